@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+)
+
+const accessReportFilename = "access-report.yaml"
+
+type accessReportEntry struct {
+	Group    string `json:"group"`
+	Version  string `json:"version"`
+	Resource string `json:"resource"`
+	// Namespace is set when the access was checked per wanted namespace
+	Namespace string `json:"namespace,omitempty"`
+	Allowed   bool   `json:"allowed"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// accessReport collects the results of the access checks and is safe for concurrent use.
+type accessReport struct {
+	mu      sync.Mutex
+	entries []accessReportEntry
+}
+
+func (r *accessReport) add(entries ...accessReportEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, entries...)
+}
+
+func (r *accessReport) write(outDir string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sort.Slice(r.entries, func(i, j int) bool {
+		a, b := r.entries[i], r.entries[j]
+		if a.Group != b.Group {
+			return a.Group < b.Group
+		}
+		if a.Version != b.Version {
+			return a.Version < b.Version
+		}
+		if a.Resource != b.Resource {
+			return a.Resource < b.Resource
+		}
+		return a.Namespace < b.Namespace
+	})
+
+	return writeReport(outDir, accessReportFilename, r.entries)
+}
+
+// checkListAccess asks the API server whether the current identity may list the resource.
+// A namespaced resource is checked in each of the wanted namespaces, if any, as the identity
+// might only be allowed to list it in them. Otherwise, it's checked cluster-wide.
+func checkListAccess(ctx context.Context, clientset kubernetes.Interface, gvr schema.GroupVersionResource, namespaced bool, wantNamespaces []string) ([]accessReportEntry, error) {
+	namespaces := []string{""}
+	if namespaced && len(wantNamespaces) > 0 && wantNamespaces[0] != "" {
+		namespaces = wantNamespaces
+	}
+
+	var entries []accessReportEntry
+	for _, namespace := range namespaces {
+		review := &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Namespace: namespace,
+					Verb:      "list",
+					Group:     gvr.Group,
+					Version:   gvr.Version,
+					Resource:  gvr.Resource,
+				},
+			},
+		}
+
+		result, err := clientset.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, review, metav1.CreateOptions{})
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, accessReportEntry{
+			Group:     gvr.Group,
+			Version:   gvr.Version,
+			Resource:  gvr.Resource,
+			Namespace: namespace,
+			Allowed:   result.Status.Allowed,
+			Reason:    result.Status.Reason,
+		})
+	}
+	return entries, nil
+}
+
+// anyAllowed reports whether the resource may be listed cluster-wide or in any of the checked namespaces.
+func anyAllowed(entries []accessReportEntry) bool {
+	for _, entry := range entries {
+		if entry.Allowed {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"golang.org/x/exp/slices"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/yaml"
+)
+
+// allowNamespaces returns a clientset whose identity may only list in the namespaces.
+func allowNamespaces(t *testing.T, namespaces ...string) kubernetes.Interface {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var review authorizationv1.SelfSubjectAccessReview
+		if err := json.NewDecoder(r.Body).Decode(&review); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		review.Status = authorizationv1.SubjectAccessReviewStatus{Reason: "denied"}
+		if slices.Contains(namespaces, review.Spec.ResourceAttributes.Namespace) {
+			review.Status = authorizationv1.SubjectAccessReviewStatus{Allowed: true}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(review)
+	}))
+	t.Cleanup(server.Close)
+
+	clientset, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return clientset
+}
+
+func TestCheckListAccess(t *testing.T) {
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	clientset := allowNamespaces(t, "team-a")
+
+	entries, err := checkListAccess(context.Background(), clientset, gvr, true, []string{""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []accessReportEntry{{Version: "v1", Resource: "configmaps", Reason: "denied"}}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("got %v, want %v", entries, want)
+	}
+	if anyAllowed(entries) {
+		t.Error("expected listing cluster-wide to be denied")
+	}
+
+	entries, err = checkListAccess(context.Background(), clientset, gvr, true, []string{"team-a", "team-b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []accessReportEntry{
+		{Version: "v1", Resource: "configmaps", Namespace: "team-a", Allowed: true},
+		{Version: "v1", Resource: "configmaps", Namespace: "team-b", Reason: "denied"},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("got %v, want %v", entries, want)
+	}
+	if !anyAllowed(entries) {
+		t.Error("expected listing in a wanted namespace to be allowed")
+	}
+
+	// cluster-scoped resources are always checked cluster-wide
+	entries, err = checkListAccess(context.Background(), clientset, schema.GroupVersionResource{Version: "v1", Resource: "nodes"}, false, []string{"team-a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Namespace != "" || entries[0].Allowed {
+		t.Errorf("got %v, want a single denied cluster-wide entry", entries)
+	}
+}
+
+func TestAccessReport(t *testing.T) {
+	report := &accessReport{}
+	report.add(accessReportEntry{Group: "apps", Version: "v1", Resource: "deployments", Allowed: true})
+	report.add(
+		accessReportEntry{Version: "v1", Resource: "secrets", Namespace: "b"},
+		accessReportEntry{Version: "v1", Resource: "secrets", Namespace: "a", Allowed: true},
+	)
+
+	outDir := t.TempDir()
+	if err := report.write(outDir); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(filepath.Join(outDir, accessReportFilename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []accessReportEntry
+	if err := yaml.Unmarshal(content, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []accessReportEntry{
+		{Version: "v1", Resource: "secrets", Namespace: "a", Allowed: true},
+		{Version: "v1", Resource: "secrets", Namespace: "b"},
+		{Group: "apps", Version: "v1", Resource: "deployments", Allowed: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// adaptiveLimiter limits the number of concurrent List calls. The limit starts at 1
+// and is increased by one after a limit's worth of calls while the rolling latency stays
+// below the target. It's halved when the API server throttles or the latency exceeds the target.
+// It is safe for concurrent use.
+type adaptiveLimiter struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+
+	max      int
+	target   time.Duration
+	limit    int
+	inflight int
+	// successes since the last change of the limit
+	successes int
+	latency   time.Duration
+}
+
+func newAdaptiveLimiter(max int, target time.Duration) *adaptiveLimiter {
+	l := &adaptiveLimiter{max: max, target: target, limit: 1}
+	l.cond = sync.NewCond(&l.mu)
+	return l
+}
+
+// acquire blocks until another call may start.
+func (l *adaptiveLimiter) acquire() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for l.inflight >= l.limit {
+		l.cond.Wait()
+	}
+	l.inflight++
+}
+
+// release records the outcome of a call and adjusts the limit.
+func (l *adaptiveLimiter) release(latency time.Duration, throttled bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.inflight--
+	defer l.cond.Broadcast()
+
+	if throttled {
+		l.decrease()
+		return
+	}
+
+	// exponentially weighted moving average
+	if l.latency == 0 {
+		l.latency = latency
+	} else {
+		l.latency = (4*l.latency + latency) / 5
+	}
+
+	if l.latency > l.target {
+		l.decrease()
+		return
+	}
+
+	l.successes++
+	if l.successes >= l.limit && l.limit < l.max {
+		l.limit++
+		l.successes = 0
+	}
+}
+
+func (l *adaptiveLimiter) decrease() {
+	l.successes = 0
+	if l.limit > 1 {
+		l.limit /= 2
+	}
+}
+
+func (l *adaptiveLimiter) currentLimit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limit
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAdaptiveLimiter(t *testing.T) {
+	limiter := newAdaptiveLimiter(4, time.Second)
+	if got := limiter.currentLimit(); got != 1 {
+		t.Fatalf("got initial limit %d, want 1", got)
+	}
+
+	call := func(latency time.Duration, throttled bool) {
+		limiter.acquire()
+		limiter.release(latency, throttled)
+	}
+
+	// 1 + 2 + 3 fast calls increase the limit to the maximum
+	for i := 0; i < 6; i++ {
+		call(10*time.Millisecond, false)
+	}
+	if got := limiter.currentLimit(); got != 4 {
+		t.Fatalf("got limit %d after fast calls, want 4", got)
+	}
+
+	for i := 0; i < 10; i++ {
+		call(10*time.Millisecond, false)
+	}
+	if got := limiter.currentLimit(); got != 4 {
+		t.Fatalf("got limit %d, want it capped at 4", got)
+	}
+
+	call(10*time.Millisecond, true)
+	if got := limiter.currentLimit(); got != 2 {
+		t.Fatalf("got limit %d after throttling, want 2", got)
+	}
+
+	call(10*time.Second, false)
+	if got := limiter.currentLimit(); got != 1 {
+		t.Fatalf("got limit %d after a slow call, want 1", got)
+	}
+
+	call(10*time.Second, true)
+	if got := limiter.currentLimit(); got != 1 {
+		t.Fatalf("got limit %d, want at least 1", got)
+	}
+}
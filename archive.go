@@ -0,0 +1,98 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// clusterArchiveName is the archive name used for cluster-scoped objects.
+const clusterArchiveName = "_cluster"
+
+type archive struct {
+	file *os.File
+	gz   *gzip.Writer
+	tw   *tar.Writer
+}
+
+// archiveWriters manages one tar.gz archive per namespace. The archives are
+// created lazily on the first write and are safe for concurrent use.
+type archiveWriters struct {
+	dir      string
+	mu       sync.Mutex
+	archives map[string]*archive
+}
+
+func newArchiveWriters(dir string) *archiveWriters {
+	return &archiveWriters{
+		dir:      dir,
+		archives: make(map[string]*archive),
+	}
+}
+
+// write adds a file with the given name and content to the archive of the namespace.
+// An empty namespace writes to the archive for cluster-scoped objects.
+func (a *archiveWriters) write(namespace, name string, content []byte) error {
+	if namespace == "" {
+		namespace = clusterArchiveName
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	arc, ok := a.archives[namespace]
+	if !ok {
+		if err := os.MkdirAll(a.dir, os.ModePerm); err != nil {
+			return fmt.Errorf("failed creating dir %q: %v", a.dir, err)
+		}
+
+		filename := filepath.Join(a.dir, namespace) + ".tar.gz"
+		file, err := os.Create(filename)
+		if err != nil {
+			return fmt.Errorf("failed creating archive %q: %v", filename, err)
+		}
+
+		gz := gzip.NewWriter(file)
+		arc = &archive{file: file, gz: gz, tw: tar.NewWriter(gz)}
+		a.archives[namespace] = arc
+	}
+
+	header := &tar.Header{
+		Name:    filepath.ToSlash(name),
+		Mode:    0o644,
+		Size:    int64(len(content)),
+		ModTime: time.Now(),
+	}
+	if err := arc.tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed writing archive header for %q: %v", name, err)
+	}
+	if _, err := arc.tw.Write(content); err != nil {
+		return fmt.Errorf("failed writing %q to archive: %v", name, err)
+	}
+	return nil
+}
+
+// Close flushes and closes all opened archives.
+func (a *archiveWriters) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var firstErr error
+	for namespace, arc := range a.archives {
+		if err := arc.tw.Close(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed closing archive of %q: %v", namespace, err)
+		}
+		if err := arc.gz.Close(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed closing archive of %q: %v", namespace, err)
+		}
+		if err := arc.file.Close(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed closing archive of %q: %v", namespace, err)
+		}
+	}
+	a.archives = make(map[string]*archive)
+	return firstErr
+}
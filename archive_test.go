@@ -0,0 +1,64 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestArchiveWriters(t *testing.T) {
+	dir := t.TempDir()
+
+	archives := newArchiveWriters(dir)
+	if err := archives.write("mynamespace", filepath.Join("configmaps", "a.yaml"), []byte("a")); err != nil {
+		t.Fatal(err)
+	}
+	if err := archives.write("", filepath.Join("namespaces", "b.yaml"), []byte("b")); err != nil {
+		t.Fatal(err)
+	}
+	if err := archives.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		archive string
+		name    string
+		content string
+	}{
+		{archive: "mynamespace.tar.gz", name: "configmaps/a.yaml", content: "a"},
+		{archive: "_cluster.tar.gz", name: "namespaces/b.yaml", content: "b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.archive, func(t *testing.T) {
+			file, err := os.Open(filepath.Join(dir, tt.archive))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+
+			gz, err := gzip.NewReader(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tr := tar.NewReader(gz)
+
+			header, err := tr.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if header.Name != tt.name {
+				t.Errorf("name = %q, want %q", header.Name, tt.name)
+			}
+			content, err := io.ReadAll(tr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(content) != tt.content {
+				t.Errorf("content = %q, want %q", content, tt.content)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// writeFileAtomic writes the file like os.WriteFile, but into a temporary file in the same directory
+// which is renamed into place, so readers never see a partially written file.
+// The temporary file is removed when writing fails.
+func writeFileAtomic(filename string, content []byte, perm os.FileMode) (err error) {
+	random := make([]byte, 8)
+	if _, err := rand.Read(random); err != nil {
+		return fmt.Errorf("failed generating temporary filename: %v", err)
+	}
+	// independent of the length of the filename, which might already be at the limit of the filesystem
+	tmpName := filepath.Join(filepath.Dir(filename), ".tmp-"+hex.EncodeToString(random))
+
+	// unlike os.CreateTemp, the permissions are subject to the umask like with os.WriteFile
+	file, err := os.OpenFile(tmpName, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			file.Close()
+			os.Remove(tmpName)
+		}
+	}()
+
+	if _, err = file.Write(content); err != nil {
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpName, filename)
+}
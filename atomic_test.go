@@ -0,0 +1,37 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "cm.yaml")
+
+	for _, content := range []string{"first\n", "second\n"} {
+		if err := writeFileAtomic(filename, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		got, err := os.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != content {
+			t.Errorf("got %q, want %q", got, content)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("got %d files, want no temporary files to remain", len(entries))
+	}
+
+	if err := writeFileAtomic(filepath.Join(dir, "missing", "cm.yaml"), []byte("content"), 0o600); err == nil {
+		t.Error("expected an error for a missing directory")
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/client-go/rest"
+)
+
+// removedAuthProviders are the in-tree auth providers which were replaced by credential plugins.
+var removedAuthProviders = map[string]string{
+	"gcp":   "install gke-gcloud-auth-plugin and run 'gcloud container clusters get-credentials' to switch the kubeconfig to it, see https://cloud.google.com/kubernetes-engine/docs/how-to/cluster-access-for-kubectl",
+	"azure": "install kubelogin and run 'kubelogin convert-kubeconfig' to switch the kubeconfig to it, see https://azure.github.io/kubelogin/",
+}
+
+// checkAuthProvider fails early with a migration hint when the kubeconfig uses a removed auth provider.
+func checkAuthProvider(config *rest.Config) error {
+	if config.AuthProvider == nil {
+		return nil
+	}
+	if hint, ok := removedAuthProviders[config.AuthProvider.Name]; ok {
+		return fmt.Errorf("the kubeconfig uses the auth provider %q, which was removed from Kubernetes clients: %s", config.AuthProvider.Name, hint)
+	}
+	return nil
+}
+
+// printAuthHelp prints how the config authenticates and what's needed for it to work.
+func printAuthHelp(w io.Writer, config *rest.Config) error {
+	var help string
+	switch {
+	case config.ExecProvider != nil:
+		command := config.ExecProvider.Command
+		help = fmt.Sprintf("authentication: credential plugin %q\n", command)
+		if path, err := exec.LookPath(command); err != nil {
+			help += "the plugin can't be found in the PATH, install it or fix the 'exec' section of the user in the kubeconfig\n"
+			if config.ExecProvider.InstallHint != "" {
+				help += config.ExecProvider.InstallHint + "\n"
+			}
+		} else {
+			help += fmt.Sprintf("the plugin was found at %q\n", path)
+		}
+		switch command {
+		case "aws", "aws-iam-authenticator":
+			help += "AWS: the plugin uses the credentials of the AWS CLI, check them with 'aws sts get-caller-identity'\n"
+		case "gke-gcloud-auth-plugin":
+			help += "GCP: the plugin uses the credentials of gcloud, check them with 'gcloud auth list'\n"
+		case "kubelogin":
+			help += "Azure: the plugin uses the login method of its arguments, e.g. 'azurecli' requires 'az login'\n"
+		}
+	case config.AuthProvider != nil:
+		help = fmt.Sprintf("authentication: auth provider %q\n", config.AuthProvider.Name)
+		if hint, ok := removedAuthProviders[config.AuthProvider.Name]; ok {
+			help += "the auth provider was removed from Kubernetes clients, " + hint + "\n"
+		}
+	case config.BearerToken != "" || config.BearerTokenFile != "":
+		help = "authentication: bearer token\nan expired token has to be replaced in the kubeconfig\n"
+	case len(config.CertData) > 0 || config.CertFile != "":
+		help = "authentication: client certificate\nan expired certificate has to be replaced in the kubeconfig\n"
+	case config.Username != "":
+		help = "authentication: basic auth\nbasic auth is not supported by current API servers, use another method if requests are unauthorized\n"
+	default:
+		help = "authentication: none\nthe requests are anonymous, configure a user in the kubeconfig\n"
+	}
+
+	_, err := io.WriteString(w, help)
+	return err
+}
+
+// reloadTokenFileOnUnauthorized lets the requests after a 401 use the current token of the config's token file.
+// client-go only re-reads the file every minute, so a retried list would still send the expired token.
+func reloadTokenFileOnUnauthorized(config *rest.Config) {
+	if config.BearerTokenFile == "" {
+		return
+	}
+	path := config.BearerTokenFile
+	config.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		return &tokenFileRoundTripper{path: path, rt: rt}
+	})
+}
+
+// tokenFileRoundTripper overrides the bearer token of the requests with the token read after the last 401.
+type tokenFileRoundTripper struct {
+	path string
+	rt   http.RoundTripper
+
+	mu    sync.Mutex
+	token string
+}
+
+func (t *tokenFileRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	token := t.token
+	t.mu.Unlock()
+
+	if token != "" {
+		req = utilnet.CloneRequest(req)
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := t.rt.RoundTrip(req)
+	if err == nil && resp.StatusCode == http.StatusUnauthorized {
+		if content, err := os.ReadFile(t.path); err == nil && strings.TrimSpace(string(content)) != "" {
+			t.mu.Lock()
+			t.token = strings.TrimSpace(string(content))
+			t.mu.Unlock()
+		}
+	}
+	return resp, err
+}
+
+func (t *tokenFileRoundTripper) WrappedRoundTripper() http.RoundTripper { return t.rt }
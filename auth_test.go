@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"k8s.io/client-go/rest"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+func TestAuthHelp(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      *rest.Config
+		wantHelp    string
+		wantErr     bool
+		wantErrHint string
+	}{
+		{
+			name:        "removed gcp provider",
+			config:      &rest.Config{AuthProvider: &clientcmdapi.AuthProviderConfig{Name: "gcp"}},
+			wantHelp:    `auth provider "gcp"`,
+			wantErr:     true,
+			wantErrHint: "gke-gcloud-auth-plugin",
+		},
+		{
+			name:     "oidc provider",
+			config:   &rest.Config{AuthProvider: &clientcmdapi.AuthProviderConfig{Name: "oidc"}},
+			wantHelp: `auth provider "oidc"`,
+		},
+		{
+			name:     "missing exec plugin",
+			config:   &rest.Config{ExecProvider: &clientcmdapi.ExecConfig{Command: "kubedump-missing-credential-plugin", InstallHint: "install the plugin"}},
+			wantHelp: "install the plugin",
+		},
+		{
+			name:     "token",
+			config:   &rest.Config{BearerToken: "test"},
+			wantHelp: "bearer token",
+		},
+		{
+			name:     "anonymous",
+			config:   &rest.Config{},
+			wantHelp: "anonymous",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var help bytes.Buffer
+			if err := printAuthHelp(&help, tt.config); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(help.String(), tt.wantHelp) {
+				t.Errorf("got help %q, want it to contain %q", help.String(), tt.wantHelp)
+			}
+
+			err := checkAuthProvider(tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkAuthProvider() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), tt.wantErrHint) {
+				t.Errorf("got error %v, want it to contain %q", err, tt.wantErrHint)
+			}
+		})
+	}
+}
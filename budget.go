@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// byteBudget limits the estimated number of bytes of objects which are processed concurrently.
+type byteBudget struct {
+	mu    sync.Mutex
+	cond  *sync.Cond
+	limit int64
+	used  int64
+}
+
+func newByteBudget(limit int64) *byteBudget {
+	b := &byteBudget{limit: limit}
+	b.cond = sync.NewCond(&b.mu)
+	return b
+}
+
+// acquire blocks until n bytes are available and returns the acquired bytes, which have to be released.
+// Requests larger than the limit are capped to the limit, so they can still run when nothing else is in flight.
+func (b *byteBudget) acquire(n int64) int64 {
+	if n > b.limit {
+		n = b.limit
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for b.used > 0 && b.used+n > b.limit {
+		b.cond.Wait()
+	}
+	b.used += n
+	return n
+}
+
+func (b *byteBudget) release(n int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.used -= n
+	b.cond.Broadcast()
+}
+
+// estimateListSize estimates the size of listing the resource by fetching a single object
+// and multiplying its size with the number of objects reported by the API server.
+// When the number of objects is unknown, -1 is returned.
+func estimateListSize(ctx context.Context, dynamicClient dynamic.Interface, gvr schema.GroupVersionResource) (int64, error) {
+	sample, err := dynamicClient.Resource(gvr).List(ctx, metav1.ListOptions{Limit: 1})
+	if err != nil {
+		return 0, err
+	}
+	if len(sample.Items) == 0 {
+		return 0, nil
+	}
+
+	jsonBytes, err := json.Marshal(sample.Items[0].Object)
+	if err != nil {
+		return 0, err
+	}
+
+	count := int64(1)
+	if remaining := sample.GetRemainingItemCount(); remaining != nil {
+		count += *remaining
+	} else if sample.GetContinue() != "" {
+		return -1, nil
+	}
+	return int64(len(jsonBytes)) * count, nil
+}
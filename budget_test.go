@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestByteBudget(t *testing.T) {
+	budget := newByteBudget(10)
+
+	if got := budget.acquire(6); got != 6 {
+		t.Fatalf("acquire() = %d, want %d", got, 6)
+	}
+
+	acquired := make(chan int64)
+	go func() {
+		acquired <- budget.acquire(5)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("acquire() should block while the budget is exceeded")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	budget.release(6)
+	if got := <-acquired; got != 5 {
+		t.Errorf("acquire() = %d, want %d", got, 5)
+	}
+	budget.release(5)
+
+	if got := budget.acquire(20); got != 10 {
+		t.Errorf("acquire() = %d, want capped %d", got, 10)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
+)
+
+// checkConnectivity loads the config and lists a single namespace, to verify the connection,
+// the authentication and the list permission without dumping anything. The status is printed to w.
+func checkConnectivity(ctx context.Context, w io.Writer, opts configOptions) error {
+	config, err := buildConfigFromFlags(opts)
+	if err != nil {
+		fmt.Fprintf(w, "config:         failed\n")
+		return err
+	}
+	fmt.Fprintf(w, "config:         ok (%v)\n", config.Host)
+
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return fmt.Errorf("failed creating dynamic client: %v", err)
+	}
+	return checkList(ctx, w, dynamicClient.Resource(namespacesGVR))
+}
+
+// checkList lists a single object of the resource and prints which step failed.
+func checkList(ctx context.Context, w io.Writer, client dynamic.ResourceInterface) error {
+	_, err := client.List(ctx, metav1.ListOptions{Limit: 1})
+	switch {
+	case err == nil:
+		fmt.Fprintf(w, "authentication: ok\nlist:           ok\n")
+	case apierrors.IsUnauthorized(err):
+		fmt.Fprintf(w, "authentication: failed\n")
+	case apierrors.IsForbidden(err):
+		fmt.Fprintf(w, "authentication: ok\nlist:           forbidden\n")
+	default:
+		fmt.Fprintf(w, "connection:     failed\n")
+	}
+	return err
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// failingResource fails all List calls with the error, or succeeds without any objects when it's nil.
+type failingResource struct {
+	dynamic.NamespaceableResourceInterface
+	err error
+}
+
+func (r *failingResource) List(context.Context, metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	return &unstructured.UnstructuredList{}, nil
+}
+
+func TestCheckList(t *testing.T) {
+	gr := schema.GroupResource{Resource: "namespaces"}
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "ok", want: "authentication: ok\nlist:           ok\n"},
+		{name: "unauthorized", err: apierrors.NewUnauthorized("expired token"), want: "authentication: failed\n"},
+		{name: "forbidden", err: apierrors.NewForbidden(gr, "", errors.New("denied")), want: "authentication: ok\nlist:           forbidden\n"},
+		{name: "unreachable", err: errors.New("connection refused"), want: "connection:     failed\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := checkList(context.Background(), &buf, &failingResource{err: tt.err})
+			if !errors.Is(err, tt.err) {
+				t.Errorf("got error %v, want %v", err, tt.err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
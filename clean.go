@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// stdoutDir as the output directory writes the cleaned manifests to stdout.
+const stdoutDir = "-"
+
+// cleanObjects reads YAML or JSON documents and writes them like dumped objects, either with
+// the regular layout or, when stdout isn't nil, as a multi-document YAML stream.
+// The items of lists are written as separate objects. It returns the number of written objects.
+func cleanObjects(r io.Reader, opts writeOptions, stdout io.Writer) (int, error) {
+	decoder := utilyaml.NewYAMLOrJSONDecoder(r, 4096)
+
+	var written int
+	for doc := 1; ; doc++ {
+		var obj map[string]interface{}
+		if err := decoder.Decode(&obj); err != nil {
+			if errors.Is(err, io.EOF) {
+				return written, nil
+			}
+			return written, fmt.Errorf("failed decoding document %d: %v", doc, err)
+		}
+		if len(obj) == 0 {
+			continue
+		}
+
+		items := []unstructured.Unstructured{{Object: obj}}
+		if list := (unstructured.Unstructured{Object: obj}); list.IsList() {
+			items = nil
+			if err := list.EachListItem(func(o runtime.Object) error {
+				items = append(items, *o.(*unstructured.Unstructured))
+				return nil
+			}); err != nil {
+				return written, fmt.Errorf("failed reading items of document %d: %v", doc, err)
+			}
+		}
+
+		for _, item := range items {
+			if item.GetKind() == "" || item.GetAPIVersion() == "" {
+				return written, fmt.Errorf("object %q of document %d has no kind or apiVersion", item.GetName(), doc)
+			}
+			gvr := guessResource(item.GroupVersionKind())
+
+			if stdout == nil {
+				if err := writeYAML(opts, gvr, item); err != nil {
+					return written, err
+				}
+				written++
+				continue
+			}
+
+			manifest, err := renderManifest(opts, gvr, item)
+			if err != nil {
+				return written, err
+			}
+			if _, err := fmt.Fprintf(stdout, "---\n%s", manifest); err != nil {
+				return written, err
+			}
+			written++
+		}
+	}
+}
+
+// guessResource returns the resource of the kind without discovery, which is correct for the built-in kinds
+// and most custom resources.
+func guessResource(gvk schema.GroupVersionKind) schema.GroupVersionResource {
+	gvr, _ := meta.UnsafeGuessKindToResource(gvk)
+	return gvr
+}
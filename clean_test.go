@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCleanObjects(t *testing.T) {
+	input := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm1
+  namespace: ns1
+  uid: 123
+  resourceVersion: "42"
+data:
+  foo: bar
+---
+{"apiVersion": "v1", "kind": "List", "items": [{"apiVersion": "apps/v1", "kind": "Deployment", "metadata": {"name": "web", "namespace": "ns1"}, "status": {"replicas": 1}}]}
+---
+`
+
+	t.Run("stdout", func(t *testing.T) {
+		var stdout bytes.Buffer
+		written, err := cleanObjects(strings.NewReader(input), writeOptions{stateless: true}, &stdout)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if written != 2 {
+			t.Errorf("got %d written objects, want 2", written)
+		}
+
+		want := `---
+apiVersion: v1
+data:
+  foo: bar
+kind: ConfigMap
+metadata:
+  name: cm1
+  namespace: ns1
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: ns1
+`
+		if got := stdout.String(); got != want {
+			t.Errorf("got\n%s\nwant\n%s", got, want)
+		}
+	})
+
+	t.Run("dir", func(t *testing.T) {
+		dir := t.TempDir()
+		if _, err := cleanObjects(strings.NewReader(input), writeOptions{outDir: dir, stateless: true}, nil); err != nil {
+			t.Fatal(err)
+		}
+		for _, file := range []string{"namespaced/ns1/configmaps/cm1.yaml", "namespaced/ns1/deployments.apps/web.yaml"} {
+			if _, err := os.Stat(filepath.Join(dir, file)); err != nil {
+				t.Error(err)
+			}
+		}
+	})
+
+	t.Run("missing kind", func(t *testing.T) {
+		if _, err := cleanObjects(strings.NewReader("metadata:\n  name: foo\n"), writeOptions{}, &bytes.Buffer{}); err == nil {
+			t.Error("expected an error for an object without a kind")
+		}
+	})
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+// collectObjects lists all objects of the cluster which pass the filters, with their state cleaned.
+// Only the preferred version of each group is listed, so every object is collected once.
+// The objects are keyed by their group, resource, namespace and name.
+func collectObjects(config configOptions, resFilter resourceFilter, filter itemFilter, threads uint64) (map[string]map[string]interface{}, error) {
+	kubeConfig, err := buildConfigFromFlags(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed getting Kubernetes config: %v", err)
+	}
+
+	clientset, err := kubernetes.NewForConfig(kubeConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed getting Kubernetes clientset: %v", err)
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(kubeConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed creating dynamic client: %v", err)
+	}
+
+	discovered, _, err := discoverResources(clientset.DiscoveryClient, nil, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed discovering resources: %v", err)
+	}
+
+	var (
+		mu          sync.Mutex
+		objects     = make(map[string]map[string]interface{})
+		waitGroup   sync.WaitGroup
+		threadGuard = make(chan struct{}, threads)
+	)
+
+	for _, gv := range discovered {
+		if gv.version.GroupVersion != gv.group.PreferredVersion.GroupVersion {
+			continue
+		}
+
+		for _, res := range gv.resources {
+			if skipResource(res, gv.group.Name, resFilter) {
+				continue
+			}
+
+			gvr := schema.GroupVersionResource{Group: gv.group.Name, Version: gv.version.Version, Resource: res.Name}
+
+			threadGuard <- struct{}{}
+			waitGroup.Add(1)
+			go func() {
+				defer func() {
+					waitGroup.Done()
+					<-threadGuard
+				}()
+
+				unstrList, err := dynamicClient.Resource(gvr).List(context.Background(), metav1.ListOptions{})
+				if err != nil {
+					log.Printf("failed listing %v: %v\n", gvr.String(), err)
+					return
+				}
+
+				for _, item := range unstrList.Items {
+					if skipItem(item, filter) {
+						continue
+					}
+					cleanState(item)
+
+					key := fmt.Sprintf("%s %s/%s", resourceAndGroupName(gvr), item.GetNamespace(), item.GetName())
+					mu.Lock()
+					objects[key] = item.Object
+					mu.Unlock()
+				}
+			}()
+		}
+	}
+
+	waitGroup.Wait()
+	return objects, nil
+}
+
+// flattenFields maps the path of every leaf field of the value to its JSON representation.
+func flattenFields(path string, value interface{}, fields map[string]string) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, val := range v {
+			flattenFields(joinFieldPath(path, key), val, fields)
+		}
+	case []interface{}:
+		for i, val := range v {
+			flattenFields(path+"["+strconv.Itoa(i)+"]", val, fields)
+		}
+	default:
+		jsonBytes, err := json.Marshal(v)
+		if err != nil {
+			jsonBytes = []byte(fmt.Sprint(v))
+		}
+		fields[path] = string(jsonBytes)
+	}
+}
+
+func joinFieldPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// diffFields returns the differences of the fields of both objects, one line per field.
+func diffFields(a, b map[string]interface{}) []string {
+	fieldsA := make(map[string]string)
+	fieldsB := make(map[string]string)
+	flattenFields("", a, fieldsA)
+	flattenFields("", b, fieldsB)
+
+	var diffs []string
+	for path, valA := range fieldsA {
+		valB, ok := fieldsB[path]
+		switch {
+		case !ok:
+			diffs = append(diffs, fmt.Sprintf("%s: %s != <missing>", path, valA))
+		case valA != valB:
+			diffs = append(diffs, fmt.Sprintf("%s: %s != %s", path, valA, valB))
+		}
+	}
+	for path, valB := range fieldsB {
+		if _, ok := fieldsA[path]; !ok {
+			diffs = append(diffs, fmt.Sprintf("%s: <missing> != %s", path, valB))
+		}
+	}
+
+	sort.Strings(diffs)
+	return diffs
+}
+
+// printComparison writes the objects which exist only in one of the clusters
+// and the field differences of the objects existing in both clusters.
+// It returns the number of differing objects.
+func printComparison(w io.Writer, nameA, nameB string, objectsA, objectsB map[string]map[string]interface{}) int {
+	keys := make(map[string]bool)
+	for key := range objectsA {
+		keys[key] = true
+	}
+	for key := range objectsB {
+		keys[key] = true
+	}
+
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+
+	differences := 0
+	for _, key := range sorted {
+		objA, okA := objectsA[key]
+		objB, okB := objectsB[key]
+
+		switch {
+		case !okB:
+			fmt.Fprintf(w, "only in %s: %s\n", nameA, key)
+		case !okA:
+			fmt.Fprintf(w, "only in %s: %s\n", nameB, key)
+		default:
+			diffs := diffFields(objA, objB)
+			if len(diffs) == 0 {
+				continue
+			}
+			fmt.Fprintf(w, "differs: %s\n", key)
+			for _, diff := range diffs {
+				fmt.Fprintf(w, "  %s\n", diff)
+			}
+		}
+		differences++
+	}
+	return differences
+}
+
+// compareClusters dumps the objects of both contexts in memory and prints their differences.
+func compareClusters(w io.Writer, contexts string, config configOptions, resFilter resourceFilter, filter itemFilter, threads uint64) (int, error) {
+	names := strings.Split(contexts, ",")
+	if len(names) != 2 || names[0] == "" || names[1] == "" {
+		return 0, fmt.Errorf("expected two contexts (e.g. 'ctx1,ctx2'), got %q", contexts)
+	}
+
+	var objects [2]map[string]map[string]interface{}
+	for i, name := range names {
+		config.context = name
+
+		var err error
+		objects[i], err = collectObjects(config, resFilter, filter, threads)
+		if err != nil {
+			return 0, fmt.Errorf("context %q: %v", name, err)
+		}
+	}
+
+	return printComparison(w, names[0], names[1], objects[0], objects[1]), nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"golang.org/x/exp/slices"
+)
+
+func TestDiffFields(t *testing.T) {
+	a := map[string]interface{}{
+		"spec": map[string]interface{}{
+			"replicas": int64(3),
+			"ports":    []interface{}{"80", "443"},
+			"paused":   true,
+		},
+	}
+	b := map[string]interface{}{
+		"spec": map[string]interface{}{
+			"replicas": int64(2),
+			"ports":    []interface{}{"80", "443"},
+			"selector": "app=web",
+		},
+	}
+
+	want := []string{
+		`spec.paused: true != <missing>`,
+		`spec.replicas: 3 != 2`,
+		`spec.selector: <missing> != "app=web"`,
+	}
+	if got := diffFields(a, b); !slices.Equal(got, want) {
+		t.Errorf("diffFields() = %q, want %q", got, want)
+	}
+}
+
+func TestPrintComparison(t *testing.T) {
+	objectsA := map[string]map[string]interface{}{
+		"configmaps default/a": {"data": map[string]interface{}{"key": "a"}},
+		"configmaps default/b": {"data": map[string]interface{}{"key": "b"}},
+		"configmaps default/c": {"data": map[string]interface{}{"key": "c"}},
+	}
+	objectsB := map[string]map[string]interface{}{
+		"configmaps default/b": {"data": map[string]interface{}{"key": "b"}},
+		"configmaps default/c": {"data": map[string]interface{}{"key": "changed"}},
+		"configmaps default/d": {"data": map[string]interface{}{"key": "d"}},
+	}
+
+	var buf bytes.Buffer
+	if got := printComparison(&buf, "one", "two", objectsA, objectsB); got != 3 {
+		t.Errorf("printComparison() = %d, want %d", got, 3)
+	}
+
+	want := "" +
+		"only in one: configmaps default/a\n" +
+		"differs: configmaps default/c\n" +
+		"  data.key: \"c\" != \"changed\"\n" +
+		"only in two: configmaps default/d\n"
+	if got := buf.String(); got != want {
+		t.Errorf("printComparison() =\n%s\nwant:\n%s", got, want)
+	}
+}
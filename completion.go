@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+// hiddenFlags are not shown in the usage, as they are only used by scripts.
+var hiddenFlags = map[string]bool{
+	"complete-resources": true,
+}
+
+// printUsage prints the usage like the flag package, but without the hidden flags.
+func printUsage() {
+	visible := flag.NewFlagSet(flag.CommandLine.Name(), flag.ContinueOnError)
+	visible.SetOutput(flag.CommandLine.Output())
+	flag.VisitAll(func(f *flag.Flag) {
+		if hiddenFlags[f.Name] {
+			return
+		}
+		visible.Var(f.Value, f.Name, f.Usage)
+		// the value might already be parsed
+		visible.Lookup(f.Name).DefValue = f.DefValue
+	})
+
+	fmt.Fprintf(visible.Output(), "Usage of %s:\n", flag.CommandLine.Name())
+	visible.PrintDefaults()
+}
+
+// isFlagSet reports whether the flag was set on the command line.
+func isFlagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// completeResources prints the names of the listable resources starting with the prefix, one per line.
+// For a comma-separated prefix, only the last element is completed.
+func completeResources(w io.Writer, discovered []groupVersionResources, prefix string) error {
+	head, last := "", prefix
+	if i := strings.LastIndex(prefix, ","); i >= 0 {
+		head, last = prefix[:i+1], prefix[i+1:]
+	}
+
+	var names []string
+	for _, gv := range discovered {
+		for _, res := range gv.resources {
+			if strings.Contains(res.Name, "/") || !slices.Contains(res.Verbs, "list") {
+				continue
+			}
+			if strings.HasPrefix(res.Name, last) && !slices.Contains(names, res.Name) {
+				names = append(names, res.Name)
+			}
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if _, err := fmt.Fprintln(w, head+name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
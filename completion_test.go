@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCompleteResources(t *testing.T) {
+	discovered := []groupVersionResources{
+		{resources: []metav1.APIResource{
+			{Name: "pods", Verbs: metav1.Verbs{"list"}},
+			{Name: "pods/log", Verbs: metav1.Verbs{"get"}},
+			{Name: "podtemplates", Verbs: metav1.Verbs{"list"}},
+			{Name: "secrets", Verbs: metav1.Verbs{"list"}},
+			{Name: "bindings", Verbs: metav1.Verbs{"create"}},
+		}},
+		{resources: []metav1.APIResource{
+			{Name: "pods", Verbs: metav1.Verbs{"list"}},
+		}},
+	}
+
+	tests := []struct {
+		prefix string
+		want   string
+	}{
+		{prefix: "", want: "pods\npodtemplates\nsecrets\n"},
+		{prefix: "pod", want: "pods\npodtemplates\n"},
+		{prefix: "secrets,po", want: "secrets,pods\nsecrets,podtemplates\n"},
+		{prefix: "bind", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.prefix, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := completeResources(&buf, discovered, tt.prefix); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("got %q, want %q", buf.String(), tt.want)
+			}
+		})
+	}
+}
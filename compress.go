@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+const compressedFilesFilename = "compressed-files.yaml"
+
+// gzipContent compresses the content of a single file.
+func gzipContent(content []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(content); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// compressedFiles records the files which were written compressed. It is safe for concurrent use.
+type compressedFiles struct {
+	mu    sync.Mutex
+	files []string
+}
+
+func (c *compressedFiles) add(filename string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.files = append(c.files, filepath.ToSlash(filename))
+}
+
+// write writes the list of compressed files, but only when files were compressed.
+func (c *compressedFiles) write(outDir string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.files) == 0 {
+		return nil
+	}
+	sort.Strings(c.files)
+	return writeReport(outDir, compressedFilesFilename, c.files)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestWriteYAMLCompressThreshold(t *testing.T) {
+	dir := t.TempDir()
+	opts := writeOptions{outDir: dir, compressThreshold: 100, compressed: &compressedFiles{}}
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+
+	small := unstructured.Unstructured{Object: map[string]interface{}{"data": map[string]interface{}{"foo": "bar"}}}
+	small.SetName("small")
+	large := unstructured.Unstructured{Object: map[string]interface{}{"data": map[string]interface{}{"foo": strings.Repeat("bar", 100)}}}
+	large.SetName("large")
+
+	for _, item := range []unstructured.Unstructured{small, large} {
+		if err := writeYAML(opts, gvr, item); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "clusterscoped", "configmaps", "small.yaml")); err != nil {
+		t.Errorf("small file is not written uncompressed: %v", err)
+	}
+
+	compressed, err := os.ReadFile(filepath.Join(dir, "clusterscoped", "configmaps", "large.yaml.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), strings.Repeat("bar", 100)) {
+		t.Errorf("got decompressed content %q, want the manifest", content)
+	}
+
+	if want := []string{"clusterscoped/configmaps/large.yaml.gz"}; !reflect.DeepEqual(opts.compressed.files, want) {
+		t.Errorf("got compressed files %v, want %v", opts.compressed.files, want)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// convertedKind identifies the resources which the API server converts between group versions,
+// e.g. the ingresses of extensions/v1beta1 and networking.k8s.io/v1.
+type convertedKind struct {
+	group    string
+	resource string
+	kind     string
+}
+
+// groupAliases maps the resources, which moved to another group, to the group they are served by now.
+// Resources of other groups are never the same API, even with the same name and kind (e.g. Knative services).
+var groupAliases = map[schema.GroupResource]string{
+	{Group: "extensions", Resource: "daemonsets"}:          "apps",
+	{Group: "extensions", Resource: "deployments"}:         "apps",
+	{Group: "extensions", Resource: "replicasets"}:         "apps",
+	{Group: "extensions", Resource: "ingresses"}:           "networking.k8s.io",
+	{Group: "extensions", Resource: "networkpolicies"}:     "networking.k8s.io",
+	{Group: "extensions", Resource: "podsecuritypolicies"}: "policy",
+	{Group: "events.k8s.io", Resource: "events"}:           "",
+}
+
+// newConvertedKind identifies the resource by its current group, subresources by the group of their resource.
+func newConvertedKind(group string, res metav1.APIResource) convertedKind {
+	resource, _, _ := strings.Cut(res.Name, "/")
+	if alias, ok := groupAliases[schema.GroupResource{Group: group, Resource: resource}]; ok {
+		group = alias
+	}
+	return convertedKind{group: group, resource: res.Name, kind: res.Kind}
+}
+
+// convertToVersions dumps the resources served by the target group versions only through them,
+// so the API server converts the objects of all other group versions serving the same resource and kind
+// in the same group or in one of its groupAliases.
+// When a target group version isn't served, the objects are dumped in the versions they are served in.
+func convertToVersions(discovered []groupVersionResources, targets []string) ([]groupVersionResources, error) {
+	targetOf := make(map[convertedKind]string)
+	for _, target := range targets {
+		gv, err := schema.ParseGroupVersion(target)
+		if err != nil {
+			return nil, fmt.Errorf("invalid group version %q: %v", target, err)
+		}
+
+		found := false
+		for _, served := range discovered {
+			if served.group.Name != gv.Group || served.version.Version != gv.Version {
+				continue
+			}
+			found = true
+			for _, res := range served.resources {
+				targetOf[newConvertedKind(served.group.Name, res)] = served.version.GroupVersion
+			}
+		}
+		if !found {
+			log.Printf("group version %q is not served, dumping its resources in the served versions\n", target)
+		}
+	}
+
+	var converted []groupVersionResources
+	for _, gv := range discovered {
+		var resources []metav1.APIResource
+		for _, res := range gv.resources {
+			if target, ok := targetOf[newConvertedKind(gv.group.Name, res)]; ok && target != gv.version.GroupVersion {
+				continue
+			}
+			resources = append(resources, res)
+		}
+
+		gv.resources = resources
+		converted = append(converted, gv)
+	}
+	return converted, nil
+}
+
+// parseGroupVersions splits the comma-separated group versions, ignoring empty entries.
+func parseGroupVersions(value string) []string {
+	var groupVersions []string
+	for _, gv := range strings.Split(value, ",") {
+		if gv = strings.TrimSpace(gv); gv != "" {
+			groupVersions = append(groupVersions, gv)
+		}
+	}
+	return groupVersions
+}
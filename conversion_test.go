@@ -0,0 +1,77 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestConvertToVersions(t *testing.T) {
+	newGroupVersion := func(group, version string, resources ...metav1.APIResource) groupVersionResources {
+		groupVersion := version
+		if group != "" {
+			groupVersion = group + "/" + version
+		}
+		return groupVersionResources{
+			group:     metav1.APIGroup{Name: group},
+			version:   metav1.GroupVersionForDiscovery{GroupVersion: groupVersion, Version: version},
+			resources: resources,
+		}
+	}
+	ingresses := metav1.APIResource{Name: "ingresses", Kind: "Ingress"}
+	ingressStatus := metav1.APIResource{Name: "ingresses/status", Kind: "Ingress"}
+	deployments := metav1.APIResource{Name: "deployments", Kind: "Deployment"}
+
+	discovered := []groupVersionResources{
+		newGroupVersion("extensions", "v1beta1", ingresses, ingressStatus, deployments),
+		newGroupVersion("networking.k8s.io", "v1beta1", ingresses),
+		newGroupVersion("networking.k8s.io", "v1", ingresses, ingressStatus),
+	}
+
+	got, err := convertToVersions(discovered, []string{"networking.k8s.io/v1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []groupVersionResources{
+		newGroupVersion("extensions", "v1beta1", deployments),
+		newGroupVersion("networking.k8s.io", "v1beta1"),
+		newGroupVersion("networking.k8s.io", "v1", ingresses, ingressStatus),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	got, err = convertToVersions(discovered, []string{"networking.k8s.io/v2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, discovered) {
+		t.Errorf("got %v, want the served versions unchanged", got)
+	}
+
+	// the same name and kind in an unrelated group is a different API
+	services := metav1.APIResource{Name: "services", Kind: "Service"}
+	events := metav1.APIResource{Name: "events", Kind: "Event"}
+	withCRD := []groupVersionResources{
+		newGroupVersion("", "v1", services, events),
+		newGroupVersion("events.k8s.io", "v1", events),
+		newGroupVersion("serving.knative.dev", "v1", services),
+	}
+	got, err = convertToVersions(withCRD, []string{"v1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []groupVersionResources{
+		newGroupVersion("", "v1", services, events),
+		newGroupVersion("events.k8s.io", "v1"),
+		newGroupVersion("serving.knative.dev", "v1", services),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if _, err := convertToVersions(discovered, []string{"a/b/c"}); err == nil {
+		t.Error("expected an error for an invalid group version")
+	}
+}
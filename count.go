@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"sync"
+	"text/tabwriter"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+type resourceCount struct {
+	gvr   schema.GroupVersionResource
+	count int64
+}
+
+// countResource counts the objects of the resource in the namespaces, or cluster-wide when namespaces is empty.
+// The count is taken from the number of remaining objects of a single object list,
+// falling back to listing all objects where the API server doesn't report it, unless exact is false.
+// Then -1 is returned for an unknown count instead.
+func countResource(ctx context.Context, client dynamic.NamespaceableResourceInterface, namespaces []string, exact bool) (int64, error) {
+	clients := []dynamic.ResourceInterface{client}
+	if len(namespaces) > 0 {
+		clients = nil
+		for _, ns := range namespaces {
+			clients = append(clients, client.Namespace(ns))
+		}
+	}
+
+	var total int64
+	for _, c := range clients {
+		count, err := countObjects(ctx, c)
+		if err != nil {
+			return 0, err
+		}
+		if count < 0 && !exact {
+			return -1, nil
+		}
+		if count < 0 {
+			list, err := listResource(ctx, c, listOptions{})
+			if err != nil {
+				return 0, err
+			}
+			count = int64(len(list.Items))
+		}
+		total += count
+	}
+	return total, nil
+}
+
+// countResources counts the objects of all resources which pass the filter. Only the wanted namespaces
+// of the item filter are considered. Resources which fail to be counted are logged and left out,
+// unless exact is false. Then they are returned with an unknown count of -1, like those without a reported count.
+func countResources(ctx context.Context, dynamicClient dynamic.Interface, discovered []groupVersionResources, resFilter resourceFilter, wantNamespaces []string, threads uint64, exact bool) []resourceCount {
+	if len(wantNamespaces) > 0 && wantNamespaces[0] == "" {
+		wantNamespaces = nil
+	}
+
+	var (
+		mu          sync.Mutex
+		counts      []resourceCount
+		waitGroup   sync.WaitGroup
+		threadGuard = make(chan struct{}, threads)
+	)
+
+	for _, gv := range discovered {
+		for _, res := range gv.resources {
+			if skipResource(res, gv.group.Name, resFilter) {
+				continue
+			}
+
+			gvr := schema.GroupVersionResource{Group: gv.group.Name, Version: gv.version.Version, Resource: res.Name}
+			var namespaces []string
+			if res.Namespaced {
+				namespaces = wantNamespaces
+			} else if len(wantNamespaces) > 0 {
+				// cluster-scoped objects are not in any of the wanted namespaces
+				continue
+			}
+
+			threadGuard <- struct{}{}
+			waitGroup.Add(1)
+			go func() {
+				defer func() {
+					waitGroup.Done()
+					<-threadGuard
+				}()
+
+				count, err := countResource(ctx, dynamicClient.Resource(gvr), namespaces, exact)
+				if err != nil {
+					log.Printf("failed counting %v: %v\n", gvr.String(), err)
+					if exact {
+						return
+					}
+					count = -1
+				}
+
+				mu.Lock()
+				defer mu.Unlock()
+				counts = append(counts, resourceCount{gvr: gvr, count: count})
+			}()
+		}
+	}
+
+	waitGroup.Wait()
+
+	sort.Slice(counts, func(i, j int) bool {
+		a, b := counts[i].gvr, counts[j].gvr
+		if a.Group != b.Group {
+			return a.Group < b.Group
+		}
+		if a.Resource != b.Resource {
+			return a.Resource < b.Resource
+		}
+		return a.Version < b.Version
+	})
+	return counts
+}
+
+func printCounts(w io.Writer, counts []resourceCount) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "GROUP\tVERSION\tRESOURCE\tCOUNT")
+
+	var total int64
+	for _, c := range counts {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\n", c.gvr.Group, c.gvr.Version, c.gvr.Resource, c.count)
+		total += c.count
+	}
+	fmt.Fprintf(tw, "\t\tTOTAL\t%d\n", total)
+	return tw.Flush()
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// clusterResource serves a resource which is only listed cluster-wide.
+type clusterResource struct {
+	dynamic.NamespaceableResourceInterface
+	resource dynamic.ResourceInterface
+}
+
+func (r *clusterResource) List(ctx context.Context, opts metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	return r.resource.List(ctx, opts)
+}
+
+func TestCountResource(t *testing.T) {
+	tests := []struct {
+		name       string
+		client     dynamic.NamespaceableResourceInterface
+		namespaces []string
+		want       int64
+	}{
+		{name: "remaining count", client: &clusterResource{resource: &pagedResource{objects: 5}}, want: 5},
+		{name: "namespaces", client: &namespacedResource{namespaces: 10, objects: 3}, namespaces: []string{"ns1", "ns2"}, want: 6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := countResource(context.Background(), tt.client, tt.namespaces, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrintCounts(t *testing.T) {
+	counts := []resourceCount{
+		{gvr: schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}, count: 3},
+		{gvr: schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}, count: 2},
+	}
+
+	var buf bytes.Buffer
+	if err := printCounts(&buf, counts); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "GROUP  VERSION  RESOURCE     COUNT\n" +
+		"       v1       configmaps   3\n" +
+		"apps   v1       deployments  2\n" +
+		"                TOTAL        5\n"
+	if buf.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", buf.String(), want)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+var crdGVR = schema.GroupVersionResource{Group: "apiextensions.k8s.io", Version: "v1", Resource: "customresourcedefinitions"}
+
+// customResourceGroups returns the groups defined by CRDs, including the group of the CRDs themselves.
+func customResourceGroups(ctx context.Context, dynamicClient dynamic.Interface) (map[string]bool, error) {
+	list, err := dynamicClient.Resource(crdGVR).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	groups := map[string]bool{crdGVR.Group: true}
+	for _, item := range list.Items {
+		if group, _, _ := unstructured.NestedString(item.Object, "spec", "group"); group != "" {
+			groups[group] = true
+		}
+	}
+	return groups, nil
+}
+
+// crdStorageVersions returns the version in which the objects of each custom resource are stored.
+func crdStorageVersions(ctx context.Context, dynamicClient dynamic.Interface) (map[schema.GroupResource]string, error) {
+	list, err := dynamicClient.Resource(crdGVR).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return storageVersions(list.Items), nil
+}
+
+func storageVersions(crds []unstructured.Unstructured) map[schema.GroupResource]string {
+	versions := make(map[schema.GroupResource]string)
+	for _, crd := range crds {
+		group, _, _ := unstructured.NestedString(crd.Object, "spec", "group")
+		plural, _, _ := unstructured.NestedString(crd.Object, "spec", "names", "plural")
+		specVersions, _, _ := unstructured.NestedSlice(crd.Object, "spec", "versions")
+
+		for _, v := range specVersions {
+			version, ok := v.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if storage, _, _ := unstructured.NestedBool(version, "storage"); storage {
+				name, _, _ := unstructured.NestedString(version, "name")
+				versions[schema.GroupResource{Group: group, Resource: plural}] = name
+			}
+		}
+	}
+	return versions
+}
+
+// onlyStorageVersions removes the custom resources served in other versions than their storage version,
+// so each custom object is only dumped once. All other resources are kept.
+func onlyStorageVersions(discovered []groupVersionResources, versions map[schema.GroupResource]string) []groupVersionResources {
+	var filtered []groupVersionResources
+	for _, gv := range discovered {
+		var resources []metav1.APIResource
+		for _, res := range gv.resources {
+			// subresources share the storage version of their resource
+			resource, _, _ := strings.Cut(res.Name, "/")
+			storageVersion, ok := versions[schema.GroupResource{Group: gv.group.Name, Resource: resource}]
+			if ok && storageVersion != gv.version.Version {
+				continue
+			}
+			resources = append(resources, res)
+		}
+
+		gv.resources = resources
+		filtered = append(filtered, gv)
+	}
+	return filtered
+}
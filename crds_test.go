@@ -0,0 +1,54 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestOnlyStorageVersions(t *testing.T) {
+	crd := unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"group": "example.com",
+			"names": map[string]interface{}{"plural": "foos"},
+			"versions": []interface{}{
+				map[string]interface{}{"name": "v1alpha1", "storage": false},
+				map[string]interface{}{"name": "v1", "storage": true},
+			},
+		},
+	}}
+
+	versions := storageVersions([]unstructured.Unstructured{crd})
+	if want := map[schema.GroupResource]string{{Group: "example.com", Resource: "foos"}: "v1"}; !reflect.DeepEqual(versions, want) {
+		t.Fatalf("got storage versions %v, want %v", versions, want)
+	}
+
+	newGroupVersion := func(group, version string, resources ...string) groupVersionResources {
+		gv := groupVersionResources{
+			group:   metav1.APIGroup{Name: group},
+			version: metav1.GroupVersionForDiscovery{Version: version},
+		}
+		for _, res := range resources {
+			gv.resources = append(gv.resources, metav1.APIResource{Name: res})
+		}
+		return gv
+	}
+
+	discovered := []groupVersionResources{
+		newGroupVersion("example.com", "v1alpha1", "foos", "foos/status", "bars"),
+		newGroupVersion("example.com", "v1", "foos", "foos/status"),
+		newGroupVersion("apps", "v1", "deployments"),
+	}
+	want := []groupVersionResources{
+		newGroupVersion("example.com", "v1alpha1", "bars"),
+		newGroupVersion("example.com", "v1", "foos", "foos/status"),
+		newGroupVersion("apps", "v1", "deployments"),
+	}
+
+	if got := onlyStorageVersions(discovered, versions); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"crypto/sha256"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// contentIndex records the first file written with each content, so duplicates can be linked to it.
+// It is safe for concurrent use.
+type contentIndex struct {
+	mu    sync.Mutex
+	files map[[sha256.Size]byte]string
+}
+
+func newContentIndex() *contentIndex {
+	return &contentIndex{files: make(map[[sha256.Size]byte]string)}
+}
+
+// claim returns the file first written with the content, or records the filename and returns false.
+func (c *contentIndex) claim(content []byte, filename string) (string, bool) {
+	hash := sha256.Sum256(content)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if first, ok := c.files[hash]; ok && first != filename {
+		return first, true
+	}
+	c.files[hash] = filename
+	return "", false
+}
+
+// writeDeduplicated writes a relative symlink to the first file with the same content instead of a copy.
+// It falls back to writing the file when the symlink can't be created, e.g. without permission on Windows.
+func writeDeduplicated(index *contentIndex, filename string, content []byte, perm os.FileMode) error {
+	first, duplicate := index.claim(content, filename)
+	if !duplicate {
+		return writeFileAtomic(filename, content, perm)
+	}
+
+	target, err := filepath.Rel(filepath.Dir(filename), first)
+	if err != nil {
+		return writeFileAtomic(filename, content, perm)
+	}
+	// a file of a previous dump would fail the symlink
+	if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Symlink(target, filename); err != nil {
+		return writeFileAtomic(filename, content, perm)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteDeduplicated(t *testing.T) {
+	dir := t.TempDir()
+	index := newContentIndex()
+
+	write := func(name, content string) string {
+		t.Helper()
+		filename := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(filename), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := writeDeduplicated(index, filename, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return filename
+	}
+
+	first := write(filepath.Join("ns1", "ca.crt"), "bundle")
+	duplicate := write(filepath.Join("ns2", "ca.crt"), "bundle")
+	other := write(filepath.Join("ns3", "ca.crt"), "other")
+
+	if info, err := os.Lstat(first); err != nil || info.Mode()&os.ModeSymlink != 0 {
+		t.Errorf("expected the first occurrence to be a regular file: %v", err)
+	}
+	if info, err := os.Lstat(other); err != nil || info.Mode()&os.ModeSymlink != 0 {
+		t.Errorf("expected different content to be a regular file: %v", err)
+	}
+
+	target, err := os.Readlink(duplicate)
+	if err != nil {
+		t.Fatalf("expected the duplicate to be a symlink: %v", err)
+	}
+	if want := filepath.Join("..", "ns1", "ca.crt"); target != want {
+		t.Errorf("got link target %q, want the relative path %q", target, want)
+	}
+	content, err := os.ReadFile(duplicate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "bundle" {
+		t.Errorf("got content %q through the symlink, want %q", content, "bundle")
+	}
+
+	// writing the duplicate again replaces the existing link
+	write(filepath.Join("ns2", "ca.crt"), "bundle")
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"sort"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const deprecationsFilename = "deprecations.yaml"
+
+type apiDeprecation struct {
+	// removedIn is the Kubernetes version which doesn't serve the API version anymore
+	removedIn string
+	// replacement is the API version to migrate to, empty when there is none
+	replacement string
+}
+
+// deprecatedAPIs are the deprecated API versions of the built-in resources,
+// based on https://kubernetes.io/docs/reference/using-api/deprecation-guide/
+var deprecatedAPIs = map[schema.GroupVersionResource]apiDeprecation{
+	{Group: "admissionregistration.k8s.io", Version: "v1beta1", Resource: "mutatingwebhookconfigurations"}:   {removedIn: "1.22", replacement: "admissionregistration.k8s.io/v1"},
+	{Group: "admissionregistration.k8s.io", Version: "v1beta1", Resource: "validatingwebhookconfigurations"}: {removedIn: "1.22", replacement: "admissionregistration.k8s.io/v1"},
+	{Group: "apiextensions.k8s.io", Version: "v1beta1", Resource: "customresourcedefinitions"}:               {removedIn: "1.22", replacement: "apiextensions.k8s.io/v1"},
+	{Group: "apiregistration.k8s.io", Version: "v1beta1", Resource: "apiservices"}:                           {removedIn: "1.22", replacement: "apiregistration.k8s.io/v1"},
+	{Group: "certificates.k8s.io", Version: "v1beta1", Resource: "certificatesigningrequests"}:               {removedIn: "1.22", replacement: "certificates.k8s.io/v1"},
+	{Group: "coordination.k8s.io", Version: "v1beta1", Resource: "leases"}:                                   {removedIn: "1.22", replacement: "coordination.k8s.io/v1"},
+	{Group: "extensions", Version: "v1beta1", Resource: "ingresses"}:                                         {removedIn: "1.22", replacement: "networking.k8s.io/v1"},
+	{Group: "networking.k8s.io", Version: "v1beta1", Resource: "ingresses"}:                                  {removedIn: "1.22", replacement: "networking.k8s.io/v1"},
+	{Group: "networking.k8s.io", Version: "v1beta1", Resource: "ingressclasses"}:                             {removedIn: "1.22", replacement: "networking.k8s.io/v1"},
+	{Group: "rbac.authorization.k8s.io", Version: "v1beta1", Resource: "clusterroles"}:                       {removedIn: "1.22", replacement: "rbac.authorization.k8s.io/v1"},
+	{Group: "rbac.authorization.k8s.io", Version: "v1beta1", Resource: "clusterrolebindings"}:                {removedIn: "1.22", replacement: "rbac.authorization.k8s.io/v1"},
+	{Group: "rbac.authorization.k8s.io", Version: "v1beta1", Resource: "roles"}:                              {removedIn: "1.22", replacement: "rbac.authorization.k8s.io/v1"},
+	{Group: "rbac.authorization.k8s.io", Version: "v1beta1", Resource: "rolebindings"}:                       {removedIn: "1.22", replacement: "rbac.authorization.k8s.io/v1"},
+	{Group: "scheduling.k8s.io", Version: "v1beta1", Resource: "priorityclasses"}:                            {removedIn: "1.22", replacement: "scheduling.k8s.io/v1"},
+	{Group: "storage.k8s.io", Version: "v1beta1", Resource: "csidrivers"}:                                    {removedIn: "1.22", replacement: "storage.k8s.io/v1"},
+	{Group: "storage.k8s.io", Version: "v1beta1", Resource: "csinodes"}:                                      {removedIn: "1.22", replacement: "storage.k8s.io/v1"},
+	{Group: "storage.k8s.io", Version: "v1beta1", Resource: "storageclasses"}:                                {removedIn: "1.22", replacement: "storage.k8s.io/v1"},
+	{Group: "storage.k8s.io", Version: "v1beta1", Resource: "volumeattachments"}:                             {removedIn: "1.22", replacement: "storage.k8s.io/v1"},
+	{Group: "autoscaling", Version: "v2beta1", Resource: "horizontalpodautoscalers"}:                         {removedIn: "1.25", replacement: "autoscaling/v2"},
+	{Group: "batch", Version: "v1beta1", Resource: "cronjobs"}:                                               {removedIn: "1.25", replacement: "batch/v1"},
+	{Group: "discovery.k8s.io", Version: "v1beta1", Resource: "endpointslices"}:                              {removedIn: "1.25", replacement: "discovery.k8s.io/v1"},
+	{Group: "events.k8s.io", Version: "v1beta1", Resource: "events"}:                                         {removedIn: "1.25", replacement: "events.k8s.io/v1"},
+	{Group: "node.k8s.io", Version: "v1beta1", Resource: "runtimeclasses"}:                                   {removedIn: "1.25", replacement: "node.k8s.io/v1"},
+	{Group: "policy", Version: "v1beta1", Resource: "poddisruptionbudgets"}:                                  {removedIn: "1.25", replacement: "policy/v1"},
+	{Group: "policy", Version: "v1beta1", Resource: "podsecuritypolicies"}:                                   {removedIn: "1.25"},
+	{Group: "autoscaling", Version: "v2beta2", Resource: "horizontalpodautoscalers"}:                         {removedIn: "1.26", replacement: "autoscaling/v2"},
+	{Group: "flowcontrol.apiserver.k8s.io", Version: "v1beta1", Resource: "flowschemas"}:                     {removedIn: "1.26", replacement: "flowcontrol.apiserver.k8s.io/v1"},
+	{Group: "flowcontrol.apiserver.k8s.io", Version: "v1beta1", Resource: "prioritylevelconfigurations"}:     {removedIn: "1.26", replacement: "flowcontrol.apiserver.k8s.io/v1"},
+	{Group: "storage.k8s.io", Version: "v1beta1", Resource: "csistoragecapacities"}:                          {removedIn: "1.27", replacement: "storage.k8s.io/v1"},
+	{Group: "flowcontrol.apiserver.k8s.io", Version: "v1beta2", Resource: "flowschemas"}:                     {removedIn: "1.29", replacement: "flowcontrol.apiserver.k8s.io/v1"},
+	{Group: "flowcontrol.apiserver.k8s.io", Version: "v1beta2", Resource: "prioritylevelconfigurations"}:     {removedIn: "1.29", replacement: "flowcontrol.apiserver.k8s.io/v1"},
+	{Group: "flowcontrol.apiserver.k8s.io", Version: "v1beta3", Resource: "flowschemas"}:                     {removedIn: "1.32", replacement: "flowcontrol.apiserver.k8s.io/v1"},
+	{Group: "flowcontrol.apiserver.k8s.io", Version: "v1beta3", Resource: "prioritylevelconfigurations"}:     {removedIn: "1.32", replacement: "flowcontrol.apiserver.k8s.io/v1"},
+}
+
+type deprecationEntry struct {
+	objectRef
+	RemovedIn   string `json:"removedIn"`
+	Replacement string `json:"replacement,omitempty"`
+}
+
+// deprecationsReport collects the objects served by deprecated API versions. It is safe for concurrent use.
+type deprecationsReport struct {
+	mu      sync.Mutex
+	entries []deprecationEntry
+}
+
+// add records the item when it was listed from a deprecated API version.
+func (r *deprecationsReport) add(gvr schema.GroupVersionResource, item unstructured.Unstructured) {
+	deprecation, ok := deprecatedAPIs[gvr]
+	if !ok {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.entries = append(r.entries, deprecationEntry{
+		objectRef:   newObjectRef(gvr, item),
+		RemovedIn:   deprecation.removedIn,
+		Replacement: deprecation.replacement,
+	})
+}
+
+func (r *deprecationsReport) len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.entries)
+}
+
+// write writes the report, but only when there were objects of deprecated API versions.
+func (r *deprecationsReport) write(outDir string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.entries) == 0 {
+		return nil
+	}
+
+	sort.Slice(r.entries, func(i, j int) bool {
+		return r.entries[i].less(r.entries[j].objectRef)
+	})
+
+	return writeReport(outDir, deprecationsFilename, r.entries)
+}
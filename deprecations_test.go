@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+)
+
+func TestDeprecationsReport(t *testing.T) {
+	newItem := func(kind, namespace, name string) unstructured.Unstructured {
+		item := unstructured.Unstructured{}
+		item.SetKind(kind)
+		item.SetNamespace(namespace)
+		item.SetName(name)
+		return item
+	}
+
+	report := &deprecationsReport{}
+	report.add(schema.GroupVersionResource{Group: "batch", Version: "v1", Resource: "cronjobs"}, newItem("CronJob", "ns", "current"))
+	report.add(schema.GroupVersionResource{Group: "batch", Version: "v1beta1", Resource: "cronjobs"}, newItem("CronJob", "ns", "b"))
+	report.add(schema.GroupVersionResource{Group: "batch", Version: "v1beta1", Resource: "cronjobs"}, newItem("CronJob", "ns", "a"))
+	report.add(schema.GroupVersionResource{Group: "policy", Version: "v1beta1", Resource: "podsecuritypolicies"}, newItem("PodSecurityPolicy", "", "restricted"))
+
+	if got := report.len(); got != 3 {
+		t.Fatalf("got %d entries, want 3", got)
+	}
+
+	outDir := t.TempDir()
+	if err := report.write(outDir); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(outDir, deprecationsFilename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []deprecationEntry
+	if err := yaml.Unmarshal(content, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []deprecationEntry{
+		{objectRef: objectRef{APIVersion: "batch/v1beta1", Kind: "CronJob", Namespace: "ns", Name: "a"}, RemovedIn: "1.25", Replacement: "batch/v1"},
+		{objectRef: objectRef{APIVersion: "batch/v1beta1", Kind: "CronJob", Namespace: "ns", Name: "b"}, RemovedIn: "1.25", Replacement: "batch/v1"},
+		{objectRef: objectRef{APIVersion: "policy/v1beta1", Kind: "PodSecurityPolicy", Name: "restricted"}, RemovedIn: "1.25"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("entry %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestDeprecationsReportEmpty(t *testing.T) {
+	outDir := t.TempDir()
+	if err := (&deprecationsReport{}).write(outDir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(outDir, deprecationsFilename)); !os.IsNotExist(err) {
+		t.Errorf("expected no report, got %v", err)
+	}
+}
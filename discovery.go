@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"text/tabwriter"
+
+	"golang.org/x/exp/slices"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/util/retry"
+)
+
+// groupVersionResources are the resources served by a group version.
+type groupVersionResources struct {
+	group     metav1.APIGroup
+	version   metav1.GroupVersionForDiscovery
+	resources []metav1.APIResource
+}
+
+// discoveredResource is a resource found during discovery.
+type discoveredResource struct {
+	gvr        schema.GroupVersionResource
+	namespaced bool
+}
+
+// retryDiscovery retries transient failures of the discovery call like the lists, permanent errors fail right away.
+func retryDiscovery(fn func() error) error {
+	return retry.OnError(listBackoff, isTransient, fn)
+}
+
+// discoverResources returns the resources of all group versions served by the API server.
+// When groupVersions is not empty, only the given group versions are discovered.
+// Group versions which fail to be discovered are logged, skipped and returned with their errors, unless strict is set.
+func discoverResources(discoveryClient discovery.DiscoveryInterface, groupVersions []string, strict bool) ([]groupVersionResources, map[string]error, error) {
+	var groups *metav1.APIGroupList
+	err := retryDiscovery(func() (err error) {
+		groups, err = discoveryClient.ServerGroups()
+		return err
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed getting server groups: %v", err)
+	}
+
+	var (
+		discovered []groupVersionResources
+		failed     = make(map[string]error)
+	)
+	for _, group := range groups.Groups {
+		for _, version := range group.Versions {
+			if len(groupVersions) > 0 && !slices.Contains(groupVersions, version.GroupVersion) {
+				continue
+			}
+
+			var resources *metav1.APIResourceList
+			err := retryDiscovery(func() (err error) {
+				resources, err = discoveryClient.ServerResourcesForGroupVersion(version.GroupVersion)
+				return err
+			})
+			if err != nil {
+				if strict {
+					return nil, nil, fmt.Errorf("failed getting resources for %q: %v", version.GroupVersion, err)
+				}
+				log.Printf("failed getting resources for %q: %v\n", version.GroupVersion, err)
+				failed[version.GroupVersion] = err
+				continue
+			}
+
+			discovered = append(discovered, groupVersionResources{
+				group:     group,
+				version:   version,
+				resources: resources.APIResources,
+			})
+		}
+	}
+	return discovered, failed, nil
+}
+
+// discoveredKinds maps the kinds of all discovered resources to their resource.
+func discoveredKinds(discovered []groupVersionResources) map[schema.GroupVersionKind]discoveredResource {
+	kinds := make(map[schema.GroupVersionKind]discoveredResource)
+	for _, gv := range discovered {
+		for _, res := range gv.resources {
+			// skip subresources
+			if strings.Contains(res.Name, "/") {
+				continue
+			}
+			gvk := schema.GroupVersionKind{Group: gv.group.Name, Version: gv.version.Version, Kind: res.Kind}
+			kinds[gvk] = discoveredResource{
+				gvr:        schema.GroupVersionResource{Group: gv.group.Name, Version: gv.version.Version, Resource: res.Name},
+				namespaced: res.Namespaced,
+			}
+		}
+	}
+	return kinds
+}
+
+// namespacedResources returns the discovered resources which are namespaced.
+func namespacedResources(discovered []groupVersionResources) map[schema.GroupVersionResource]bool {
+	namespaced := make(map[schema.GroupVersionResource]bool)
+	for _, gv := range discovered {
+		for _, res := range gv.resources {
+			if res.Namespaced {
+				namespaced[schema.GroupVersionResource{Group: gv.group.Name, Version: gv.version.Version, Resource: res.Name}] = true
+			}
+		}
+	}
+	return namespaced
+}
+
+// printResources writes a table of the discovered resources.
+func printResources(w io.Writer, discovered []groupVersionResources) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tGROUP\tVERSION\tNAMESPACED\tVERBS\tSHORTNAMES")
+	for _, gv := range discovered {
+		for _, res := range gv.resources {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%t\t%s\t%s\n",
+				res.Name,
+				gv.group.Name,
+				gv.version.Version,
+				res.Namespaced,
+				strings.Join(res.Verbs, ","),
+				strings.Join(res.ShortNames, ","),
+			)
+		}
+	}
+	return tw.Flush()
+}
+
+const apiResourcesFilename = "apiresources.yaml"
+
+type apiResourcesGroup struct {
+	Name             string                `json:"name"`
+	PreferredVersion string                `json:"preferredVersion"`
+	Versions         []apiResourcesVersion `json:"versions"`
+}
+
+type apiResourcesVersion struct {
+	Version   string             `json:"version"`
+	Resources []apiResourceEntry `json:"resources"`
+}
+
+type apiResourceEntry struct {
+	Name       string   `json:"name"`
+	Kind       string   `json:"kind"`
+	Namespaced bool     `json:"namespaced"`
+	Verbs      []string `json:"verbs"`
+	ShortNames []string `json:"shortNames,omitempty"`
+}
+
+// apiResourcesSnapshot groups the discovered resources, including subresources, by group and version.
+func apiResourcesSnapshot(discovered []groupVersionResources) []apiResourcesGroup {
+	var groups []apiResourcesGroup
+	for _, gv := range discovered {
+		if len(groups) == 0 || groups[len(groups)-1].Name != gv.group.Name {
+			groups = append(groups, apiResourcesGroup{Name: gv.group.Name, PreferredVersion: gv.group.PreferredVersion.Version})
+		}
+		group := &groups[len(groups)-1]
+
+		version := apiResourcesVersion{Version: gv.version.Version, Resources: []apiResourceEntry{}}
+		for _, res := range gv.resources {
+			version.Resources = append(version.Resources, apiResourceEntry{
+				Name:       res.Name,
+				Kind:       res.Kind,
+				Namespaced: res.Namespaced,
+				Verbs:      res.Verbs,
+				ShortNames: res.ShortNames,
+			})
+		}
+		group.Versions = append(group.Versions, version)
+	}
+	return groups
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+)
+
+func TestAPIResourcesSnapshot(t *testing.T) {
+	apps := metav1.APIGroup{Name: "apps", PreferredVersion: metav1.GroupVersionForDiscovery{GroupVersion: "apps/v1", Version: "v1"}}
+	discovered := []groupVersionResources{
+		{
+			group:   metav1.APIGroup{PreferredVersion: metav1.GroupVersionForDiscovery{GroupVersion: "v1", Version: "v1"}},
+			version: metav1.GroupVersionForDiscovery{GroupVersion: "v1", Version: "v1"},
+			resources: []metav1.APIResource{
+				{Name: "pods", Kind: "Pod", Namespaced: true, Verbs: metav1.Verbs{"get", "list"}, ShortNames: []string{"po"}},
+				{Name: "pods/log", Kind: "Pod", Namespaced: true, Verbs: metav1.Verbs{"get"}},
+			},
+		},
+		{
+			group:     apps,
+			version:   metav1.GroupVersionForDiscovery{GroupVersion: "apps/v1", Version: "v1"},
+			resources: []metav1.APIResource{{Name: "deployments", Kind: "Deployment", Namespaced: true, Verbs: metav1.Verbs{"list"}}},
+		},
+		{
+			group:   apps,
+			version: metav1.GroupVersionForDiscovery{GroupVersion: "apps/v1beta1", Version: "v1beta1"},
+		},
+	}
+
+	want := []apiResourcesGroup{
+		{
+			Name:             "",
+			PreferredVersion: "v1",
+			Versions: []apiResourcesVersion{{Version: "v1", Resources: []apiResourceEntry{
+				{Name: "pods", Kind: "Pod", Namespaced: true, Verbs: []string{"get", "list"}, ShortNames: []string{"po"}},
+				{Name: "pods/log", Kind: "Pod", Namespaced: true, Verbs: []string{"get"}},
+			}}},
+		},
+		{
+			Name:             "apps",
+			PreferredVersion: "v1",
+			Versions: []apiResourcesVersion{
+				{Version: "v1", Resources: []apiResourceEntry{{Name: "deployments", Kind: "Deployment", Namespaced: true, Verbs: []string{"list"}}}},
+				{Version: "v1beta1", Resources: []apiResourceEntry{}},
+			},
+		},
+	}
+
+	if got := apiResourcesSnapshot(discovered); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestNamespacedResources(t *testing.T) {
+	discovered := []groupVersionResources{
+		{
+			version: metav1.GroupVersionForDiscovery{GroupVersion: "v1", Version: "v1"},
+			resources: []metav1.APIResource{
+				{Name: "pods", Kind: "Pod", Namespaced: true},
+				{Name: "nodes", Kind: "Node"},
+			},
+		},
+		{
+			group:     metav1.APIGroup{Name: "apps"},
+			version:   metav1.GroupVersionForDiscovery{GroupVersion: "apps/v1", Version: "v1"},
+			resources: []metav1.APIResource{{Name: "deployments", Kind: "Deployment", Namespaced: true}},
+		},
+	}
+
+	want := map[schema.GroupVersionResource]bool{
+		{Version: "v1", Resource: "pods"}:                       true,
+		{Group: "apps", Version: "v1", Resource: "deployments"}: true,
+	}
+	if got := namespacedResources(discovered); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+// brokenDiscovery serves 'apps/v1', 'metrics.k8s.io/v1beta1' which is unavailable once and 'denied.example.com/v1' which is forbidden.
+type brokenDiscovery struct {
+	discovery.DiscoveryInterface
+	calls map[string]int
+}
+
+func (d *brokenDiscovery) ServerGroups() (*metav1.APIGroupList, error) {
+	group := func(name, version string) metav1.APIGroup {
+		gv := metav1.GroupVersionForDiscovery{GroupVersion: name + "/" + version, Version: version}
+		return metav1.APIGroup{Name: name, Versions: []metav1.GroupVersionForDiscovery{gv}, PreferredVersion: gv}
+	}
+	return &metav1.APIGroupList{Groups: []metav1.APIGroup{group("apps", "v1"), group("metrics.k8s.io", "v1beta1"), group("denied.example.com", "v1")}}, nil
+}
+
+func (d *brokenDiscovery) ServerResourcesForGroupVersion(groupVersion string) (*metav1.APIResourceList, error) {
+	d.calls[groupVersion]++
+	switch {
+	case groupVersion == "denied.example.com/v1":
+		return nil, apierrors.NewForbidden(schema.GroupResource{}, "", errors.New("denied"))
+	case groupVersion == "metrics.k8s.io/v1beta1" && d.calls[groupVersion] == 1:
+		return nil, apierrors.NewServiceUnavailable("unavailable")
+	}
+	return &metav1.APIResourceList{GroupVersion: groupVersion, APIResources: []metav1.APIResource{{Name: "things", Kind: "Thing"}}}, nil
+}
+
+func TestDiscoverResources(t *testing.T) {
+	backoff := listBackoff
+	listBackoff.Duration = 0
+	defer func() { listBackoff = backoff }()
+
+	broken := &brokenDiscovery{calls: make(map[string]int)}
+	discovered, failed, err := discoverResources(broken, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, gv := range discovered {
+		got = append(got, gv.version.GroupVersion)
+	}
+	if want := []string{"apps/v1", "metrics.k8s.io/v1beta1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if len(failed) != 1 || !apierrors.IsForbidden(failed["denied.example.com/v1"]) {
+		t.Errorf("got failures %v, want the forbidden group version", failed)
+	}
+	if want := map[string]int{"apps/v1": 1, "metrics.k8s.io/v1beta1": 2, "denied.example.com/v1": 1}; !reflect.DeepEqual(broken.calls, want) {
+		t.Errorf("got calls %v, want only the transient error to be retried: %v", broken.calls, want)
+	}
+
+	if _, _, err := discoverResources(&brokenDiscovery{calls: make(map[string]int)}, nil, true); err == nil {
+		t.Error("expected strict discovery to fail")
+	}
+	if _, _, err := discoverResources(&brokenDiscovery{calls: make(map[string]int)}, []string{"apps/v1"}, true); err != nil {
+		t.Errorf("expected strict discovery of a working group version to succeed, got %v", err)
+	}
+}
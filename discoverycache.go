@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/discovery"
+)
+
+// cachedDiscovery serves the server groups and the resources of the group versions from the files
+// in the directory while they are younger than the TTL, and discovers and caches them otherwise.
+// All other calls are passed to the discovery client.
+type cachedDiscovery struct {
+	discovery.DiscoveryInterface
+	dir string
+	ttl time.Duration
+	// failed logs failing to cache only once, e.g. for a read-only home dir
+	failed sync.Once
+}
+
+// discoveryCacheDir returns the cache directory of the server. The server version is part of the
+// directory, so the cache of the previous version isn't used after an upgrade.
+func discoveryCacheDir(homeDir, host, serverVersion string) string {
+	return filepath.Join(homeDir, ".kube", "cache", "kubedump", "discovery", sanitizePathComponent(host), sanitizePathComponent(serverVersion))
+}
+
+func newCachedDiscovery(client discovery.DiscoveryInterface, dir string, ttl time.Duration) *cachedDiscovery {
+	return &cachedDiscovery{DiscoveryInterface: client, dir: dir, ttl: ttl}
+}
+
+func (c *cachedDiscovery) ServerGroups() (*metav1.APIGroupList, error) {
+	return readThrough(c, filepath.Join(c.dir, "servergroups.json"), c.DiscoveryInterface.ServerGroups)
+}
+
+func (c *cachedDiscovery) ServerResourcesForGroupVersion(groupVersion string) (*metav1.APIResourceList, error) {
+	return readThrough(c, filepath.Join(c.dir, filepath.FromSlash(groupVersion), "serverresources.json"), func() (*metav1.APIResourceList, error) {
+		return c.DiscoveryInterface.ServerResourcesForGroupVersion(groupVersion)
+	})
+}
+
+// readThrough returns the cached result of the file while it's fresh, otherwise the result of discover is cached.
+// Failing to cache the result is only logged, as the discovery still succeeded.
+func readThrough[T any](c *cachedDiscovery, filename string, discover func() (*T, error)) (*T, error) {
+	if info, err := os.Stat(filename); err == nil && time.Since(info.ModTime()) < c.ttl {
+		if content, err := os.ReadFile(filename); err == nil {
+			cached := new(T)
+			if err := json.Unmarshal(content, cached); err == nil {
+				return cached, nil
+			}
+		}
+	}
+
+	result, err := discover()
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := json.Marshal(result)
+	if err == nil {
+		if err = os.MkdirAll(filepath.Dir(filename), 0o750); err == nil {
+			err = writeFileAtomic(filename, content, 0o600)
+		}
+	}
+	if err != nil {
+		c.failed.Do(func() {
+			log.Printf("failed caching discovery in %q: %v\n", filename, err)
+		})
+	}
+	return result, nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/discovery"
+)
+
+// countingDiscovery serves a single group version and counts the discovery calls.
+type countingDiscovery struct {
+	discovery.DiscoveryInterface
+	calls int
+}
+
+func (d *countingDiscovery) ServerGroups() (*metav1.APIGroupList, error) {
+	d.calls++
+	version := metav1.GroupVersionForDiscovery{GroupVersion: "apps/v1", Version: "v1"}
+	return &metav1.APIGroupList{Groups: []metav1.APIGroup{{Name: "apps", Versions: []metav1.GroupVersionForDiscovery{version}, PreferredVersion: version}}}, nil
+}
+
+func (d *countingDiscovery) ServerResourcesForGroupVersion(groupVersion string) (*metav1.APIResourceList, error) {
+	d.calls++
+	return &metav1.APIResourceList{GroupVersion: groupVersion, APIResources: []metav1.APIResource{{Name: "deployments", Kind: "Deployment", Namespaced: true}}}, nil
+}
+
+func TestCachedDiscovery(t *testing.T) {
+	live := &countingDiscovery{}
+	dir := discoveryCacheDir(t.TempDir(), "https://example.com:6443", "v1.27.2")
+	cached := newCachedDiscovery(live, dir, time.Hour)
+
+	first, _, err := discoverResources(cached, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if live.calls != 2 {
+		t.Fatalf("got %d discovery calls, want 2", live.calls)
+	}
+
+	second, _, err := discoverResources(cached, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if live.calls != 2 {
+		t.Errorf("got %d discovery calls, want the cache to be used", live.calls)
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("got %v from the cache, want %v", second, first)
+	}
+
+	// expire the cache
+	expired := time.Now().Add(-2 * time.Hour)
+	if err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		return os.Chtimes(path, expired, expired)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := discoverResources(cached, nil, true); err != nil {
+		t.Fatal(err)
+	}
+	if live.calls != 4 {
+		t.Errorf("got %d discovery calls, want the expired cache to be refreshed", live.calls)
+	}
+}
+
+func TestDiscoveryCacheDir(t *testing.T) {
+	a := discoveryCacheDir("/home", "https://example.com:6443", "v1.27.2")
+	b := discoveryCacheDir("/home", "https://example.com:6443", "v1.28.0")
+	if a == b {
+		t.Errorf("got the same cache dir %q for different server versions", a)
+	}
+	if want := filepath.Join("/home", ".kube", "cache", "kubedump", "discovery", "https___example.com_6443", "v1.27.2"); a != want {
+		t.Errorf("got %q, want %q", a, want)
+	}
+}
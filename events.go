@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	"golang.org/x/exp/slices"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+)
+
+const allKinds = "*"
+
+var eventsGVR = schema.GroupVersionResource{Version: "v1", Resource: "events"}
+
+// eventTracker records the UIDs of dumped objects of the given kinds,
+// so only their events are dumped. It is safe for concurrent use.
+type eventTracker struct {
+	kinds []string
+
+	mu   sync.Mutex
+	uids map[types.UID]bool
+	// dumped are the events which were already dumped as objects of the events resource
+	dumped map[types.UID]bool
+}
+
+func newEventTracker(kinds []string) *eventTracker {
+	return &eventTracker{kinds: kinds, uids: make(map[types.UID]bool), dumped: make(map[types.UID]bool)}
+}
+
+func (t *eventTracker) track(gvr schema.GroupVersionResource, item unstructured.Unstructured) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if gvr == eventsGVR {
+		t.dumped[item.GetUID()] = true
+	}
+	if slices.Contains(t.kinds, allKinds) || slices.Contains(t.kinds, item.GetKind()) {
+		t.uids[item.GetUID()] = true
+	}
+}
+
+// involves reports whether the event is about a tracked object.
+func (t *eventTracker) involves(event unstructured.Unstructured) bool {
+	uid, _, _ := unstructured.NestedString(event.Object, "involvedObject", "uid")
+	if uid == "" {
+		return false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.uids[types.UID(uid)]
+}
+
+// includeEvents lists the events of all namespaces and passes the events of tracked objects to write,
+// unless they were already dumped. Failed writes are logged and the remaining events are still written.
+// It returns the number of written events.
+func includeEvents(ctx context.Context, dynamicClient dynamic.Interface, tracker *eventTracker, write func(schema.GroupVersionResource, unstructured.Unstructured) error) (uint64, error) {
+	events, err := dynamicClient.Resource(eventsGVR).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return 0, fmt.Errorf("failed listing events: %v", err)
+	}
+
+	var written uint64
+	for _, event := range events.Items {
+		if !tracker.involves(event) || tracker.wasDumped(event) {
+			continue
+		}
+		if err := write(eventsGVR, event); err != nil {
+			log.Printf("failed writing event %v/%v: %v\n", event.GetNamespace(), event.GetName(), err)
+			continue
+		}
+		written++
+	}
+	return written, nil
+}
+
+func (t *eventTracker) wasDumped(event unstructured.Unstructured) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.dumped[event.GetUID()]
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestEventTracker(t *testing.T) {
+	newObject := func(kind, uid string) unstructured.Unstructured {
+		item := unstructured.Unstructured{}
+		item.SetKind(kind)
+		item.SetUID(types.UID(uid))
+		return item
+	}
+	newEvent := func(uid string) unstructured.Unstructured {
+		return unstructured.Unstructured{Object: map[string]interface{}{
+			"involvedObject": map[string]interface{}{"uid": uid},
+		}}
+	}
+
+	testCases := []struct {
+		description string
+		kinds       []string
+		event       unstructured.Unstructured
+		want        bool
+	}{
+		{description: "tracked kind", kinds: []string{"Pod"}, event: newEvent("pod-uid"), want: true},
+		{description: "untracked kind", kinds: []string{"Pod"}, event: newEvent("deployment-uid"), want: false},
+		{description: "all kinds", kinds: []string{allKinds}, event: newEvent("deployment-uid"), want: true},
+		{description: "not dumped", kinds: []string{allKinds}, event: newEvent("other-uid"), want: false},
+		{description: "no involved object", kinds: []string{allKinds}, event: unstructured.Unstructured{Object: map[string]interface{}{}}, want: false},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.description, func(t *testing.T) {
+			tracker := newEventTracker(tt.kinds)
+			tracker.track(schema.GroupVersionResource{Version: "v1", Resource: "pods"}, newObject("Pod", "pod-uid"))
+			tracker.track(schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}, newObject("Deployment", "deployment-uid"))
+
+			if got := tracker.involves(tt.event); got != tt.want {
+				t.Errorf("involves() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIncludeEvents(t *testing.T) {
+	newEvent := func(name, involvedUID string) unstructured.Unstructured {
+		event := unstructured.Unstructured{Object: map[string]interface{}{
+			"involvedObject": map[string]interface{}{"uid": involvedUID},
+		}}
+		event.SetNamespace("ns")
+		event.SetName(name)
+		event.SetUID(types.UID(name + "-uid"))
+		return event
+	}
+
+	dumped := newEvent("dumped", "pod-uid")
+	client := &fakeDynamicClient{resource: &listedResource{items: []unstructured.Unstructured{
+		dumped,
+		newEvent("failing", "pod-uid"),
+		newEvent("started", "pod-uid"),
+		newEvent("other", "other-uid"),
+	}}}
+
+	pod := unstructured.Unstructured{}
+	pod.SetKind("Pod")
+	pod.SetUID("pod-uid")
+
+	tracker := newEventTracker([]string{"Pod"})
+	tracker.track(schema.GroupVersionResource{Version: "v1", Resource: "pods"}, pod)
+	tracker.track(eventsGVR, dumped)
+
+	var got []string
+	written, err := includeEvents(context.Background(), client, tracker, func(_ schema.GroupVersionResource, event unstructured.Unstructured) error {
+		if event.GetName() == "failing" {
+			return errors.New("disk full")
+		}
+		got = append(got, event.GetName())
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if written != 1 || len(got) != 1 || got[0] != "started" {
+		t.Errorf("got %d written events %v, want only %q after the failed one", written, got, "started")
+	}
+}
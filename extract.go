@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"golang.org/x/exp/slices"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	formatYAML = "yaml"
+	// formatExtract writes each data key of ConfigMaps and Secrets as a file instead of the manifest
+	formatExtract = "extract"
+)
+
+// extractableResources are the resources whose data keys can be extracted.
+var extractableResources = []string{"configmaps", "secrets"}
+
+// parseFormatOverrides parses values like 'configmaps=extract' into a map from the resource,
+// optionally with its group like 'deployments.apps', to the format.
+func parseFormatOverrides(values []string) (map[string]string, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	overrides := make(map[string]string)
+	for _, value := range values {
+		resource, format, ok := strings.Cut(value, "=")
+		if !ok || resource == "" {
+			return nil, fmt.Errorf("invalid format override %q, expected 'resource=format'", value)
+		}
+		resource = strings.ToLower(resource)
+		switch format {
+		case formatYAML:
+		case formatExtract:
+			if !slices.Contains(extractableResources, resource) {
+				return nil, fmt.Errorf("format %q is only supported for %v, not %q", formatExtract, strings.Join(extractableResources, ", "), resource)
+			}
+		default:
+			return nil, fmt.Errorf("invalid format %q of %q, must be %q or %q", format, resource, formatYAML, formatExtract)
+		}
+		overrides[resource] = format
+	}
+	return overrides, nil
+}
+
+// outputFormat returns the format of the resource, the overrides are looked up with and without the group.
+func outputFormat(overrides map[string]string, gvr schema.GroupVersionResource) string {
+	if format, ok := overrides[resourceAndGroupName(gvr)]; ok {
+		return format
+	}
+	if format, ok := overrides[gvr.Resource]; ok && gvr.Group == "" {
+		return format
+	}
+	return formatYAML
+}
+
+// isExtractable reports whether the data keys of the resource can be extracted.
+func isExtractable(gvr schema.GroupVersionResource) bool {
+	return gvr.Group == "" && slices.Contains(extractableResources, gvr.Resource)
+}
+
+// extractData returns a file below the directory of the object for each data key of a ConfigMap or Secret.
+// Base64 encoded values are decoded, redacted values and values which aren't valid base64 are written as is.
+// Keys which aren't safe filenames are skipped.
+func extractData(item unstructured.Unstructured) []outputFile {
+	var files []outputFile
+	for _, field := range []string{"data", "binaryData", "stringData"} {
+		data, _, _ := unstructured.NestedMap(item.Object, field)
+
+		keys := make([]string, 0, len(data))
+		for key := range data {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			value, ok := data[key].(string)
+			if !ok {
+				continue
+			}
+			if key == "." || key == ".." || strings.ContainsAny(key, unsafeFilenameChars) {
+				log.Printf("skipping extraction of key %q of %v %q in namespace %q, it isn't a safe filename\n", key, item.GetKind(), item.GetName(), item.GetNamespace())
+				continue
+			}
+
+			content := []byte(value)
+			if value != redactedPlaceholder && (field == "binaryData" || (field == "data" && isSecret(item))) {
+				if decoded, err := base64.StdEncoding.DecodeString(value); err == nil {
+					content = decoded
+				}
+			}
+			files = append(files, outputFile{suffix: "/" + key, content: content})
+		}
+	}
+	return files
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestParseFormatOverrides(t *testing.T) {
+	tests := []struct {
+		values  []string
+		want    map[string]string
+		wantErr bool
+	}{
+		{values: nil, want: nil},
+		{values: []string{"ConfigMaps=extract", "deployments.apps=yaml"}, want: map[string]string{"configmaps": formatExtract, "deployments.apps": formatYAML}},
+		{values: []string{"configmaps"}, wantErr: true},
+		{values: []string{"configmaps=xml"}, wantErr: true},
+		{values: []string{"deployments.apps=extract"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseFormatOverrides(tt.values)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("parseFormatOverrides(%v) error = %v, wantErr %v", tt.values, err, tt.wantErr)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseFormatOverrides(%v) = %v, want %v", tt.values, got, tt.want)
+		}
+	}
+}
+
+func TestOutputFormat(t *testing.T) {
+	overrides := map[string]string{"configmaps": formatExtract, "widgets.example.com": formatExtract}
+
+	tests := []struct {
+		gvr  schema.GroupVersionResource
+		want string
+	}{
+		{gvr: schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}, want: formatExtract},
+		{gvr: schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "configmaps"}, want: formatYAML},
+		{gvr: schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "widgets"}, want: formatExtract},
+		{gvr: schema.GroupVersionResource{Version: "v1", Resource: "secrets"}, want: formatYAML},
+	}
+
+	for _, tt := range tests {
+		if got := outputFormat(overrides, tt.gvr); got != tt.want {
+			t.Errorf("outputFormat(%v) = %q, want %q", tt.gvr, got, tt.want)
+		}
+	}
+}
+
+func TestExtractData(t *testing.T) {
+	configMap := unstructured.Unstructured{Object: map[string]interface{}{
+		"kind":       "ConfigMap",
+		"data":       map[string]interface{}{"nginx.conf": "worker_processes 1;\n", "../escape": "x"},
+		"binaryData": map[string]interface{}{"logo.png": "iVBORw=="},
+	}}
+	want := []outputFile{
+		{suffix: "/nginx.conf", content: []byte("worker_processes 1;\n")},
+		{suffix: "/logo.png", content: []byte{0x89, 'P', 'N', 'G'}},
+	}
+	if got := extractData(configMap); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	secret := unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"data":       map[string]interface{}{"password": "aHVudGVyMg==", "token": redactedPlaceholder},
+		"stringData": map[string]interface{}{"username": "admin"},
+	}}
+	want = []outputFile{
+		{suffix: "/password", content: []byte("hunter2")},
+		{suffix: "/token", content: []byte(redactedPlaceholder)},
+		{suffix: "/username", content: []byte("admin")},
+	}
+	if got := extractData(secret); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestWriteYAMLExtract(t *testing.T) {
+	item := unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": "web", "namespace": "ns"},
+		"data":       map[string]interface{}{"nginx.conf": "worker_processes 1;\n"},
+	}}
+
+	opts := writeOptions{outDir: t.TempDir(), formatOverrides: map[string]string{"configmaps": formatExtract}}
+	if err := writeYAML(opts, schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}, item); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := filepath.Join(opts.outDir, "namespaced", "ns", "configmaps")
+	content, err := os.ReadFile(filepath.Join(dir, "web", "nginx.conf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "worker_processes 1;\n" {
+		t.Errorf("got %q, want the data of the key", content)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "web.yaml")); !os.IsNotExist(err) {
+		t.Errorf("expected no manifest when extracting, got %v", err)
+	}
+}
+
+func TestWriteYAMLExtractData(t *testing.T) {
+	item := unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata":   map[string]interface{}{"name": "tls", "namespace": "ns"},
+		"data":       map[string]interface{}{"tls.crt": "Y2VydA=="},
+	}}
+
+	opts := writeOptions{outDir: t.TempDir(), extractData: true}
+	if err := writeYAML(opts, schema.GroupVersionResource{Version: "v1", Resource: "secrets"}, item); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := filepath.Join(opts.outDir, "namespaced", "ns", "secrets")
+	content, err := os.ReadFile(filepath.Join(dir, "tls", "tls.crt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "cert" {
+		t.Errorf("got %q, want the decoded data of the key", content)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "tls.yaml")); err != nil {
+		t.Errorf("expected the manifest additionally to the extracted data: %v", err)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"sync"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// namespaceLimiter limits the number of concurrent writes per namespace,
+// so a large namespace can't occupy all writers. It is safe for concurrent use.
+type namespaceLimiter struct {
+	limit int
+
+	mu     sync.Mutex
+	guards map[string]chan struct{}
+}
+
+func newNamespaceLimiter(limit int) *namespaceLimiter {
+	return &namespaceLimiter{limit: limit, guards: make(map[string]chan struct{})}
+}
+
+func (l *namespaceLimiter) guard(namespace string) chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	guard, ok := l.guards[namespace]
+	if !ok {
+		guard = make(chan struct{}, l.limit)
+		l.guards[namespace] = guard
+	}
+	return guard
+}
+
+// acquire blocks while the namespace already has the maximum number of concurrent writes.
+func (l *namespaceLimiter) acquire(namespace string) {
+	l.guard(namespace) <- struct{}{}
+}
+
+func (l *namespaceLimiter) release(namespace string) {
+	<-l.guard(namespace)
+}
+
+// interleaveByNamespace orders the items round-robin by namespace, keeping the order within each namespace.
+// The namespaces are taken in the order of their first item.
+func interleaveByNamespace(items []unstructured.Unstructured) []unstructured.Unstructured {
+	var (
+		namespaces []string
+		groups     = make(map[string][]unstructured.Unstructured)
+	)
+	for _, item := range items {
+		namespace := item.GetNamespace()
+		if _, ok := groups[namespace]; !ok {
+			namespaces = append(namespaces, namespace)
+		}
+		groups[namespace] = append(groups[namespace], item)
+	}
+
+	interleaved := make([]unstructured.Unstructured, 0, len(items))
+	for len(interleaved) < len(items) {
+		for _, namespace := range namespaces {
+			if group := groups[namespace]; len(group) > 0 {
+				interleaved = append(interleaved, group[0])
+				groups[namespace] = group[1:]
+			}
+		}
+	}
+	return interleaved
+}
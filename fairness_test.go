@@ -0,0 +1,54 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestNamespaceLimiter(t *testing.T) {
+	limiter := newNamespaceLimiter(1)
+	limiter.acquire("a")
+
+	// other namespaces are not affected
+	limiter.acquire("b")
+
+	acquired := make(chan struct{})
+	go func() {
+		limiter.acquire("a")
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("acquire() should block while the namespace is at its limit")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	limiter.release("a")
+	<-acquired
+}
+
+func TestInterleaveByNamespace(t *testing.T) {
+	var items []unstructured.Unstructured
+	for _, id := range []string{"a/1", "a/2", "a/3", "b/1", "", "b/2"} {
+		item := unstructured.Unstructured{}
+		if namespace, name, ok := strings.Cut(id, "/"); ok {
+			item.SetNamespace(namespace)
+			item.SetName(name)
+		}
+		items = append(items, item)
+	}
+
+	var got []string
+	for _, item := range interleaveByNamespace(items) {
+		got = append(got, item.GetNamespace()+"/"+item.GetName())
+	}
+
+	want := "a/1 b/1 / a/2 b/2 a/3"
+	if strings.Join(got, " ") != want {
+		t.Errorf("got %q, want %q", strings.Join(got, " "), want)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+var namespacesGVR = schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}
+
+// maxFallbackNamespaces caps the requests of listing a resource per namespace, which would otherwise add
+// one request per namespace for each resource a namespace-scoped identity can't list cluster-wide.
+const maxFallbackNamespaces = 100
+
+// requiresNamespace reports whether listing a namespaced resource cluster-wide failed in a way
+// that listing it per namespace might not, e.g. when the identity may only list it in some namespaces.
+func requiresNamespace(err error) bool {
+	return apierrors.IsForbidden(err) || apierrors.IsMethodNotSupported(err)
+}
+
+// listWithFallback lists the resource cluster-wide. A namespaced resource which can't be listed cluster-wide
+// is listed in each of the namespaces instead, skipping those where it's forbidden or not found as well.
+func listWithFallback(ctx context.Context, client dynamic.NamespaceableResourceInterface, gvr schema.GroupVersionResource, namespaced bool, namespaces *namespaceNames, opts listOptions, guard chan struct{}, out *logger) (*unstructured.UnstructuredList, error) {
+	list, err := listResource(ctx, client, opts)
+	if err == nil || !namespaced || !requiresNamespace(err) {
+		return list, err
+	}
+
+	names, nsErr := namespaces.get()
+	if nsErr != nil {
+		log.Printf("failed getting namespaces for listing %v per namespace: %v\n", gvr.String(), nsErr)
+		return nil, err
+	}
+	if len(names) > maxFallbackNamespaces {
+		out.infof("listing %v cluster-wide failed, not listing it in each of %d namespaces (more than %d), restrict them with -namespaces: %v\n", gvr.String(), len(names), maxFallbackNamespaces, err)
+		return nil, err
+	}
+
+	out.infof("listing %v cluster-wide failed, listing it in each of %d namespaces: %v\n", gvr.String(), len(names), err)
+	return listNamespaces(ctx, client, names, opts, guard, func(namespace string, err error) bool {
+		if !apierrors.IsForbidden(err) && !apierrors.IsNotFound(err) {
+			return false
+		}
+		out.debugf("skipping %v in namespace %q: %v\n", gvr.String(), namespace, err)
+		return true
+	})
+}
+
+// namespaceNames lists the names of the namespaces once, when they are needed first. It is safe for concurrent use.
+type namespaceNames struct {
+	dynamicClient dynamic.Interface
+	// want are used instead of listing the namespaces, unless empty
+	want  []string
+	once  sync.Once
+	names []string
+	err   error
+}
+
+// get doesn't take the context of the caller, as its cancellation would be cached as the error for all callers.
+func (n *namespaceNames) get() ([]string, error) {
+	n.once.Do(func() {
+		if len(n.want) > 0 && n.want[0] != "" {
+			n.names = n.want
+			return
+		}
+
+		list, err := listResource(context.Background(), n.dynamicClient.Resource(namespacesGVR), listOptions{})
+		if err != nil {
+			n.err = err
+			return
+		}
+		for _, item := range list.Items {
+			n.names = append(n.names, item.GetName())
+		}
+	})
+	return n.names, n.err
+}
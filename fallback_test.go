@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"strconv"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// fakeDynamicClient serves the same resource for all group version resources.
+type fakeDynamicClient struct {
+	dynamic.Interface
+	resource dynamic.NamespaceableResourceInterface
+	requests int
+}
+
+func (c *fakeDynamicClient) Resource(schema.GroupVersionResource) dynamic.NamespaceableResourceInterface {
+	c.requests++
+	return c.resource
+}
+
+func TestRequiresNamespace(t *testing.T) {
+	gr := schema.GroupResource{Resource: "foos"}
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{err: apierrors.NewForbidden(gr, "", errors.New("cluster-wide denied")), want: true},
+		{err: apierrors.NewMethodNotSupported(gr, "list"), want: true},
+		{err: apierrors.NewNotFound(gr, ""), want: false},
+		{err: apierrors.NewBadRequest("invalid"), want: false},
+		{err: apierrors.NewServiceUnavailable("overloaded"), want: false},
+		{err: errors.New("connection refused"), want: false},
+	}
+
+	for _, tt := range tests {
+		if got := requiresNamespace(tt.err); got != tt.want {
+			t.Errorf("requiresNamespace(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestNamespaceNames(t *testing.T) {
+	client := &fakeDynamicClient{resource: &namespacedResource{namespaces: 1, objects: 3}}
+
+	names := &namespaceNames{dynamicClient: client, want: []string{""}}
+	for i := 0; i < 2; i++ {
+		got, err := names.get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []string{"0", "1", "2"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+	if client.requests != 1 {
+		t.Errorf("got %d requests, want the namespaces to be listed once", client.requests)
+	}
+
+	wanted := &namespaceNames{dynamicClient: client, want: []string{"ns1", "ns2"}}
+	got, err := wanted.get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"ns1", "ns2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want the wanted namespaces %v", got, want)
+	}
+}
+
+func TestListWithFallback(t *testing.T) {
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	denied := apierrors.NewForbidden(gvr.GroupResource(), "", errors.New("cluster-wide denied"))
+	out := newLogger(io.Discard, 0)
+	list := func(client *namespacedResource, namespaced bool, namespaces ...string) ([]string, error) {
+		names := &namespaceNames{want: namespaces}
+		got, err := listWithFallback(context.Background(), client, gvr, namespaced, names, listOptions{}, make(chan struct{}, 2), out)
+		if err != nil {
+			return nil, err
+		}
+		var items []string
+		for _, item := range got.Items {
+			items = append(items, item.GetNamespace()+"/"+item.GetName())
+		}
+		return items, nil
+	}
+
+	got, err := list(&namespacedResource{namespaces: 2, objects: 1}, true, "ns0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"ns0/0", "ns1/0"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want the cluster-wide list %v", got, want)
+	}
+
+	// the forbidden namespace is skipped
+	client := &namespacedResource{namespaces: 3, objects: 1, fail: "ns1", clusterWideErr: denied}
+	got, err = list(client, true, "ns0", "ns1", "ns2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"ns0/0", "ns2/0"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if _, err := list(client, true, "ns1"); err == nil {
+		t.Error("expected an error when the resource can't be listed in any namespace")
+	}
+	if _, err := list(client, false, "ns0"); !apierrors.IsForbidden(err) {
+		t.Errorf("got %v, want the cluster-wide error for a cluster-scoped resource", err)
+	}
+
+	var many []string
+	for i := 0; i <= maxFallbackNamespaces; i++ {
+		many = append(many, "ns"+strconv.Itoa(i))
+	}
+	if _, err := list(client, true, many...); !apierrors.IsForbidden(err) {
+		t.Errorf("got %v, want the cluster-wide error for more than %d namespaces", err, maxFallbackNamespaces)
+	}
+
+	notFound := &namespacedResource{namespaces: 1, objects: 1, clusterWideErr: apierrors.NewNotFound(gvr.GroupResource(), "")}
+	if _, err := list(notFound, true, "ns0"); !apierrors.IsNotFound(err) {
+		t.Errorf("got %v, want no fallback for not found", err)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const fieldManagersFilename = "field-managers.yaml"
+
+type fieldManager struct {
+	Manager     string `json:"manager"`
+	Operation   string `json:"operation,omitempty"`
+	Subresource string `json:"subresource,omitempty"`
+	Time        string `json:"time,omitempty"`
+}
+
+type fieldManagersEntry struct {
+	objectRef
+	Managers []fieldManager `json:"managers"`
+}
+
+// fieldManagersReport collects the field managers of the dumped objects. It is safe for concurrent use.
+type fieldManagersReport struct {
+	mu      sync.Mutex
+	entries []fieldManagersEntry
+}
+
+// add records the managers of the item, it has to be called before the managed fields are removed.
+func (r *fieldManagersReport) add(gvr schema.GroupVersionResource, item unstructured.Unstructured) {
+	managedFields := item.GetManagedFields()
+	if len(managedFields) == 0 {
+		return
+	}
+
+	entry := fieldManagersEntry{objectRef: newObjectRef(gvr, item)}
+	for _, field := range managedFields {
+		manager := fieldManager{
+			Manager:     field.Manager,
+			Operation:   string(field.Operation),
+			Subresource: field.Subresource,
+		}
+		if field.Time != nil {
+			manager.Time = field.Time.UTC().Format(time.RFC3339)
+		}
+		entry.Managers = append(entry.Managers, manager)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, entry)
+}
+
+func (r *fieldManagersReport) write(outDir string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sort.Slice(r.entries, func(i, j int) bool {
+		return r.entries[i].less(r.entries[j].objectRef)
+	})
+
+	return writeReport(outDir, fieldManagersFilename, r.entries)
+}
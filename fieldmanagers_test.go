@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+)
+
+func TestFieldManagersReport(t *testing.T) {
+	newItem := func(kind, namespace, name string, managedFields ...metav1.ManagedFieldsEntry) unstructured.Unstructured {
+		item := unstructured.Unstructured{}
+		item.SetKind(kind)
+		item.SetNamespace(namespace)
+		item.SetName(name)
+		item.SetManagedFields(managedFields)
+		return item
+	}
+
+	report := &fieldManagersReport{}
+	outDir := t.TempDir()
+
+	applied := metav1.NewTime(time.Date(2023, 5, 1, 12, 0, 0, 0, time.FixedZone("CEST", 2*60*60)))
+	report.add(schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}, newItem("ConfigMap", "ns", "unmanaged"))
+	report.add(schema.GroupVersionResource{Version: "v1", Resource: "pods"}, newItem("Pod", "ns", "web",
+		metav1.ManagedFieldsEntry{Manager: "kubelet", Operation: metav1.ManagedFieldsOperationUpdate, Subresource: "status"},
+	))
+	report.add(schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}, newItem("Deployment", "ns", "web",
+		metav1.ManagedFieldsEntry{Manager: "kubectl", Operation: metav1.ManagedFieldsOperationApply, Time: &applied},
+		metav1.ManagedFieldsEntry{Manager: "kube-controller-manager", Operation: metav1.ManagedFieldsOperationUpdate},
+	))
+
+	if err := report.write(outDir); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(filepath.Join(outDir, fieldManagersFilename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []fieldManagersEntry
+	if err := yaml.Unmarshal(content, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []fieldManagersEntry{
+		{objectRef: objectRef{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "ns", Name: "web"}, Managers: []fieldManager{
+			{Manager: "kubectl", Operation: "Apply", Time: "2023-05-01T10:00:00Z"},
+			{Manager: "kube-controller-manager", Operation: "Update"},
+		}},
+		{objectRef: objectRef{APIVersion: "v1", Kind: "Pod", Namespace: "ns", Name: "web"}, Managers: []fieldManager{
+			{Manager: "kubelet", Operation: "Update", Subresource: "status"},
+		}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
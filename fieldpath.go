@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+const fieldPathWildcard = "[*]"
+
+type fieldPathSegment struct {
+	key string
+	// wildcard applies the rest of the path to all elements of the array
+	wildcard bool
+}
+
+type fieldPath []fieldPathSegment
+
+// parseFieldPath parses a path like 'status.conditions[*].lastTransitionTime'.
+// Dots within a key are escaped with a backslash, e.g. 'metadata.annotations.example\.com/owner'.
+func parseFieldPath(path string) (fieldPath, error) {
+	var parsed fieldPath
+	for _, part := range splitFieldPath(path) {
+		segment := fieldPathSegment{key: part}
+		if strings.HasSuffix(part, fieldPathWildcard) {
+			segment = fieldPathSegment{key: strings.TrimSuffix(part, fieldPathWildcard), wildcard: true}
+		}
+		if segment.key == "" || strings.ContainsAny(segment.key, "[]*") {
+			return nil, fmt.Errorf("invalid segment %q of field path %q", part, path)
+		}
+		parsed = append(parsed, segment)
+	}
+	return parsed, nil
+}
+
+// splitFieldPath splits the path at the dots which are not escaped and unescapes them.
+func splitFieldPath(path string) []string {
+	var (
+		parts []string
+		part  strings.Builder
+	)
+	for i := 0; i < len(path); i++ {
+		switch {
+		case path[i] == '\\' && i+1 < len(path) && path[i+1] == '.':
+			part.WriteByte('.')
+			i++
+		case path[i] == '.':
+			parts = append(parts, part.String())
+			part.Reset()
+		default:
+			part.WriteByte(path[i])
+		}
+	}
+	return append(parts, part.String())
+}
+
+// readFieldPathsFile parses the field paths of the file, one per line.
+// Empty lines and lines starting with '#' are ignored.
+func readFieldPathsFile(filename string) ([]fieldPath, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed reading field paths file: %v", err)
+	}
+
+	var parsed []fieldPath
+	for i, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		path, err := parseFieldPath(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+1, err)
+		}
+		parsed = append(parsed, path)
+	}
+	return parsed, nil
+}
+
+// parseStatusFieldPaths parses the paths, which all have to be below the status.
+func parseStatusFieldPaths(paths []string) ([]fieldPath, error) {
+	var parsed []fieldPath
+	for _, path := range paths {
+		if !strings.HasPrefix(path, "status.") {
+			return nil, fmt.Errorf("field path %q is not below 'status'", path)
+		}
+		p, err := parseFieldPath(path)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, p)
+	}
+	return parsed, nil
+}
+
+// removeFieldPath works like unstructured.RemoveNestedField, but the path may contain array wildcards.
+// Missing fields and fields of unexpected types are ignored.
+func removeFieldPath(obj map[string]interface{}, path fieldPath) {
+	visitFieldPath(obj, path, func(parent map[string]interface{}, key string) {
+		delete(parent, key)
+	})
+}
+
+// visitFieldPath calls visit with the parent and key of all existing fields matching the path.
+func visitFieldPath(obj map[string]interface{}, path fieldPath, visit func(parent map[string]interface{}, key string)) {
+	if len(path) == 0 {
+		return
+	}
+
+	segment := path[0]
+	value, ok := obj[segment.key]
+	if !ok {
+		return
+	}
+
+	if len(path) == 1 {
+		visit(obj, segment.key)
+		return
+	}
+
+	if !segment.wildcard {
+		if nested, ok := value.(map[string]interface{}); ok {
+			visitFieldPath(nested, path[1:], visit)
+		}
+		return
+	}
+
+	elements, ok := value.([]interface{})
+	if !ok {
+		return
+	}
+	for _, element := range elements {
+		if nested, ok := element.(map[string]interface{}); ok {
+			visitFieldPath(nested, path[1:], visit)
+		}
+	}
+}
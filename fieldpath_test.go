@@ -0,0 +1,127 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseStatusFieldPaths(t *testing.T) {
+	tests := []struct {
+		path    string
+		want    fieldPath
+		wantErr bool
+	}{
+		{path: "status.observedGeneration", want: fieldPath{{key: "status"}, {key: "observedGeneration"}}},
+		{path: "status.conditions[*].lastTransitionTime", want: fieldPath{{key: "status"}, {key: "conditions", wildcard: true}, {key: "lastTransitionTime"}}},
+		{path: "spec.replicas", wantErr: true},
+		{path: "status..foo", wantErr: true},
+		{path: "status.conditions[0]", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, err := parseStatusFieldPaths([]string{tt.path})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseStatusFieldPaths() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(got[0], tt.want) {
+				t.Errorf("got %v, want %v", got[0], tt.want)
+			}
+		})
+	}
+}
+
+func TestRemoveFieldPath(t *testing.T) {
+	newObject := func() map[string]interface{} {
+		return map[string]interface{}{
+			"status": map[string]interface{}{
+				"replicas": int64(1),
+				"conditions": []interface{}{
+					map[string]interface{}{"type": "Ready", "lastTransitionTime": "2024-01-01T00:00:00Z"},
+					map[string]interface{}{"type": "Available"},
+					"unexpected",
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		path string
+		want map[string]interface{}
+	}{
+		{
+			path: "status.conditions[*].lastTransitionTime",
+			want: map[string]interface{}{
+				"status": map[string]interface{}{
+					"replicas": int64(1),
+					"conditions": []interface{}{
+						map[string]interface{}{"type": "Ready"},
+						map[string]interface{}{"type": "Available"},
+						"unexpected",
+					},
+				},
+			},
+		},
+		{
+			path: "status.conditions[*]",
+			want: map[string]interface{}{
+				"status": map[string]interface{}{"replicas": int64(1)},
+			},
+		},
+		{
+			path: "status.replicas.missing",
+			want: newObject(),
+		},
+		{
+			path: "status.missing[*].foo",
+			want: newObject(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			path, err := parseFieldPath(tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := newObject()
+			removeFieldPath(got, path)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadFieldPathsFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "strip")
+	content := "# org-wide rules\nmetadata.annotations.example\\.com/owner\n\n  spec.template.metadata.labels.team  \n"
+	if err := os.WriteFile(filename, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := readFieldPathsFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []fieldPath{
+		{{key: "metadata"}, {key: "annotations"}, {key: "example.com/owner"}},
+		{{key: "spec"}, {key: "template"}, {key: "metadata"}, {key: "labels"}, {key: "team"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if err := os.WriteFile(filename, []byte("metadata..name\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readFieldPathsFile(filename); err == nil {
+		t.Error("expected an error for an invalid field path")
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"sort"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const finalizersFilename = "finalizers.yaml"
+
+type finalizerEntry struct {
+	objectRef
+	Finalizers []string `json:"finalizers"`
+	// Deleting is set when the deletion was already requested, i.e. the finalizers block it
+	Deleting bool `json:"deleting,omitempty"`
+}
+
+// finalizersReport collects the objects with finalizers. It is safe for concurrent use.
+type finalizersReport struct {
+	mu      sync.Mutex
+	entries []finalizerEntry
+}
+
+// add records the item when it has any finalizers.
+func (r *finalizersReport) add(gvr schema.GroupVersionResource, item unstructured.Unstructured) {
+	finalizers := item.GetFinalizers()
+	if len(finalizers) == 0 {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.entries = append(r.entries, finalizerEntry{
+		objectRef:  newObjectRef(gvr, item),
+		Finalizers: finalizers,
+		Deleting:   item.GetDeletionTimestamp() != nil,
+	})
+}
+
+// write writes the report, but only when there were objects with finalizers.
+func (r *finalizersReport) write(outDir string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.entries) == 0 {
+		return nil
+	}
+
+	sort.Slice(r.entries, func(i, j int) bool {
+		return r.entries[i].less(r.entries[j].objectRef)
+	})
+
+	return writeReport(outDir, finalizersFilename, r.entries)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+)
+
+func TestFinalizersReport(t *testing.T) {
+	newItem := func(kind, namespace, name string, finalizers ...string) unstructured.Unstructured {
+		item := unstructured.Unstructured{}
+		item.SetKind(kind)
+		item.SetNamespace(namespace)
+		item.SetName(name)
+		item.SetFinalizers(finalizers)
+		return item
+	}
+
+	report := &finalizersReport{}
+	outDir := t.TempDir()
+
+	report.add(schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}, newItem("ConfigMap", "ns", "plain"))
+	if err := report.write(outDir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(outDir, finalizersFilename)); !os.IsNotExist(err) {
+		t.Fatalf("expected no report without finalizers, got %v", err)
+	}
+
+	deleting := newItem("Namespace", "", "stuck", "kubernetes")
+	deleting.SetDeletionTimestamp(&metav1.Time{Time: time.Now()})
+	report.add(schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}, deleting)
+	report.add(schema.GroupVersionResource{Version: "v1", Resource: "persistentvolumeclaims"}, newItem("PersistentVolumeClaim", "ns", "data", "kubernetes.io/pvc-protection"))
+
+	if err := report.write(outDir); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(filepath.Join(outDir, finalizersFilename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []finalizerEntry
+	if err := yaml.Unmarshal(content, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []finalizerEntry{
+		{objectRef: objectRef{APIVersion: "v1", Kind: "Namespace", Name: "stuck"}, Finalizers: []string{"kubernetes"}, Deleting: true},
+		{objectRef: objectRef{APIVersion: "v1", Kind: "PersistentVolumeClaim", Namespace: "ns", Name: "data"}, Finalizers: []string{"kubernetes.io/pvc-protection"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const helmHooksFilename = "helm-hooks.yaml"
+
+const (
+	helmHookAnnotation             = "helm.sh/hook"
+	helmHookWeightAnnotation       = "helm.sh/hook-weight"
+	helmHookDeletePolicyAnnotation = "helm.sh/hook-delete-policy"
+)
+
+// isHelmHook reports whether Helm runs the object as a hook instead of managing it as part of the release.
+func isHelmHook(item unstructured.Unstructured) bool {
+	_, ok := item.GetAnnotations()[helmHookAnnotation]
+	return ok
+}
+
+type helmHookEntry struct {
+	objectRef
+	Hooks  []string `json:"hooks"`
+	Weight string   `json:"weight,omitempty"`
+	// DeletePolicies tell when Helm deletes the object, e.g. 'hook-succeeded' for one-shot Jobs
+	DeletePolicies []string `json:"deletePolicies,omitempty"`
+}
+
+// helmHooksReport collects the Helm hook objects, which a restore shouldn't blindly reapply.
+// It is safe for concurrent use.
+type helmHooksReport struct {
+	mu      sync.Mutex
+	entries []helmHookEntry
+}
+
+// add records the item when it's a Helm hook.
+func (r *helmHooksReport) add(gvr schema.GroupVersionResource, item unstructured.Unstructured) {
+	if !isHelmHook(item) {
+		return
+	}
+	annotations := item.GetAnnotations()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.entries = append(r.entries, helmHookEntry{
+		objectRef:      newObjectRef(gvr, item),
+		Hooks:          splitHelmAnnotation(annotations[helmHookAnnotation]),
+		Weight:         strings.TrimSpace(annotations[helmHookWeightAnnotation]),
+		DeletePolicies: splitHelmAnnotation(annotations[helmHookDeletePolicyAnnotation]),
+	})
+}
+
+// splitHelmAnnotation splits the comma-separated values like Helm does.
+func splitHelmAnnotation(value string) []string {
+	var values []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// write writes the report, but only when there were Helm hooks.
+func (r *helmHooksReport) write(outDir string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.entries) == 0 {
+		return nil
+	}
+
+	sort.Slice(r.entries, func(i, j int) bool {
+		return r.entries[i].less(r.entries[j].objectRef)
+	})
+
+	return writeReport(outDir, helmHooksFilename, r.entries)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+)
+
+func TestHelmHooksReport(t *testing.T) {
+	newItem := func(kind, name string, annotations map[string]string) unstructured.Unstructured {
+		item := unstructured.Unstructured{}
+		item.SetKind(kind)
+		item.SetNamespace("ns")
+		item.SetName(name)
+		item.SetAnnotations(annotations)
+		return item
+	}
+
+	report := &helmHooksReport{}
+	outDir := t.TempDir()
+
+	report.add(schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}, newItem("ConfigMap", "plain", map[string]string{"meta.helm.sh/release-name": "app"}))
+	if err := report.write(outDir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(outDir, helmHooksFilename)); !os.IsNotExist(err) {
+		t.Fatalf("expected no report without hooks, got %v", err)
+	}
+
+	report.add(schema.GroupVersionResource{Group: "batch", Version: "v1", Resource: "jobs"}, newItem("Job", "migrate", map[string]string{
+		helmHookAnnotation:             "pre-install, pre-upgrade",
+		helmHookWeightAnnotation:       " -5",
+		helmHookDeletePolicyAnnotation: "before-hook-creation,hook-succeeded",
+	}))
+	report.add(schema.GroupVersionResource{Version: "v1", Resource: "pods"}, newItem("Pod", "test", map[string]string{helmHookAnnotation: "test"}))
+
+	if err := report.write(outDir); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(filepath.Join(outDir, helmHooksFilename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []helmHookEntry
+	if err := yaml.Unmarshal(content, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []helmHookEntry{
+		{objectRef: objectRef{APIVersion: "batch/v1", Kind: "Job", Namespace: "ns", Name: "migrate"}, Hooks: []string{"pre-install", "pre-upgrade"}, Weight: "-5", DeletePolicies: []string{"before-hook-creation", "hook-succeeded"}},
+		{objectRef: objectRef{APIVersion: "v1", Kind: "Pod", Namespace: "ns", Name: "test"}, Hooks: []string{"test"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const imagesFilename = "images.yaml"
+
+// podSpecPaths are the locations of the pod spec in the built-in workload kinds.
+var podSpecPaths = map[string][]string{
+	"Pod":                   {"spec"},
+	"PodTemplate":           {"template", "spec"},
+	"ReplicationController": {"spec", "template", "spec"},
+	"ReplicaSet":            {"spec", "template", "spec"},
+	"Deployment":            {"spec", "template", "spec"},
+	"StatefulSet":           {"spec", "template", "spec"},
+	"DaemonSet":             {"spec", "template", "spec"},
+	"Job":                   {"spec", "template", "spec"},
+	"CronJob":               {"spec", "jobTemplate", "spec", "template", "spec"},
+}
+
+var containerFields = []string{"initContainers", "containers", "ephemeralContainers"}
+
+type imageEntry struct {
+	Image string `json:"image"`
+	Count int    `json:"count"`
+	// Objects are formatted as 'kind/namespace/name'
+	Objects []string `json:"objects"`
+}
+
+// imagesReport collects the container images of the dumped workloads. It is safe for concurrent use.
+type imagesReport struct {
+	mu     sync.Mutex
+	images map[string][]string
+}
+
+func newImagesReport() *imagesReport {
+	return &imagesReport{images: make(map[string][]string)}
+}
+
+// add records the images of the containers of the item, when it's a workload.
+func (r *imagesReport) add(item unstructured.Unstructured) {
+	path, ok := podSpecPaths[item.GetKind()]
+	if !ok {
+		return
+	}
+	podSpec, ok, _ := unstructured.NestedMap(item.Object, path...)
+	if !ok {
+		return
+	}
+
+	object := strings.Join([]string{item.GetKind(), item.GetNamespace(), item.GetName()}, "/")
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, field := range containerFields {
+		containers, _, _ := unstructured.NestedSlice(podSpec, field)
+		for _, c := range containers {
+			container, ok := c.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if image, _, _ := unstructured.NestedString(container, "image"); image != "" {
+				r.images[image] = append(r.images[image], object)
+			}
+		}
+	}
+}
+
+func (r *imagesReport) entries() []imageEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var entries []imageEntry
+	for image, objects := range r.images {
+		objects = append([]string(nil), objects...)
+		sort.Strings(objects)
+		entries = append(entries, imageEntry{Image: image, Count: len(objects), Objects: objects})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Image < entries[j].Image
+	})
+	return entries
+}
+
+func (r *imagesReport) write(outDir string) error {
+	return writeReport(outDir, imagesFilename, r.entries())
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestImagesReport(t *testing.T) {
+	containers := func(images ...string) []interface{} {
+		var containers []interface{}
+		for _, image := range images {
+			containers = append(containers, map[string]interface{}{"name": "c", "image": image})
+		}
+		return containers
+	}
+
+	pod := unstructured.Unstructured{Object: map[string]interface{}{
+		"kind":     "Pod",
+		"metadata": map[string]interface{}{"namespace": "ns1", "name": "web-1"},
+		"spec": map[string]interface{}{
+			"initContainers": containers("busybox:1.36"),
+			"containers":     containers("nginx:1.25", "sidecar:2"),
+		},
+	}}
+	deployment := unstructured.Unstructured{Object: map[string]interface{}{
+		"kind":     "Deployment",
+		"metadata": map[string]interface{}{"namespace": "ns1", "name": "web"},
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{"containers": containers("nginx:1.25")},
+			},
+		},
+	}}
+	cronJob := unstructured.Unstructured{Object: map[string]interface{}{
+		"kind":     "CronJob",
+		"metadata": map[string]interface{}{"namespace": "ns2", "name": "backup"},
+		"spec": map[string]interface{}{
+			"jobTemplate": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"template": map[string]interface{}{
+						"spec": map[string]interface{}{"containers": containers("backup:latest")},
+					},
+				},
+			},
+		},
+	}}
+	configMap := unstructured.Unstructured{Object: map[string]interface{}{
+		"kind": "ConfigMap",
+		"data": map[string]interface{}{"image": "ignored"},
+	}}
+
+	report := newImagesReport()
+	for _, item := range []unstructured.Unstructured{pod, deployment, cronJob, configMap} {
+		report.add(item)
+	}
+
+	want := []imageEntry{
+		{Image: "backup:latest", Count: 1, Objects: []string{"CronJob/ns2/backup"}},
+		{Image: "busybox:1.36", Count: 1, Objects: []string{"Pod/ns1/web-1"}},
+		{Image: "nginx:1.25", Count: 2, Objects: []string{"Deployment/ns1/web", "Pod/ns1/web-1"}},
+		{Image: "sidecar:2", Count: 1, Objects: []string{"Pod/ns1/web-1"}},
+	}
+	if got := report.entries(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+// jsonPathFilter matches the objects for which a JSONPath expression returns a truthy result.
+// The expression is evaluated against a list containing only the object, so the object can be
+// filtered like the items of 'kubectl get -o jsonpath', e.g. '{[?(@.spec.replicas > 3)]}'.
+// It is safe for concurrent use.
+type jsonPathFilter struct {
+	mu   sync.Mutex
+	path *jsonpath.JSONPath
+}
+
+func newJSONPathFilter(expression string) (*jsonPathFilter, error) {
+	path := jsonpath.New("filter").AllowMissingKeys(true)
+	if err := path.Parse(expression); err != nil {
+		return nil, fmt.Errorf("failed parsing JSONPath %q: %v", expression, err)
+	}
+	return &jsonPathFilter{path: path}, nil
+}
+
+// matches reports whether any of the results is truthy, i.e. not false, zero, empty or null.
+// Objects the expression can't be evaluated against don't match.
+func (f *jsonPathFilter) matches(item unstructured.Unstructured) bool {
+	f.mu.Lock()
+	results, err := f.path.FindResults([]interface{}{item.Object})
+	f.mu.Unlock()
+	if err != nil {
+		return false
+	}
+
+	for _, values := range results {
+		for _, value := range values {
+			if value.IsValid() && !isZeroValue(value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isZeroValue(value reflect.Value) bool {
+	for value.Kind() == reflect.Interface || value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return true
+		}
+		value = value.Elem()
+	}
+	switch value.Kind() {
+	case reflect.Map, reflect.Slice, reflect.String:
+		return value.Len() == 0
+	default:
+		return value.IsZero()
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestJSONPathFilter(t *testing.T) {
+	item := unstructured.Unstructured{Object: map[string]interface{}{
+		"kind": "Deployment",
+		"spec": map[string]interface{}{"replicas": int64(5), "paused": false},
+		"metadata": map[string]interface{}{
+			"labels": map[string]interface{}{"team": "web", "empty": ""},
+		},
+	}}
+
+	tests := []struct {
+		expression string
+		want       bool
+	}{
+		{expression: "{[?(@.spec.replicas > 3)]}", want: true},
+		{expression: "{[?(@.spec.replicas > 7)]}", want: false},
+		{expression: `{[?(@.kind == "Deployment")].metadata.labels.team}`, want: true},
+		{expression: "{[*].metadata.labels.team}", want: true},
+		{expression: "{[*].metadata.labels.empty}", want: false},
+		{expression: "{[*].spec.paused}", want: false},
+		{expression: "{[*].spec.missing}", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			filter, err := newJSONPathFilter(tt.expression)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := filter.matches(item); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := newJSONPathFilter("{.spec["); err == nil {
+		t.Error("expected an error for an invalid expression")
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// parseLabels parses labels like 'key=value'.
+func parseLabels(values []string) (map[string]string, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	parsed := make(map[string]string)
+	for _, value := range values {
+		key, val, ok := strings.Cut(value, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid label %q, expected 'key=value'", value)
+		}
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid label key %q: %v", key, strings.Join(errs, ", "))
+		}
+		if errs := validation.IsValidLabelValue(val); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid label value %q: %v", val, strings.Join(errs, ", "))
+		}
+		parsed[key] = val
+	}
+	return parsed, nil
+}
+
+// addLabels adds the labels to the item, existing labels are only replaced with overwrite.
+func addLabels(item unstructured.Unstructured, labels map[string]string, overwrite bool) {
+	itemLabels := item.GetLabels()
+	if itemLabels == nil {
+		itemLabels = make(map[string]string)
+	}
+	for key, val := range labels {
+		if _, ok := itemLabels[key]; ok && !overwrite {
+			continue
+		}
+		itemLabels[key] = val
+	}
+	item.SetLabels(itemLabels)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestParseLabels(t *testing.T) {
+	tests := []struct {
+		values  []string
+		want    map[string]string
+		wantErr bool
+	}{
+		{values: nil, want: nil},
+		{values: []string{"backup.example.com/id=2024-01-01", "tool=kubedump"}, want: map[string]string{"backup.example.com/id": "2024-01-01", "tool": "kubedump"}},
+		{values: []string{"tool="}, want: map[string]string{"tool": ""}},
+		{values: []string{"tool"}, wantErr: true},
+		{values: []string{"-tool=kubedump"}, wantErr: true},
+		{values: []string{"tool=kube dump"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseLabels(tt.values)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("parseLabels(%q) error = %v, wantErr %v", tt.values, err, tt.wantErr)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseLabels(%q) = %v, want %v", tt.values, got, tt.want)
+		}
+	}
+}
+
+func TestAddLabels(t *testing.T) {
+	labels := map[string]string{"backup": "b2", "tool": "kubedump"}
+
+	tests := []struct {
+		name      string
+		existing  map[string]string
+		overwrite bool
+		want      map[string]string
+	}{
+		{name: "no labels", want: labels},
+		{name: "keep existing", existing: map[string]string{"app": "web", "backup": "b1"}, want: map[string]string{"app": "web", "backup": "b1", "tool": "kubedump"}},
+		{name: "overwrite existing", existing: map[string]string{"app": "web", "backup": "b1"}, overwrite: true, want: map[string]string{"app": "web", "backup": "b2", "tool": "kubedump"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			item := unstructured.Unstructured{Object: map[string]interface{}{}}
+			item.SetLabels(tt.existing)
+
+			addLabels(item, labels, tt.overwrite)
+			if got := item.GetLabels(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
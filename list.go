@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+)
+
+// listOptions configure how listResource lists a resource.
+type listOptions struct {
+	// pageSize is the number of objects per page, 0 for no pagination
+	pageSize   int64
+	consistent bool
+	// resourceVersion pins the list to an exact resource version, empty for the most recent state
+	resourceVersion string
+}
+
+// listResource lists all objects of the resource.
+//
+// Without consistent, the pages are requested with resourceVersion "0", which allows the API server
+// to answer from its watch cache. The result might be slightly outdated and when a continue token
+// expires, the remaining objects are listed again without pagination, so the result isn't a snapshot.
+//
+// With consistent, the first page is read from etcd and all further pages are served at the exact
+// resource version of the first page, so the result is a point-in-time snapshot of the resource.
+// An expired continue token fails the listing, as the snapshot can't be completed anymore.
+//
+// With a resourceVersion, the list is served at exactly that version, which fails with 410 Gone
+// when the version was already compacted. It's always consistent.
+func listResource(ctx context.Context, client dynamic.ResourceInterface, opts listOptions) (*unstructured.UnstructuredList, error) {
+	listOpts := metav1.ListOptions{Limit: opts.pageSize}
+	consistent := opts.consistent
+	switch {
+	case opts.resourceVersion != "":
+		listOpts.ResourceVersion = opts.resourceVersion
+		listOpts.ResourceVersionMatch = metav1.ResourceVersionMatchExact
+		consistent = true
+	case opts.pageSize > 0 && !consistent:
+		listOpts.ResourceVersion = "0"
+	}
+
+	var result *unstructured.UnstructuredList
+	for {
+		page, err := client.List(ctx, listOpts)
+		if apierrors.IsResourceExpired(err) || apierrors.IsGone(err) {
+			if listOpts.Continue == "" && opts.resourceVersion != "" {
+				return nil, fmt.Errorf("resource version %v is too old and was already compacted, use a more recent one: %v", opts.resourceVersion, err)
+			}
+			if listOpts.Continue != "" {
+				if consistent {
+					return nil, fmt.Errorf("continue token expired, the snapshot at resource version %v can't be completed (consider a larger page size): %v", result.GetResourceVersion(), err)
+				}
+				// the list is not a snapshot anyway, start again without pagination
+				return client.List(ctx, metav1.ListOptions{ResourceVersion: "0"})
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if result == nil {
+			result = page
+		} else {
+			result.Items = append(result.Items, page.Items...)
+		}
+
+		if page.GetContinue() == "" {
+			result.SetContinue("")
+			return result, nil
+		}
+
+		// the continue token encodes the resource version of the first page,
+		// a resource version must not be set additionally
+		listOpts.Continue = page.GetContinue()
+		listOpts.ResourceVersion = ""
+		listOpts.ResourceVersionMatch = ""
+	}
+}
+
+// listNamespaces lists the objects of a namespaced resource in each of the namespaces instead of cluster-wide.
+// The namespaces are listed by the calling goroutine and an additional goroutine for each slot which is
+// free in the guard, so the fan-out only uses threads which would be idle otherwise.
+// The items are returned in the order of the namespaces. Namespaces failing with an error skip accepts are left out,
+// unless all of them fail. skip may be nil to fail on any error.
+func listNamespaces(ctx context.Context, client dynamic.NamespaceableResourceInterface, namespaces []string, opts listOptions, guard chan struct{}, skip func(namespace string, err error) bool) (*unstructured.UnstructuredList, error) {
+	var (
+		lists = make([]*unstructured.UnstructuredList, len(namespaces))
+		errs  = make([]error, len(namespaces))
+		next  = make(chan int, len(namespaces))
+		wg    sync.WaitGroup
+	)
+	for i := range namespaces {
+		next <- i
+	}
+	close(next)
+
+	work := func() {
+		for i := range next {
+			lists[i], errs[i] = listResource(ctx, client.Namespace(namespaces[i]), opts)
+		}
+	}
+
+spawn:
+	for extra := 0; extra < len(namespaces)-1; extra++ {
+		select {
+		case guard <- struct{}{}:
+			wg.Add(1)
+			go func() {
+				defer func() {
+					<-guard
+					wg.Done()
+				}()
+				work()
+			}()
+		default:
+			break spawn
+		}
+	}
+	work()
+	wg.Wait()
+
+	var (
+		result  = &unstructured.UnstructuredList{}
+		listed  bool
+		skipped error
+	)
+	for i, list := range lists {
+		if errs[i] != nil {
+			// wrapped for checking the API status, e.g. for retrying transient errors
+			err := fmt.Errorf("failed listing namespace %q: %w", namespaces[i], errs[i])
+			if skip == nil || !skip(namespaces[i], errs[i]) {
+				return nil, err
+			}
+			if skipped == nil {
+				skipped = err
+			}
+			continue
+		}
+		if !listed {
+			listed = true
+			result.SetAPIVersion(list.GetAPIVersion())
+			result.SetKind(list.GetKind())
+			result.SetResourceVersion(list.GetResourceVersion())
+		}
+		result.Items = append(result.Items, list.Items...)
+	}
+	if !listed && skipped != nil {
+		return nil, skipped
+	}
+	return result, nil
+}
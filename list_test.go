@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// pagedResource serves its objects in pages and expires the continue token once after expireAfter pages.
+type pagedResource struct {
+	dynamic.ResourceInterface
+	objects     int
+	expireAfter int
+	requests    []metav1.ListOptions
+}
+
+func (r *pagedResource) List(_ context.Context, opts metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	r.requests = append(r.requests, opts)
+
+	if opts.ResourceVersion == "1" {
+		return nil, apierrors.NewGone("too old resource version: 1 (42)")
+	}
+
+	offset := 0
+	if opts.Continue != "" {
+		offset, _ = strconv.Atoi(opts.Continue)
+		if r.expireAfter > 0 && offset >= r.expireAfter*int(opts.Limit) {
+			r.expireAfter = 0
+			return nil, apierrors.NewResourceExpired("too old resource version")
+		}
+	}
+
+	end := r.objects
+	if opts.Limit > 0 && offset+int(opts.Limit) < end {
+		end = offset + int(opts.Limit)
+	}
+
+	list := &unstructured.UnstructuredList{}
+	list.SetResourceVersion("42")
+	for i := offset; i < end; i++ {
+		item := unstructured.Unstructured{}
+		item.SetName(strconv.Itoa(i))
+		list.Items = append(list.Items, item)
+	}
+	if end < r.objects {
+		list.SetContinue(strconv.Itoa(end))
+	}
+	return list, nil
+}
+
+func TestListResource(t *testing.T) {
+	tests := []struct {
+		name            string
+		pageSize        int64
+		consistent      bool
+		resourceVersion string
+		expireAfter     int
+		wantRequests    int
+		wantErr         bool
+	}{
+		{name: "unpaginated", pageSize: 0, wantRequests: 1},
+		{name: "paginated", pageSize: 2, wantRequests: 3},
+		{name: "paginated consistent", pageSize: 2, consistent: true, wantRequests: 3},
+		{name: "expired falls back to full list", pageSize: 2, expireAfter: 1, wantRequests: 3},
+		{name: "expired consistent fails", pageSize: 2, consistent: true, expireAfter: 1, wantErr: true},
+		{name: "resource version", resourceVersion: "42", wantRequests: 1},
+		{name: "resource version paginated", pageSize: 2, resourceVersion: "42", wantRequests: 3},
+		{name: "resource version expired continue fails", pageSize: 2, resourceVersion: "42", expireAfter: 1, wantErr: true},
+		{name: "resource version too old", resourceVersion: "1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resource := &pagedResource{objects: 5, expireAfter: tt.expireAfter}
+
+			list, err := listResource(context.Background(), resource, listOptions{pageSize: tt.pageSize, consistent: tt.consistent, resourceVersion: tt.resourceVersion})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(list.Items) != 5 {
+				t.Errorf("got %d items, want 5", len(list.Items))
+			}
+			for i, item := range list.Items {
+				if item.GetName() != strconv.Itoa(i) {
+					t.Errorf("got item %q at %d", item.GetName(), i)
+				}
+			}
+			if list.GetContinue() != "" {
+				t.Errorf("got continue token %q", list.GetContinue())
+			}
+			if len(resource.requests) != tt.wantRequests {
+				t.Errorf("got %d requests, want %d", len(resource.requests), tt.wantRequests)
+			}
+
+			for i, req := range resource.requests {
+				if req.Continue != "" && req.ResourceVersion != "" {
+					t.Errorf("request %d sets resource version %q with a continue token", i, req.ResourceVersion)
+				}
+				if i == 0 && tt.resourceVersion != "" && (req.ResourceVersion != tt.resourceVersion || req.ResourceVersionMatch != metav1.ResourceVersionMatchExact) {
+					t.Errorf("first request has resource version %q (%v), want exactly %q", req.ResourceVersion, req.ResourceVersionMatch, tt.resourceVersion)
+				}
+				if i == 0 && tt.resourceVersion == "" && tt.pageSize > 0 && (req.ResourceVersion == "0") == tt.consistent {
+					t.Errorf("first request has resource version %q, consistent %v", req.ResourceVersion, tt.consistent)
+				}
+			}
+		})
+	}
+}
+
+// namespacedResource serves objects spread over namespaces, with a latency per request and per object.
+type namespacedResource struct {
+	dynamic.NamespaceableResourceInterface
+	namespace  string
+	namespaces int
+	objects    int
+	// fail lists in the namespace
+	fail string
+	// clusterWideErr fails lists across all namespaces, unless nil
+	clusterWideErr   error
+	requestLatency   time.Duration
+	perObjectLatency time.Duration
+}
+
+func (r *namespacedResource) Namespace(ns string) dynamic.ResourceInterface {
+	namespaced := *r
+	namespaced.namespace = ns
+	return &namespaced
+}
+
+func (r *namespacedResource) List(_ context.Context, _ metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	if r.namespace != "" && r.namespace == r.fail {
+		return nil, apierrors.NewForbidden(schema.GroupResource{Resource: "configmaps"}, "", nil)
+	}
+	if r.namespace == "" && r.clusterWideErr != nil {
+		return nil, r.clusterWideErr
+	}
+
+	list := &unstructured.UnstructuredList{}
+	list.SetResourceVersion("42")
+	for ns := 0; ns < r.namespaces; ns++ {
+		namespace := "ns" + strconv.Itoa(ns)
+		if r.namespace != "" && r.namespace != namespace {
+			continue
+		}
+		for i := 0; i < r.objects; i++ {
+			item := unstructured.Unstructured{}
+			item.SetNamespace(namespace)
+			item.SetName(strconv.Itoa(i))
+			list.Items = append(list.Items, item)
+		}
+	}
+	time.Sleep(r.requestLatency + time.Duration(len(list.Items))*r.perObjectLatency)
+	return list, nil
+}
+
+func TestListNamespaces(t *testing.T) {
+	client := &namespacedResource{namespaces: 10, objects: 3}
+	namespaces := []string{"ns2", "ns5", "ns7", "missing"}
+
+	for _, threads := range []int{1, 4} {
+		guard := make(chan struct{}, threads)
+		guard <- struct{}{} // taken by the caller
+
+		list, err := listNamespaces(context.Background(), client, namespaces, listOptions{}, guard, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got []string
+		for _, item := range list.Items {
+			got = append(got, item.GetNamespace()+"/"+item.GetName())
+		}
+		want := []string{"ns2/0", "ns2/1", "ns2/2", "ns5/0", "ns5/1", "ns5/2", "ns7/0", "ns7/1", "ns7/2"}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("threads %d: got %v, want %v", threads, got, want)
+		}
+		if list.GetResourceVersion() != "42" {
+			t.Errorf("threads %d: got resource version %q, want 42", threads, list.GetResourceVersion())
+		}
+		if len(guard) != 1 {
+			t.Errorf("threads %d: got %d taken slots after listing, want 1", threads, len(guard))
+		}
+	}
+
+	client.fail = "ns5"
+	if _, err := listNamespaces(context.Background(), client, namespaces, listOptions{}, make(chan struct{}, 4), nil); err == nil {
+		t.Error("expected an error when a namespace fails")
+	}
+}
+
+func benchmarkClient() *namespacedResource {
+	return &namespacedResource{namespaces: 200, objects: 50, requestLatency: time.Millisecond, perObjectLatency: time.Microsecond}
+}
+
+var benchmarkNamespaces = []string{"ns1", "ns42", "ns99", "ns150"}
+
+func BenchmarkListClusterWide(b *testing.B) {
+	client := benchmarkClient()
+	filter := itemFilter{namespaced: true, wantNamespaces: benchmarkNamespaces}
+
+	for i := 0; i < b.N; i++ {
+		list, err := listResource(context.Background(), client, listOptions{})
+		if err != nil {
+			b.Fatal(err)
+		}
+		for _, item := range list.Items {
+			skipItem(item, filter)
+		}
+	}
+}
+
+func BenchmarkListPerNamespace(b *testing.B) {
+	client := benchmarkClient()
+	guard := make(chan struct{}, len(benchmarkNamespaces))
+
+	for i := 0; i < b.N; i++ {
+		if _, err := listNamespaces(context.Background(), client, benchmarkNamespaces, listOptions{}, guard, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
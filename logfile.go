@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// logFile serializes the writes of the console output and of the log package to a file.
+// It is safe for concurrent use.
+type logFile struct {
+	mu  sync.Mutex
+	w   io.Writer
+	now func() time.Time
+}
+
+func openLogFile(filename string) (*logFile, error) {
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &logFile{w: f, now: time.Now}, nil
+}
+
+// Write writes the messages of the log package, which are already prefixed with a timestamp.
+func (l *logFile) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
+
+// timestamped returns a writer prefixing each line with the current time, for the console output.
+func (l *logFile) timestamped() io.Writer {
+	return &timestampWriter{file: l, lineStart: true}
+}
+
+type timestampWriter struct {
+	file *logFile
+	// lineStart is set when the next write starts a new line
+	lineStart bool
+}
+
+func (w *timestampWriter) Write(p []byte) (int, error) {
+	w.file.mu.Lock()
+	defer w.file.mu.Unlock()
+
+	var buf bytes.Buffer
+	for _, line := range bytes.SplitAfter(p, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		if w.lineStart {
+			buf.WriteString(w.file.now().Format("2006/01/02 15:04:05 "))
+		}
+		buf.Write(line)
+		w.lineStart = line[len(line)-1] == '\n'
+	}
+	if _, err := w.file.w.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
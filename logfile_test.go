@@ -0,0 +1,35 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"testing"
+	"time"
+)
+
+func TestLogFile(t *testing.T) {
+	var buf bytes.Buffer
+	file := &logFile{w: &buf, now: func() time.Time { return time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC) }}
+
+	var console bytes.Buffer
+	out := newLogger(io.MultiWriter(&console, file.timestamped()), uint64(levelInfo))
+	out.infof("dumping %d resources\n", 2)
+	out.infof("partial ")
+	out.infof("line\nnext line\n")
+	out.debugf("not logged at info level\n")
+
+	logger := log.New(file, "", 0)
+	logger.Println("failed listing")
+
+	want := "2024/01/02 03:04:05 dumping 2 resources\n" +
+		"2024/01/02 03:04:05 partial line\n" +
+		"2024/01/02 03:04:05 next line\n" +
+		"failed listing\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, want := console.String(), "dumping 2 resources\npartial line\nnext line\n"; got != want {
+		t.Errorf("got console output %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// logLevel is the verbosity of the output, errors are always logged with the log package.
+type logLevel uint64
+
+const (
+	// levelQuiet only outputs errors
+	levelQuiet logLevel = iota
+	// levelInfo outputs a summary of the dump
+	levelInfo
+	// levelDebug additionally outputs the progress per resource and the version information
+	levelDebug
+	// levelTrace additionally outputs every processed object
+	levelTrace
+)
+
+// logger writes messages up to its level. It is safe for concurrent use.
+type logger struct {
+	mu    sync.Mutex
+	w     io.Writer
+	level logLevel
+}
+
+func newLogger(w io.Writer, verbosity uint64) *logger {
+	return &logger{w: w, level: logLevel(verbosity)}
+}
+
+func (l *logger) enabled(level logLevel) bool {
+	return l.level >= level
+}
+
+func (l *logger) logf(level logLevel, format string, args ...interface{}) {
+	if !l.enabled(level) {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fmt.Fprintf(l.w, format, args...)
+}
+
+func (l *logger) infof(format string, args ...interface{}) {
+	l.logf(levelInfo, format, args...)
+}
+
+func (l *logger) debugf(format string, args ...interface{}) {
+	l.logf(levelDebug, format, args...)
+}
+
+func (l *logger) tracef(format string, args ...interface{}) {
+	l.logf(levelTrace, format, args...)
+}
+
+// orderedLogs writes the messages of concurrent sections in the order the sections were opened,
+// so the output doesn't depend on which goroutine finishes first. It is safe for concurrent use.
+type orderedLogs struct {
+	mu sync.Mutex
+	w  io.Writer
+	// open are the sections which aren't fully written yet, only the first one is written right away
+	open []*logSection
+}
+
+func newOrderedLogs(w io.Writer) *orderedLogs {
+	return &orderedLogs{w: w}
+}
+
+// section opens the next section, its messages are buffered until the previous sections are closed.
+func (o *orderedLogs) section() *logSection {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	s := &logSection{logs: o}
+	o.open = append(o.open, s)
+	return s
+}
+
+// logSection is the output of one goroutine of orderedLogs, it has to be closed when the goroutine is done.
+type logSection struct {
+	logs   *orderedLogs
+	buf    bytes.Buffer
+	closed bool
+}
+
+func (s *logSection) Write(p []byte) (int, error) {
+	s.logs.mu.Lock()
+	defer s.logs.mu.Unlock()
+
+	if s.logs.open[0] == s {
+		return s.logs.w.Write(p)
+	}
+	return s.buf.Write(p)
+}
+
+// close writes the buffered messages of the following sections, up to the next one which isn't closed yet.
+func (s *logSection) close() {
+	o := s.logs
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	s.closed = true
+	for len(o.open) > 0 && o.open[0].closed {
+		o.open = o.open[1:]
+		if len(o.open) > 0 {
+			o.w.Write(o.open[0].buf.Bytes())
+			o.open[0].buf.Reset()
+		}
+	}
+}
+
+// with returns a logger of the same level writing to w.
+func (l *logger) with(w io.Writer) *logger {
+	return &logger{w: w, level: l.level}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestLogger(t *testing.T) {
+	tests := []struct {
+		verbosity uint64
+		want      string
+	}{
+		{verbosity: 0, want: ""},
+		{verbosity: 1, want: "info\n"},
+		{verbosity: 2, want: "info\ndebug\n"},
+		{verbosity: 3, want: "info\ndebug\ntrace\n"},
+		{verbosity: 10, want: "info\ndebug\ntrace\n"},
+	}
+
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		out := newLogger(&buf, tt.verbosity)
+		out.infof("info\n")
+		out.debugf("debug\n")
+		out.tracef("trace\n")
+
+		if buf.String() != tt.want {
+			t.Errorf("verbosity %d: got %q, want %q", tt.verbosity, buf.String(), tt.want)
+		}
+	}
+}
+
+func TestOrderedLogs(t *testing.T) {
+	var buf bytes.Buffer
+	logs := newOrderedLogs(&buf)
+
+	first, second, third := logs.section(), logs.section(), logs.section()
+	fmt.Fprint(third, "third\n")
+	fmt.Fprint(second, "second\n")
+	fmt.Fprint(first, "first\n")
+	if buf.String() != "first\n" {
+		t.Fatalf("got %q before closing, want only the first section", buf.String())
+	}
+
+	third.close()
+	second.close()
+	if buf.String() != "first\n" {
+		t.Fatalf("got %q, want the later sections to wait for the first one", buf.String())
+	}
+
+	fmt.Fprint(first, "first again\n")
+	first.close()
+	if want := "first\nfirst again\nsecond\nthird\n"; buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+
+	fourth := logs.section()
+	fmt.Fprint(fourth, "fourth\n")
+	if want := "first\nfirst again\nsecond\nthird\nfourth\n"; buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+}
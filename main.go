@@ -1,29 +1,56 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"io/fs"
 	"log"
+	"net/http"
+	"net/url"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"text/tabwriter"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
+	"golang.org/x/exp/maps"
 	"golang.org/x/exp/slices"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/metadata"
+	_ "k8s.io/client-go/plugin/pkg/client/auth" // oidc and the migration errors of the removed gcp and azure providers
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	"sigs.k8s.io/yaml"
 )
 
+const (
+	resourcesModeAllowAll = "allow-all"
+	resourcesModeDenyAll  = "deny-all"
+)
+
 var (
 	// will be replaced during the build process
 	version = "undefined"
@@ -31,252 +58,2071 @@ var (
 	date    = "undefined"
 )
 
-func lookupEnvString(key string, defaultVal string) string {
-	if val, ok := os.LookupEnv(key); ok {
-		return val
+func lookupEnvString(key string, defaultVal string) string {
+	if val, ok := os.LookupEnv(key); ok {
+		return val
+	}
+	return defaultVal
+}
+
+func lookupEnvBool(key string, defaultVal bool) bool {
+	if val, ok := os.LookupEnv(key); ok {
+		parsed, err := strconv.ParseBool(val)
+		if err != nil {
+			log.Fatalf("failed parsing %q as bool (%q): %v", val, key, err)
+		}
+		return parsed
+	}
+	return defaultVal
+}
+
+func lookupEnvUint64(key string, defaultVal uint64) uint64 {
+	if val, ok := os.LookupEnv(key); ok {
+		parsed, err := strconv.ParseUint(val, 10, 64)
+		if err != nil {
+			log.Fatalf("failed parsing %q as uint64 (%q): %v", val, key, err)
+		}
+		return parsed
+	}
+	return defaultVal
+}
+
+func lookupEnvDuration(key string, defaultVal time.Duration) time.Duration {
+	if val, ok := os.LookupEnv(key); ok {
+		parsed, err := time.ParseDuration(val)
+		if err != nil {
+			log.Fatalf("failed parsing %q as duration (%q): %v", val, key, err)
+		}
+		return parsed
+	}
+	return defaultVal
+}
+
+// stringSliceFlag is a repeatable flag which also accepts comma-separated values.
+// Values set on the command line replace the default values.
+type stringSliceFlag struct {
+	values []string
+	set    bool
+}
+
+func newStringSliceFlag(defaultVal string) *stringSliceFlag {
+	f := &stringSliceFlag{}
+	f.append(defaultVal)
+	return f
+}
+
+func (f *stringSliceFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return strings.Join(f.values, ",")
+}
+
+func (f *stringSliceFlag) Set(val string) error {
+	if !f.set {
+		f.values = nil
+		f.set = true
+	}
+	f.append(val)
+	return nil
+}
+
+func (f *stringSliceFlag) append(val string) {
+	for _, v := range strings.Split(val, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			f.values = append(f.values, v)
+		}
+	}
+}
+
+func main() {
+	start := time.Now()
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		log.Fatalf("failed getting user home dir: %v\n", err)
+	}
+
+	var (
+		kubeConfigPath          = flag.String("config", lookupEnvString("CONFIG", filepath.Join(homeDir, ".kube", "config")), "path to the kubeconfig, empty for in-cluster config")
+		kubeContext             = flag.String("context", lookupEnvString("CONTEXT", ""), "context from the kubeconfig, empty for default")
+		outdirFlag              = flag.String("dir", lookupEnvString("DIR", "dump"), "output directory for the dumps")
+		resourcesFlag           = flag.String("resources", lookupEnvString("RESOURCES", ""), "resource to dump (e.g. 'configmaps,secrets', globs like 'config*' or '*.apps' also match the group), empty for all")
+		ignoreResourcesFlag     = flag.String("ignore-resources", lookupEnvString("IGNORE_RESOURCES", ""), "resource to ignore (e.g. 'configmaps,secrets', globs like 'config*' or '*.apps' also match the group)")
+		resourcesModeFlag       = flag.String("resources-mode", lookupEnvString("RESOURCES_MODE", resourcesModeAllowAll), fmt.Sprintf("dump all resources when no resources are specified (%q) or none (%q)", resourcesModeAllowAll, resourcesModeDenyAll))
+		namespacesFlag          = flag.String("namespaces", lookupEnvString("NAMESPACES", ""), "namespace to dump (e.g. 'ns1,ns2'), empty for all")
+		ignoreNamespacesFlag    = flag.String("ignore-namespaces", lookupEnvString("IGNORE_NAMESPACES", ""), "namespace to ignore (e.g. 'ns1,ns2')")
+		clusterscopedFlag       = flag.Bool("clusterscoped", lookupEnvBool("CLUSTERSCOPED", true), "dump cluster-wide resources")
+		namespacedFlag          = flag.Bool("namespaced", lookupEnvBool("NAMESPACED", true), "dump namespaced resources")
+		statelessFlag           = flag.Bool("stateless", lookupEnvBool("STATELESS", true), "remove fields containing a state of the resource")
+		versionFlag             = flag.Bool("version", lookupEnvBool("VERSION", false), fmt.Sprintf("print version information of this release (%v)", version))
+		maxThreadsFlag          = newThreadsFlag("10")
+		verbosityFlag           = flag.Uint64("verbosity", lookupEnvUint64("VERBOSITY", 1), "verbosity of the output (0 quiet, 1 info, 2 debug, 3 trace)")
+		quietFlag               = flag.Bool("quiet", lookupEnvBool("QUIET", false), "suppress all output except errors (overrides -verbosity)")
+		listTimeoutFlag         = flag.Duration("list-timeout", lookupEnvDuration("LIST_TIMEOUT", 0), "timeout for listing a single resource (e.g. '30s'), 0 for no timeout")
+		noSubdirFlag            = flag.Bool("no-subdir", lookupEnvBool("NO_SUBDIR", false), "write all files directly into the output directory, encoding the path into the filename")
+		checkAccessFlag         = flag.Bool("check-access", lookupEnvBool("CHECK_ACCESS", false), "only dump resources the current identity may list and write an '"+accessReportFilename+"'")
+		resumeFlag              = flag.Bool("resume", lookupEnvBool("RESUME", false), "continue an interrupted dump, skipping the resources which were already completely dumped")
+		followOwnersFlag        = flag.Bool("follow-owners", lookupEnvBool("FOLLOW_OWNERS", false), "additionally dump the owners of dumped objects, even when they are filtered")
+		fieldManagersFlag       = flag.Bool("field-managers", lookupEnvBool("FIELD_MANAGERS", false), "write a summary of the field managers of all objects to '"+fieldManagersFilename+"'")
+		maxRuntimeFlag          = flag.Duration("max-runtime", lookupEnvDuration("MAX_RUNTIME", 0), "stop dumping further resources after the duration (e.g. '10m') and keep the partial dump, 0 for no limit")
+		discoveryStrictFlag     = flag.Bool("discovery-strict", lookupEnvBool("DISCOVERY_STRICT", false), "fail when the resources of a group version can't be discovered instead of skipping the group version")
+		userAgentFlag           = flag.String("user-agent", lookupEnvString("USER_AGENT", "kubedump/"+version), "user agent used for the requests to the API server")
+		secretsDirFlag          = flag.String("secrets-dir", lookupEnvString("SECRETS_DIR", ""), "separate output directory for secrets with restricted permissions, empty for the regular output directory")
+		decodeSecretsFlag       = flag.Bool("decode-secrets", lookupEnvBool("DECODE_SECRETS", false), "write the decoded values of secrets as 'stringData' (values which are not valid UTF-8 stay base64 encoded in 'data')")
+		contextSubdirFlag       = flag.Bool("context-subdir", lookupEnvBool("CONTEXT_SUBDIR", false), "write the dump into a subdirectory named after the context")
+		sidecarMetadataFlag     = flag.Bool("sidecar-metadata", lookupEnvBool("SIDECAR_METADATA", false), "write the provenance (resource, uid, resource version, dump time) of each object to a '<name>"+metadataSuffix+"' file")
+		nameReplaceCharsFlag    = flag.String("name-replace-chars", lookupEnvString("NAME_REPLACE_CHARS", defaultNameReplaceChars), "characters to replace in the filenames of objects")
+		nameReplaceCharFlag     = flag.String("name-replace-char", lookupEnvString("NAME_REPLACE_CHAR", defaultNameReplaceChar), "character used as the replacement for -name-replace-chars")
+		listResourcesFlag       = flag.Bool("list-resources", lookupEnvBool("LIST_RESOURCES", false), "print the discoverable resources and exit")
+		maxInflightBytesFlag    = flag.Uint64("max-inflight-bytes", lookupEnvUint64("MAX_INFLIGHT_BYTES", 0), "maximum estimated bytes of objects processed concurrently, additionally to -list-threads, 0 for no limit")
+		outputFormatFlag        = flag.String("output-format", lookupEnvString("OUTPUT_FORMAT", outputFormatYAML), fmt.Sprintf("write the objects as %q files or only print a %q of them", outputFormatYAML, outputFormatTable))
+		proxyURLFlag            = flag.String("proxy-url", lookupEnvString("PROXY_URL", ""), "proxy for the requests to the API server, empty for the kubeconfig or environment settings")
+		tlsServerNameFlag       = flag.String("tls-server-name", lookupEnvString("TLS_SERVER_NAME", ""), "server name used for the TLS verification (SNI) of the API server, empty for the kubeconfig settings")
+		compareClustersFlag     = flag.String("compare-clusters", lookupEnvString("COMPARE_CLUSTERS", ""), "print the differences of the objects of two contexts (e.g. 'ctx1,ctx2') instead of dumping")
+		listThreadsFlag         = flag.Uint64("list-threads", lookupEnvUint64("LIST_THREADS", 0), "maximum number of threads listing resources, 0 for -threads")
+		writeThreadsFlag        = flag.Uint64("write-threads", lookupEnvUint64("WRITE_THREADS", 0), "maximum number of threads writing objects, 0 for -threads")
+		includeEventsForFlag    = flag.String("include-events-for", lookupEnvString("INCLUDE_EVENTS_FOR", ""), "additionally dump the events of dumped objects of the kinds (e.g. 'Pod,Deployment', '"+allKinds+"' for all kinds), empty for none")
+		annotateOutputFlag      = flag.Bool("annotate-output", lookupEnvBool("ANNOTATE_OUTPUT", false), "prepend a comment with the context, resource and dump time to each file")
+		sinceFileFlag           = flag.String("since-file", lookupEnvString("SINCE_FILE", ""), "only dump objects created or modified after the time in the file and update it after a complete dump, a missing file dumps everything")
+		reportFlag              = flag.String("report", lookupEnvString("REPORT", ""), "write a JSON summary of the run (times, counts, errors, flags, cluster version) to the file, empty for none")
+		pageSizeFlag            = flag.Uint64("page-size", lookupEnvUint64("PAGE_SIZE", 0), "list the resources in pages of the number of objects, 0 for no pagination")
+		consistentFlag          = flag.Bool("consistent", lookupEnvBool("CONSISTENT", false), "list all pages of a resource at the exact resource version of the first page, making it a point-in-time snapshot (requires -page-size)")
+		excludeOwnedFlag        = flag.Bool("exclude-owned", lookupEnvBool("EXCLUDE_OWNED", false), "skip objects controlled by another object (e.g. pods of a replica set)")
+		excludeOwnedKindsFlag   = flag.String("exclude-owned-kinds", lookupEnvString("EXCLUDE_OWNED_KINDS", ""), "controller kinds which cause -exclude-owned to skip an object (e.g. 'ReplicaSet,Job'), empty for all")
+		clusterscopedDirFlag    = flag.String("clusterscoped-dir", lookupEnvString("CLUSTERSCOPED_DIR", defaultClusterscopedDir), "name of the directory for cluster-scoped resources, omitted when only one scope is dumped")
+		namespacedDirFlag       = flag.String("namespaced-dir", lookupEnvString("NAMESPACED_DIR", defaultNamespacedDir), "name of the directory for namespaced resources, omitted when only one scope is dumped")
+		resourceVersionFlag     = flag.String("resource-version", lookupEnvString("RESOURCE_VERSION", ""), "list all resources at exactly the resource version, for reproducible dumps while it's not compacted, empty for the most recent state")
+		adaptiveConcurrencyFlag = flag.Bool("adaptive-concurrency", lookupEnvBool("ADAPTIVE_CONCURRENCY", false), "start with a single concurrent list and increase up to -list-threads while the latency stays below -adaptive-latency, backing off when throttled")
+		adaptiveLatencyFlag     = flag.Duration("adaptive-latency", lookupEnvDuration("ADAPTIVE_LATENCY", time.Second), "acceptable rolling latency of list calls for -adaptive-concurrency")
+		onlyCRDsFlag            = flag.Bool("only-crds", lookupEnvBool("ONLY_CRDS", false), "only dump the custom resource definitions and the resources of their groups")
+		kindsFlag               = flag.String("kinds", lookupEnvString("KINDS", ""), "kind to dump (e.g. 'Deployment,Service'), a resource matching either -resources or -kinds is dumped")
+		caDirFlag               = flag.String("ca-dir", lookupEnvString("CA_DIR", ""), "directory with PEM files ('*.pem', '*.crt') of the CAs of the API server, replacing the CA of the kubeconfig, empty for the kubeconfig settings")
+		largeThresholdFlag      = flag.Uint64("large-threshold", lookupEnvUint64("LARGE_THRESHOLD", 0), "estimate the number of objects before dumping and stop when it exceeds the threshold, unless -confirm-large is set, 0 for no estimate")
+		confirmLargeFlag        = flag.Bool("confirm-large", lookupEnvBool("CONFIRM_LARGE", false), "dump even when the estimated number of objects exceeds -large-threshold")
+		nsWriteThreadsFlag      = flag.Uint64("namespace-write-threads", lookupEnvUint64("NAMESPACE_WRITE_THREADS", 0), "maximum number of threads writing objects of the same namespace, 0 for no limit")
+		deepRenameFlag          = flag.Bool("deep-rename", lookupEnvBool("DEEP_RENAME", false), "additionally rename the namespace references of known fields (e.g. the subjects of role bindings) with -rename-namespace")
+		completeResourcesFlag   = flag.String("complete-resources", "", "print the resources starting with the prefix for shell completion and exit")
+		restoreScriptFlag       = flag.Bool("emit-restore-script", lookupEnvBool("EMIT_RESTORE_SCRIPT", false), "write an '"+restoreScriptFilename+"' applying the written manifests with kubectl, each resource only in the preferred group version, namespaces and custom resource definitions first")
+		cleanStdinFlag          = flag.Bool("clean-stdin", lookupEnvBool("CLEAN_STDIN", false), "clean and transform the YAML or JSON manifests from stdin without a cluster and write them to -dir, or to stdout when -dir is '"+stdoutDir+"'")
+		requestTimeoutFlag      = flag.Duration("request-timeout", lookupEnvDuration("REQUEST_TIMEOUT", 0), "timeout for a single request to the API server (e.g. '30s'), 0 for the kubeconfig settings or no timeout")
+		storageVersionOnlyFlag  = flag.Bool("crd-storage-version-only", lookupEnvBool("CRD_STORAGE_VERSION_ONLY", false), "only dump custom resources in the storage version of their CRD instead of every served version")
+		singleFileFlag          = flag.String("output-single-file", lookupEnvString("OUTPUT_SINGLE_FILE", ""), "write all objects sorted by kind and name into the single YAML file in -dir (e.g. 'all.yaml'), empty for one file per object")
+		authHelpFlag            = flag.Bool("auth-help", lookupEnvBool("AUTH_HELP", false), "print how the kubeconfig authenticates and what's needed for it to work, and exit")
+		parallelNamespacesFlag  = flag.Bool("parallel-namespaces", lookupEnvBool("PARALLEL_NAMESPACES", false), "list namespaced resources in each of the -namespaces concurrently using idle -list-threads, instead of cluster-wide")
+		imagesReportFlag        = flag.Bool("images-report", lookupEnvBool("IMAGES_REPORT", false), "write the container images of all dumped workloads with the objects using them to '"+imagesFilename+"'")
+		globalStripFlag         = flag.String("global-strip", lookupEnvString("GLOBAL_STRIP", ""), "file with field paths to remove from all objects, one per line (e.g. 'metadata.annotations.example\\.com/owner'), empty for none")
+		listContextsFlag        = flag.Bool("list-contexts", lookupEnvBool("LIST_CONTEXTS", false), "print the contexts of the kubeconfig, marking the current one, and exit")
+		projectionFlag          = flag.String("projection", lookupEnvString("PROJECTION", ""), "resource to only dump the metadata of as 'PartialObjectMetadata' (e.g. 'pods,events', globs like 'config*' or '*.apps' also match the group), empty for none")
+		serialLogsFlag          = flag.Bool("serial-logs", lookupEnvBool("SERIAL_LOGS", false), "print the verbose output of the resources in discovery order and without durations, and the summary of all resources sorted, for comparable verbose runs")
+		maxTotalRetriesFlag     = flag.Uint64("max-total-retries", lookupEnvUint64("MAX_TOTAL_RETRIES", 20), "maximum number of retries of transiently failed lists during the whole run, 0 for no retries")
+		excludeSystemNsFlag     = flag.Bool("exclude-system-namespaces", lookupEnvBool("EXCLUDE_SYSTEM_NAMESPACES", false), "ignore the -system-namespaces additionally to -ignore-namespaces")
+		systemNamespacesFlag    = flag.String("system-namespaces", lookupEnvString("SYSTEM_NAMESPACES", defaultSystemNamespaces), "namespaces ignored by -exclude-system-namespaces")
+		compressThresholdFlag   = flag.Uint64("compress-threshold", lookupEnvUint64("COMPRESS_THRESHOLD", 0), "gzip the files larger than the number of bytes to '<name>.gz' and list them in '"+compressedFilesFilename+"', 0 for no compression")
+		overwriteLabelsFlag     = flag.Bool("overwrite-labels", lookupEnvBool("OVERWRITE_LABELS", false), "replace existing labels of the objects with -add-label instead of keeping them")
+		countOnlyFlag           = flag.Bool("count-only", lookupEnvBool("COUNT_ONLY", false), "print the number of objects per resource without fetching all objects (only -namespaces of the object filters apply) and exit")
+		followConfigRefsFlag    = flag.Bool("follow-configmap-references", lookupEnvBool("FOLLOW_CONFIGMAP_REFERENCES", false), "additionally dump the config maps and secrets referenced by the pod specs of dumped workloads, even when they are filtered")
+		redactRegexFlag         = flag.String("redact-regex", lookupEnvString("REDACT_REGEX", ""), "replace the values of keys and environment variables matching the regex in all objects with '"+redactedPlaceholder+"' (e.g. '(?i)password|token'), empty for none")
+		nsSelectorFlag          = flag.String("namespace-selector", lookupEnvString("NAMESPACE_SELECTOR", ""), "only dump the namespaces matching the label selector (e.g. 'backup=true'), combined with -namespaces when both are set, empty for all")
+		finalizersReportFlag    = flag.Bool("finalizers-report", lookupEnvBool("FINALIZERS_REPORT", false), "write the objects with finalizers, which may block their deletion, to '"+finalizersFilename+"'")
+		gvkInFilenameFlag       = flag.Bool("output-gvk-in-filename", lookupEnvBool("OUTPUT_GVK_IN_FILENAME", false), "append the group, version and kind to the filenames (e.g. 'name.apps.v1.Deployment.yaml'), to keep them unambiguous when merging dumps")
+		checkFlag               = flag.Bool("check", lookupEnvBool("CHECK", false), "only verify the config, the authentication and the permission to list namespaces, then exit non-zero on failure")
+		keepRawFlag             = flag.Bool("keep-raw", lookupEnvBool("KEEP_RAW", false), "additionally write the object next to each manifest as '<name>"+rawSuffix+"', to verify what the cleaning removed, only -global-strip and -redact-regex are applied to it")
+		extractDataFlag         = flag.Bool("extract-data", lookupEnvBool("EXTRACT_DATA", false), "additionally write each data key of ConfigMaps and Secrets as a file below '<name>/', decoding the base64 of Secrets (use '-format-override configmaps=extract' to skip the manifests)")
+		maxFileNameLengthFlag   = flag.Uint64("max-file-name-length", lookupEnvUint64("MAX_FILE_NAME_LENGTH", 255), "truncate longer filenames including their extension, appending a hash of the name to keep them unique, at least "+fmt.Sprint(minFileNameLength)+", 0 for no limit")
+		existingDirFlag         = flag.String("existing-dir", lookupEnvString("EXISTING_DIR", existingDirWarn), fmt.Sprintf("when the output directory isn't empty, %q and write into it anyway, %q, or %q it silently (e.g. for automation), ignored when resuming", existingDirWarn, existingDirFail, existingDirOverwrite))
+		jsonPathFilterFlag      = flag.String("jsonpath-filter", lookupEnvString("JSONPATH_FILTER", ""), "only dump the objects for which the JSONPath expression is truthy, evaluated against a list containing only the object (e.g. '{[?(@.spec.replicas > 3)]}'), empty for all")
+		discoveryCacheTTLFlag   = flag.Duration("discovery-cache-ttl", lookupEnvDuration("DISCOVERY_CACHE_TTL", 0), "reuse the discovered resources cached in '~/.kube/cache/kubedump' for the duration, per server and version (e.g. '10m'), resources added within it are missed, 0 for no cache")
+		noDiscoveryCacheFlag    = flag.Bool("no-discovery-cache", lookupEnvBool("NO_DISCOVERY_CACHE", false), "neither read nor write the discovery cache")
+		logFileFlag             = flag.String("log-file", lookupEnvString("LOG_FILE", ""), "additionally append the output and the errors with timestamps to the file, empty for none")
+		keepOwnerRefsFlag       = flag.Bool("owner-references-keep", lookupEnvBool("OWNER_REFERENCES_KEEP", false), "keep the owner references which -stateless removes, for restoring the whole owner graph at once")
+		layoutFlag              = flag.String("layout", lookupEnvString("LAYOUT", layoutDefault), fmt.Sprintf("directory structure of the output, %q or %q writing JSON files like the contents of a Velero backup tarball", layoutDefault, layoutVelero))
+		ignoreOwnerKindFlag     = flag.String("ignore-owner-kind", lookupEnvString("IGNORE_OWNER_KIND", ""), "skip objects controlled by an object of the kinds (e.g. 'ReplicaSet,Job'), independently of -exclude-owned, empty for none")
+		convertToFlag           = flag.String("convert-to", lookupEnvString("CONVERT_TO", ""), "dump the resources served by the group versions only in them (e.g. 'networking.k8s.io/v1'), so the API server converts the objects of other group versions of the same resources, empty for all served versions")
+		skipKindsFlag           = flag.String("skip-kinds", lookupEnvString("SKIP_KINDS", ""), "kinds to ignore (e.g. 'Event,Endpoints'), a resource matching either -ignore-resources or -skip-kinds is ignored")
+		dedupeContentFlag       = flag.Bool("dedupe-content", lookupEnvBool("DEDUPE_CONTENT", false), "write a relative symlink to the first file with the same content instead of a copy (e.g. for data extracted with -extract-data), except for separated secrets")
+		timeoutPerResourceFlag  = flag.Duration("timeout-per-resource", lookupEnvDuration("TIMEOUT_PER_RESOURCE", 0), "abandon a resource when listing and writing its objects takes longer (e.g. '5m'), unlike -list-timeout this includes the writes, 0 for no timeout")
+		mergeIntoFlag           = flag.String("merge-into", lookupEnvString("MERGE_INTO", ""), "merge the dump directories given as arguments ('<name>=<dir>' or '<dir>' named after its base) below '<name>/' of the directory without a cluster and write the combined index '"+mergeIndexFilename+"', empty for dumping")
+		skipHelmHooksFlag       = flag.Bool("skip-helm-hooks", lookupEnvBool("SKIP_HELM_HOOKS", false), "skip the Helm hook objects (annotated with '"+helmHookAnnotation+"'), e.g. one-shot Jobs which shouldn't be reapplied by a restore")
+		helmHooksReportFlag     = flag.Bool("helm-hooks-report", lookupEnvBool("HELM_HOOKS_REPORT", false), "write the dumped Helm hook objects with their hooks, weights and delete policies to '"+helmHooksFilename+"', so a restore can treat them specially")
+		noNamespaceDirFlag      = flag.String("no-namespace-dir", lookupEnvString("NO_NAMESPACE_DIR", defaultNoNamespaceDir), "name of the namespace directory for objects of namespaced resources returned without a namespace, instead of misfiling them as cluster-scoped")
+		sqliteFlag              = flag.String("sqlite", lookupEnvString("SQLITE", ""), "additionally insert the written objects with their group, version, kind, namespace, name, uid and JSON body into the 'objects' table of the SQLite database file, empty for none")
+		archivePerNamespaceFlag = flag.Bool("archive-per-namespace", lookupEnvBool("ARCHIVE_PER_NAMESPACE", false), "write one tar.gz archive per namespace (cluster-scoped resources go to '_cluster.tar.gz')")
+	)
+	if val, ok := os.LookupEnv("THREADS"); ok {
+		if err := maxThreadsFlag.Set(val); err != nil {
+			log.Fatalf("failed parsing %q as threads (%q): %v", val, "THREADS", err)
+		}
+	}
+	flag.Var(maxThreadsFlag, "threads", "maximum number of threads listing and writing each, unless overridden by -list-threads and -write-threads (minimum 1), '"+threadsAuto+"' to derive it from the CPUs and the API latency")
+	groupVersionsFlag := newStringSliceFlag(lookupEnvString("GROUP_VERSION", ""))
+	flag.Var(groupVersionsFlag, "group-version", "group version to dump (e.g. 'apps/v1'), repeatable, empty for all")
+	skipAnnotationsFlag := newStringSliceFlag(lookupEnvString("SKIP_ANNOTATION", ""))
+	flag.Var(skipAnnotationsFlag, "skip-annotation", "skip objects with the annotation (e.g. 'backup=false', only the key matches any value), repeatable")
+	requireAnnotationsFlag := newStringSliceFlag(lookupEnvString("REQUIRE_ANNOTATION", ""))
+	flag.Var(requireAnnotationsFlag, "require-annotation", "only dump objects with any of the annotations (e.g. 'backup=true', only the key matches any value), repeatable")
+	renameNamespacesFlag := newStringSliceFlag(lookupEnvString("RENAME_NAMESPACE", ""))
+	flag.Var(renameNamespacesFlag, "rename-namespace", "write the objects of a namespace as if they were in another namespace (e.g. 'prod=staging'), repeatable")
+	addLabelsFlag := newStringSliceFlag(lookupEnvString("ADD_LABEL", ""))
+	flag.Var(addLabelsFlag, "add-label", "add the label to all objects (e.g. 'backup-id=2024-01-01'), existing labels are kept unless -overwrite-labels is set, repeatable")
+	stripStatusFieldsFlag := newStringSliceFlag(lookupEnvString("STRIP_STATUS_FIELD", ""))
+	flag.Var(stripStatusFieldsFlag, "strip-status-field", "remove the field of the status, which is kept with '-stateless=false' (e.g. 'status.conditions[*].lastTransitionTime'), repeatable")
+	formatOverridesFlag := newStringSliceFlag(lookupEnvString("FORMAT_OVERRIDE", ""))
+	flag.Var(formatOverridesFlag, "format-override", "write the objects of the resource in another format (e.g. 'configmaps=extract'), '"+formatYAML+"' or '"+formatExtract+"' writing each data key of ConfigMaps and Secrets as a file below '<name>/' instead of the manifest, repeatable")
+
+	flag.Usage = printUsage
+	flag.Parse()
+
+	if *quietFlag {
+		*verbosityFlag = 0
+	}
+	var (
+		console    io.Writer = os.Stdout
+		logOutputs           = []io.Writer{os.Stderr}
+	)
+	if *logFileFlag != "" {
+		file, err := openLogFile(*logFileFlag)
+		if err != nil {
+			log.Fatalf("failed opening log file: %v\n", err)
+		}
+		console = io.MultiWriter(os.Stdout, file.timestamped())
+		logOutputs = append(logOutputs, file)
+	}
+
+	out := newLogger(console, *verbosityFlag)
+
+	var report *runReport
+	if *reportFlag != "" {
+		report = newRunReport(start)
+		logOutputs = append(logOutputs, report)
+	}
+	log.SetOutput(io.MultiWriter(logOutputs...))
+
+	if *versionFlag || out.enabled(levelDebug) {
+		fmt.Printf("version: %v\n", version)
+		fmt.Printf("commit: %v\n", commit)
+		fmt.Printf("date: %v\n", date)
+
+		if *versionFlag {
+			os.Exit(0)
+		}
+	}
+
+	if !maxThreadsFlag.auto && maxThreadsFlag.n <= 0 {
+		log.Fatalln("minimum number of threads is 1")
+	}
+	if *consistentFlag && *pageSizeFlag == 0 {
+		log.Fatalln("-consistent requires -page-size, an unpaginated list is always consistent")
+	}
+
+	if *resourcesModeFlag != resourcesModeAllowAll && *resourcesModeFlag != resourcesModeDenyAll {
+		log.Fatalf("invalid resources mode %q, must be %q or %q\n", *resourcesModeFlag, resourcesModeAllowAll, resourcesModeDenyAll)
+	}
+	nameReplacer, err := newNameReplacer(*nameReplaceCharsFlag, *nameReplaceCharFlag)
+	if err != nil {
+		log.Fatalf("invalid name replacement: %v\n", err)
+	}
+
+	stripStatusFields, err := parseStatusFieldPaths(stripStatusFieldsFlag.values)
+	if err != nil {
+		log.Fatalf("invalid status field: %v\n", err)
+	}
+
+	addedLabels, err := parseLabels(addLabelsFlag.values)
+	if err != nil {
+		log.Fatalf("invalid label: %v\n", err)
+	}
+
+	formatOverrides, err := parseFormatOverrides(formatOverridesFlag.values)
+	if err != nil {
+		log.Fatalf("invalid format override: %v\n", err)
+	}
+
+	var redactPattern *regexp.Regexp
+	if *redactRegexFlag != "" {
+		redactPattern, err = regexp.Compile(*redactRegexFlag)
+		if err != nil {
+			log.Fatalf("invalid redact regex: %v\n", err)
+		}
+	}
+
+	var globalStrip []fieldPath
+	if *globalStripFlag != "" {
+		globalStrip, err = readFieldPathsFile(*globalStripFlag)
+		if err != nil {
+			log.Fatalf("invalid global strip file: %v\n", err)
+		}
+	}
+
+	namespaceRenames, err := parseNamespaceRenames(renameNamespacesFlag.values)
+	if err != nil {
+		log.Fatalf("invalid namespace rename: %v\n", err)
+	}
+
+	if err := validateScopeDirs(*clusterscopedDirFlag, *namespacedDirFlag); err != nil {
+		log.Fatalf("invalid scope directory: %v\n", err)
+	}
+	if !safeDirName(*noNamespaceDirFlag) {
+		log.Fatalf("invalid no namespace directory: %q is not a safe directory name\n", *noNamespaceDirFlag)
+	}
+
+	if *outputFormatFlag != outputFormatYAML && *outputFormatFlag != outputFormatTable {
+		log.Fatalf("invalid output format %q, must be %q or %q\n", *outputFormatFlag, outputFormatYAML, outputFormatTable)
+	}
+
+	if *keepOwnerRefsFlag && *statelessFlag {
+		log.Println("warning: keeping owner references, their UIDs won't resolve on another cluster unless the owners are restored with them")
+	}
+
+	switch *layoutFlag {
+	case layoutDefault:
+	case layoutVelero:
+		if *archivePerNamespaceFlag || *singleFileFlag != "" || *noSubdirFlag || *secretsDirFlag != "" || *restoreScriptFlag || *compressThresholdFlag > 0 {
+			log.Fatalln("the velero layout is not supported when writing archives, a single file, no subdirs, a separate secrets dir, a restore script or compressing files")
+		}
+		// a Velero backup only contains the objects
+		if *keepRawFlag || *sidecarMetadataFlag || *extractDataFlag || *dedupeContentFlag || slices.Contains(maps.Values(formatOverrides), formatExtract) {
+			log.Fatalln("the velero layout is not supported when keeping raw objects, writing sidecar metadata, extracting data or deduplicating content")
+		}
+	default:
+		log.Fatalf("invalid layout %q, must be %q or %q\n", *layoutFlag, layoutDefault, layoutVelero)
+	}
+
+	if *maxFileNameLengthFlag > 0 && *maxFileNameLengthFlag < uint64(minFileNameLength) {
+		log.Fatalf("invalid max file name length %d, must be 0 or at least %d to fit the hash and the suffixes of truncated names\n", *maxFileNameLengthFlag, minFileNameLength)
+	}
+
+	if *existingDirFlag != existingDirWarn && *existingDirFlag != existingDirFail && *existingDirFlag != existingDirOverwrite {
+		log.Fatalf("invalid existing dir handling %q, must be %q, %q or %q\n", *existingDirFlag, existingDirWarn, existingDirFail, existingDirOverwrite)
+	}
+
+	if *resumeFlag && *archivePerNamespaceFlag {
+		log.Fatalln("resuming is not supported when writing archives")
+	}
+
+	if *restoreScriptFlag && (*archivePerNamespaceFlag || *resumeFlag || *compressThresholdFlag > 0) {
+		log.Fatalln("the restore script is not supported when writing archives, resuming or compressing files")
+	}
+
+	if *cleanStdinFlag && (*archivePerNamespaceFlag || *singleFileFlag != "" || *compressThresholdFlag > 0 || *dedupeContentFlag || *restoreScriptFlag) {
+		log.Fatalln("cleaning stdin is not supported when writing archives, a single file, compressing files, deduplicating content or writing a restore script")
+	}
+
+	if *sqliteFlag != "" && (*cleanStdinFlag || *outputFormatFlag == outputFormatTable) {
+		log.Fatalln("the SQLite database is not supported when cleaning stdin or printing a table")
+	}
+
+	if *singleFileFlag != "" {
+		if *archivePerNamespaceFlag || *resumeFlag || *restoreScriptFlag || *keepRawFlag || len(formatOverridesFlag.values) > 0 || *extractDataFlag {
+			log.Fatalln("a single file is not supported when writing archives, resuming, writing a restore script, keeping the raw objects, overriding formats or extracting data")
+		}
+		if *sidecarMetadataFlag || *dedupeContentFlag || *compressThresholdFlag > 0 {
+			log.Fatalln("a single file is not supported when writing sidecar metadata, deduplicating content or compressing files")
+		}
+		if *singleFileFlag == "." || *singleFileFlag == ".." || strings.ContainsAny(*singleFileFlag, unsafeFilenameChars) {
+			log.Fatalf("%q is not a safe filename\n", *singleFileFlag)
+		}
+	}
+
+	var (
+		wantResources    = strings.Split(strings.ToLower(*resourcesFlag), ",")
+		wantNamespaces   = strings.Split(strings.ToLower(*namespacesFlag), ",")
+		ignoreResources  = strings.Split(strings.ToLower(*ignoreResourcesFlag), ",")
+		ignoreNamespaces = strings.Split(strings.ToLower(*ignoreNamespacesFlag), ",")
+		resFilter        = resourceFilter{
+			wantResources:   wantResources,
+			ignoreResources: ignoreResources,
+			denyAll:         *resourcesModeFlag == resourcesModeDenyAll,
+		}
+		filter = itemFilter{
+			namespaced:         *namespacedFlag,
+			clusterscoped:      *clusterscopedFlag,
+			wantNamespaces:     wantNamespaces,
+			ignoreNamespaces:   ignoreNamespaces,
+			skipAnnotations:    parseAnnotations(skipAnnotationsFlag.values),
+			requireAnnotations: parseAnnotations(requireAnnotationsFlag.values),
+			excludeOwned:       *excludeOwnedFlag,
+		}
+	)
+
+	if *jsonPathFilterFlag != "" {
+		filter.jsonPath, err = newJSONPathFilter(*jsonPathFilterFlag)
+		if err != nil {
+			log.Fatalf("invalid JSONPath filter: %v\n", err)
+		}
+	}
+
+	if *excludeSystemNsFlag {
+		filter.ignoreNamespaces = withSystemNamespaces(filter.ignoreNamespaces, *systemNamespacesFlag)
+	}
+
+	if *nsSelectorFlag != "" {
+		if _, err := labels.Parse(*nsSelectorFlag); err != nil {
+			log.Fatalf("invalid namespace selector: %v\n", err)
+		}
+		if *compareClustersFlag != "" {
+			log.Fatalln("-namespace-selector can't be combined with -compare-clusters")
+		}
+	}
+
+	if *parallelNamespacesFlag && *namespacesFlag == "" && *nsSelectorFlag == "" {
+		log.Fatalln("-parallel-namespaces requires -namespaces or -namespace-selector")
+	}
+
+	if *kindsFlag != "" {
+		resFilter.wantKinds = strings.Split(*kindsFlag, ",")
+	}
+	if *skipKindsFlag != "" {
+		resFilter.skipKinds = strings.Split(*skipKindsFlag, ",")
+	}
+	if *excludeOwnedKindsFlag != "" {
+		filter.excludeOwnedKinds = strings.Split(*excludeOwnedKindsFlag, ",")
+	}
+	if *ignoreOwnerKindFlag != "" {
+		filter.ignoreOwnerKinds = strings.Split(*ignoreOwnerKindFlag, ",")
+	}
+	filter.skipHelmHooks = *skipHelmHooksFlag
+	if *skipHelmHooksFlag && *helmHooksReportFlag {
+		log.Fatalln("-helm-hooks-report lists the dumped Helm hooks, which -skip-helm-hooks skips")
+	}
+
+	if *sinceFileFlag != "" {
+		filter.since, err = readSinceFile(*sinceFileFlag)
+		if err != nil {
+			log.Fatalf("failed getting since time: %v\n", err)
+		}
+	}
+
+	writeOpts := writeOptions{
+		outDir:           *outdirFlag,
+		secretsDir:       *secretsDirFlag,
+		decodeSecrets:    *decodeSecretsFlag,
+		nameReplacer:     nameReplacer,
+		sidecarMetadata:  *sidecarMetadataFlag,
+		dumpTime:         start,
+		stateless:        *statelessFlag,
+		noSubdir:         *noSubdirFlag,
+		annotate:         *annotateOutputFlag,
+		clusterscopedDir: *clusterscopedDirFlag,
+		namespacedDir:    *namespacedDirFlag,
+		singleScope:      *clusterscopedFlag != *namespacedFlag,
+		stripFields:      stripStatusFields,
+		globalStrip:      globalStrip,
+		namespaceRenames: namespaceRenames,
+		deepRename:       *deepRenameFlag,
+		addLabels:        addedLabels,
+		overwriteLabels:  *overwriteLabelsFlag,
+		redact:           redactPattern,
+		gvkFilename:      *gvkInFilenameFlag,
+		keepRaw:          *keepRawFlag,
+		formatOverrides:  formatOverrides,
+		extractData:      *extractDataFlag,
+		maxNameLength:    int(*maxFileNameLengthFlag),
+		keepOwnerRefs:    *keepOwnerRefsFlag,
+		layout:           *layoutFlag,
+	}
+
+	if *mergeIntoFlag != "" {
+		if flag.NArg() == 0 {
+			log.Fatalln("-merge-into requires the dump directories as arguments")
+		}
+		sources, err := parseMergeSources(flag.Args())
+		if err != nil {
+			log.Fatalf("failed parsing merge sources: %v\n", err)
+		}
+		merged, err := mergeDumps(*mergeIntoFlag, sources)
+		if err != nil {
+			log.Fatalf("failed merging dumps: %v\n", err)
+		}
+		out.infof("merged %d files of %d dumps in %v\n", merged, len(sources), time.Since(start).Round(1*time.Millisecond))
+		os.Exit(0)
+	}
+
+	if *cleanStdinFlag {
+		writeOpts.contextName = "stdin"
+		var stdout io.Writer
+		if *outdirFlag == stdoutDir {
+			stdout = os.Stdout
+		}
+		cleaned, err := cleanObjects(os.Stdin, writeOpts, stdout)
+		if err != nil {
+			log.Fatalf("failed cleaning objects from stdin: %v\n", err)
+		}
+		if stdout == nil {
+			out.infof("cleaned %d manifests in %v\n", cleaned, time.Since(start).Round(1*time.Millisecond))
+		}
+		os.Exit(0)
+	}
+
+	configOpts := configOptions{
+		context:        *kubeContext,
+		kubeconfigPath: *kubeConfigPath,
+		userAgent:      *userAgentFlag,
+		proxyURL:       *proxyURLFlag,
+		tlsServerName:  *tlsServerNameFlag,
+		caDir:          *caDirFlag,
+		requestTimeout: *requestTimeoutFlag,
+	}
+
+	if *listContextsFlag {
+		if err := printContexts(os.Stdout, *kubeContext, *kubeConfigPath); err != nil {
+			log.Fatalf("failed printing contexts: %v\n", err)
+		}
+		os.Exit(0)
+	}
+
+	if *authHelpFlag {
+		config, err := clientConfigFromFlags(*kubeContext, *kubeConfigPath).ClientConfig()
+		if err != nil {
+			log.Fatalf("failed getting Kubernetes config: %v\n", err)
+		}
+		if err := printAuthHelp(os.Stdout, config); err != nil {
+			log.Fatalf("failed printing auth help: %v\n", err)
+		}
+		os.Exit(0)
+	}
+
+	if *checkFlag {
+		if err := checkConnectivity(context.Background(), os.Stdout, configOpts); err != nil {
+			log.Fatalf("check failed: %v\n", err)
+		}
+		os.Exit(0)
+	}
+
+	if *compareClustersFlag != "" {
+		threads := *listThreadsFlag
+		if threads == 0 {
+			threads = maxThreadsFlag.resolve(0)
+		}
+		differences, err := compareClusters(os.Stdout, *compareClustersFlag, configOpts, resFilter, filter, threads)
+		if err != nil {
+			log.Fatalf("failed comparing clusters: %v\n", err)
+		}
+		out.infof("found %d differing objects in %v\n", differences, time.Since(start).Round(1*time.Millisecond))
+		os.Exit(0)
+	}
+
+	kubeConfig, err := buildConfigFromFlags(configOpts)
+	if err != nil {
+		log.Fatalf("failed getting Kubernetes config: %v\n", err)
+	}
+
+	var contextName string
+	if *contextSubdirFlag || *annotateOutputFlag {
+		contextName, err = currentContextName(*kubeContext, *kubeConfigPath)
+		if err != nil {
+			log.Fatalf("failed getting context name: %v\n", err)
+		}
+		if contextName == "" {
+			contextName = "in-cluster"
+		}
+	}
+
+	if *contextSubdirFlag {
+		subdir := sanitizePathComponent(contextName)
+
+		*outdirFlag = filepath.Join(*outdirFlag, subdir)
+		if *secretsDirFlag != "" {
+			*secretsDirFlag = filepath.Join(*secretsDirFlag, subdir)
+		}
+	}
+
+	writeOpts.outDir = *outdirFlag
+	writeOpts.secretsDir = *secretsDirFlag
+	writeOpts.contextName = contextName
+
+	clientset, err := kubernetes.NewForConfig(kubeConfig)
+	if err != nil {
+		log.Fatalf("failed getting Kubernetes clientset: %v\n", err)
+	}
+
+	if maxThreadsFlag.auto {
+		requestStart := time.Now()
+		if _, err := clientset.Discovery().ServerVersion(); err != nil {
+			log.Printf("failed measuring the API latency: %v\n", err)
+		}
+		maxThreadsFlag.n = maxThreadsFlag.resolve(time.Since(requestStart))
+		out.debugf("using %d threads\n", maxThreadsFlag.n)
+	}
+	if *listThreadsFlag == 0 {
+		*listThreadsFlag = maxThreadsFlag.n
+	}
+	if *writeThreadsFlag == 0 {
+		*writeThreadsFlag = maxThreadsFlag.n
+	}
+
+	if report != nil {
+		serverVersion, err := clientset.Discovery().ServerVersion()
+		if err != nil {
+			log.Printf("failed getting server version: %v\n", err)
+		} else {
+			report.ClusterVersion = serverVersion.GitVersion
+		}
+	}
+
+	var discoveryClient discovery.DiscoveryInterface = clientset.DiscoveryClient
+	if !*noDiscoveryCacheFlag && *discoveryCacheTTLFlag > 0 {
+		serverVersion, err := clientset.Discovery().ServerVersion()
+		homeDir, homeErr := os.UserHomeDir()
+		switch {
+		case err != nil:
+			log.Printf("failed getting server version, not using the discovery cache: %v\n", err)
+		case homeErr != nil:
+			log.Printf("failed getting home dir, not using the discovery cache: %v\n", homeErr)
+		default:
+			discoveryClient = newCachedDiscovery(clientset.DiscoveryClient, discoveryCacheDir(homeDir, kubeConfig.Host, serverVersion.GitVersion), *discoveryCacheTTLFlag)
+		}
+	}
+
+	discovered, discoveryFailures, err := discoverResources(discoveryClient, groupVersionsFlag.values, *discoveryStrictFlag)
+	if err != nil {
+		log.Fatalf("failed discovering resources: %v\n", err)
+	}
+
+	if isFlagSet("complete-resources") {
+		if err := completeResources(os.Stdout, discovered, *completeResourcesFlag); err != nil {
+			log.Fatalf("failed completing resources: %v\n", err)
+		}
+		os.Exit(0)
+	}
+
+	if *listResourcesFlag {
+		if err := printResources(os.Stdout, discovered); err != nil {
+			log.Fatalf("failed printing resources: %v\n", err)
+		}
+		os.Exit(0)
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(kubeConfig)
+	if err != nil {
+		log.Fatalf("failed creating dynamic client: %v\n", err)
+	}
+
+	if *nsSelectorFlag != "" {
+		selected, err := selectNamespaces(context.Background(), dynamicClient, *nsSelectorFlag, wantNamespaces)
+		if err != nil {
+			log.Fatalf("failed listing namespaces matching the selector: %v\n", err)
+		}
+		if len(selected) == 0 {
+			log.Printf("no namespaces match the selector %q, skipping all namespaced objects\n", *nsSelectorFlag)
+			filter.namespaced = false
+		} else {
+			wantNamespaces = selected
+			filter.wantNamespaces = selected
+		}
+	}
+
+	var (
+		metadataClient   metadata.Interface
+		projectResources []string
+	)
+	if *projectionFlag != "" {
+		projectResources = strings.Split(strings.ToLower(*projectionFlag), ",")
+		metadataClient, err = metadata.NewForConfig(kubeConfig)
+		if err != nil {
+			log.Fatalf("failed creating metadata client: %v\n", err)
+		}
+	}
+
+	if *onlyCRDsFlag {
+		resFilter.onlyGroups, err = customResourceGroups(context.Background(), dynamicClient)
+		if err != nil {
+			log.Fatalf("failed getting custom resource definitions: %v\n", err)
+		}
+	}
+
+	// the resources to dump, all discovered resources are still recorded in the API resources snapshot
+	dumped := discovered
+	if *storageVersionOnlyFlag {
+		versions, err := crdStorageVersions(context.Background(), dynamicClient)
+		if err != nil {
+			log.Fatalf("failed getting storage versions of custom resource definitions: %v\n", err)
+		}
+		dumped = onlyStorageVersions(discovered, versions)
+	}
+	if *convertToFlag != "" {
+		dumped, err = convertToVersions(dumped, parseGroupVersions(*convertToFlag))
+		if err != nil {
+			log.Fatalf("failed converting versions: %v\n", err)
+		}
+	}
+
+	if *countOnlyFlag {
+		counts := countResources(context.Background(), dynamicClient, dumped, resFilter, wantNamespaces, *listThreadsFlag, true)
+		if err := printCounts(os.Stdout, counts); err != nil {
+			log.Fatalf("failed printing counts: %v\n", err)
+		}
+		os.Exit(0)
+	}
+
+	if *largeThresholdFlag > 0 && !*confirmLargeFlag {
+		estimate, unknown := estimateObjects(context.Background(), dynamicClient, dumped, resFilter, wantNamespaces, *listThreadsFlag)
+		out.debugf("estimated %d objects, %d resources with an unknown number of objects\n", estimate, unknown)
+		if estimate > int64(*largeThresholdFlag) {
+			log.Fatalf("estimated %d objects (without %d resources of unknown size) exceed the threshold of %d, use -confirm-large to dump them anyway\n", estimate, unknown, *largeThresholdFlag)
+		}
+	}
+
+	var (
+		writtenFiles uint64
+		waitGroup    sync.WaitGroup
+		threadGuard  = make(chan struct{}, *listThreadsFlag)
+		timings      = &resourceTimings{}
+		listOpts     = listOptions{
+			pageSize:        int64(*pageSizeFlag),
+			consistent:      *consistentFlag,
+			resourceVersion: *resourceVersionFlag,
+		}
+	)
+
+	if *archivePerNamespaceFlag {
+		writeOpts.archives = newArchiveWriters(*outdirFlag)
+	}
+	if *restoreScriptFlag {
+		writeOpts.restoreScript = newRestoreScript(dumped)
+	}
+	if *sqliteFlag != "" {
+		writeOpts.sqlite, err = openSQLite(*sqliteFlag)
+		if err != nil {
+			log.Fatalf("failed opening SQLite database: %v\n", err)
+		}
+	}
+	if *singleFileFlag != "" {
+		writeOpts.singleFile = newSingleFileWriter(filepath.Join(*outdirFlag, *singleFileFlag))
+	}
+	if *dedupeContentFlag {
+		writeOpts.dedupe = newContentIndex()
+	}
+	writeOpts.namespacedRes = namespacedResources(discovered)
+	writeOpts.noNamespaceDir = *noNamespaceDirFlag
+	if *compressThresholdFlag > 0 {
+		writeOpts.compressThreshold = int(*compressThresholdFlag)
+		writeOpts.compressed = &compressedFiles{}
+	}
+
+	if *outputFormatFlag != outputFormatTable && !*resumeFlag && *existingDirFlag != existingDirOverwrite {
+		nonEmpty, err := nonEmptyDir(*outdirFlag)
+		if err != nil {
+			log.Fatalf("failed checking output dir: %v\n", err)
+		}
+		if nonEmpty && *existingDirFlag == existingDirFail {
+			log.Fatalf("output dir %q isn't empty, use another one or '-existing-dir=%v'\n", *outdirFlag, existingDirOverwrite)
+		}
+		if nonEmpty {
+			log.Printf("warning: output dir %q isn't empty, the dump is mixed with its files\n", *outdirFlag)
+		}
+	}
+
+	var (
+		table        *objectTable
+		dumpProgress *progress
+	)
+	if *outputFormatFlag == outputFormatTable {
+		table = &objectTable{}
+	} else {
+		dumpProgress, err = openProgress(*outdirFlag, *resumeFlag)
+		if err != nil {
+			log.Fatalf("failed opening progress: %v\n", err)
+		}
+	}
+
+	if table == nil && *layoutFlag == layoutVelero {
+		if err := writeVeleroMetadata(*outdirFlag); err != nil {
+			log.Fatalf("failed writing velero metadata: %v\n", err)
+		}
+	}
+
+	if table == nil {
+		if err := writeReport(*outdirFlag, apiResourcesFilename, apiResourcesSnapshot(discovered)); err != nil {
+			log.Fatalf("failed writing API resources: %v\n", err)
+		}
+	}
+
+	var references *referenceTracker
+	if *followConfigRefsFlag {
+		references = newReferenceTracker()
+	}
+
+	var owners *ownerTracker
+	if *followOwnersFlag {
+		owners = newOwnerTracker()
+	}
+
+	var events *eventTracker
+	if *includeEventsForFlag != "" {
+		events = newEventTracker(strings.Split(*includeEventsForFlag, ","))
+	}
+
+	var fieldManagers *fieldManagersReport
+	if *fieldManagersFlag {
+		fieldManagers = &fieldManagersReport{}
+	}
+
+	var images *imagesReport
+	if *imagesReportFlag {
+		images = newImagesReport()
+	}
+
+	aggregated, err := aggregatedGroupVersions(context.Background(), dynamicClient)
+	if err != nil {
+		log.Printf("failed getting API services: %v\n", err)
+	}
+	unavailable := &unavailableReport{}
+	unavailable.addDiscoveryFailures(discoveryFailures, aggregated)
+	deprecations := &deprecationsReport{}
+
+	var finalizers *finalizersReport
+	if *finalizersReportFlag {
+		finalizers = &finalizersReport{}
+	}
+	var helmHooks *helmHooksReport
+	if *helmHooksReportFlag {
+		helmHooks = &helmHooksReport{}
+	}
+
+	// emit writes the object or adds it to the table
+	emit := func(gvr schema.GroupVersionResource, item unstructured.Unstructured) error {
+		if table != nil {
+			table.add(item)
+			return nil
+		}
+		if err := writeYAML(writeOpts, gvr, item); err != nil {
+			return err
+		}
+		if report != nil {
+			report.count(gvr, item.GetNamespace())
+		}
+		return nil
+	}
+
+	// record adds the object to the trackers and reports, the followed owners and references included
+	record := func(gvr schema.GroupVersionResource, item unstructured.Unstructured) {
+		if references != nil {
+			references.track(gvr, item)
+		}
+		if events != nil {
+			events.track(gvr, item)
+		}
+		deprecations.add(gvr, item)
+		if finalizers != nil {
+			finalizers.add(gvr, item)
+		}
+		if helmHooks != nil {
+			helmHooks.add(gvr, item)
+		}
+		if fieldManagers != nil {
+			fieldManagers.add(gvr, item)
+		}
+		if images != nil {
+			images.add(item)
+		}
+	}
+
+	var budget *byteBudget
+	if *maxInflightBytesFlag > 0 {
+		budget = newByteBudget(int64(*maxInflightBytesFlag))
+	}
+
+	var limiter *adaptiveLimiter
+	if *adaptiveConcurrencyFlag {
+		limiter = newAdaptiveLimiter(int(*listThreadsFlag), *adaptiveLatencyFlag)
+	}
+
+	var accessResults *accessReport
+	if *checkAccessFlag {
+		accessResults = &accessReport{}
+	}
+
+	retries := newRetryBudget(*maxTotalRetriesFlag)
+	clusterNamespaces := &namespaceNames{dynamicClient: dynamicClient, want: wantNamespaces}
+
+	var nsLimiter *namespaceLimiter
+	if *nsWriteThreadsFlag > 0 {
+		nsLimiter = newNamespaceLimiter(int(*nsWriteThreadsFlag))
+	}
+
+	var (
+		writeJobs = make(chan writeJob, *writeThreadsFlag)
+		writers   sync.WaitGroup
+	)
+	for i := uint64(0); i < *writeThreadsFlag; i++ {
+		writers.Add(1)
+		go func() {
+			defer writers.Done()
+
+			for job := range writeJobs {
+				if job.pending.timedOut() {
+					job.pending.done(false)
+					continue
+				}
+				if nsLimiter != nil {
+					nsLimiter.acquire(job.item.GetNamespace())
+				}
+				err := emit(job.gvr, job.item)
+				if nsLimiter != nil {
+					nsLimiter.release(job.item.GetNamespace())
+				}
+				if err != nil {
+					log.Printf("failed writing %v/%v: %v\n", job.item.GetNamespace(), job.item.GetName(), err)
+					job.pending.done(false)
+					continue
+				}
+				atomic.AddUint64(&writtenFiles, 1)
+				job.pending.done(true)
+			}
+		}()
+	}
+
+	var (
+		totalResources     int
+		completedResources int64
+		budgetExceeded     bool
+		timedOut           = &timedOutResources{}
+		serialLogs         *orderedLogs
+	)
+	if *serialLogsFlag {
+		serialLogs = newOrderedLogs(console)
+	}
+	totalResources = wantedResources(dumped, resFilter)
+
+dump:
+	for _, gv := range dumped {
+		for _, res := range gv.resources {
+			if skipResource(res, gv.group.Name, resFilter) {
+				continue
+			}
+
+			threadGuard <- struct{}{} // would block if guard channel is already filled
+
+			if *maxRuntimeFlag > 0 && time.Since(start) > *maxRuntimeFlag {
+				// stop spawning new resources, the running ones are allowed to finish
+				<-threadGuard
+				budgetExceeded = true
+				dumpProgress.markIncomplete()
+				break dump
+			}
+
+			// the sections are opened in discovery order, their output is printed in it
+			resOut, section := out, (*logSection)(nil)
+			if serialLogs != nil {
+				section = serialLogs.section()
+				resOut = out.with(section)
+			}
+			waitGroup.Add(1)
+			go func(res metav1.APIResource, group metav1.APIGroup, version metav1.GroupVersionForDiscovery) {
+				// completed by the writers once there are objects to write
+				handedOver := false
+				defer func() {
+					if !handedOver {
+						atomic.AddInt64(&completedResources, 1)
+						if section != nil {
+							section.close()
+						}
+					}
+					waitGroup.Done()
+					<-threadGuard
+				}()
+
+				gvr := schema.GroupVersionResource{
+					Group:    group.Name,
+					Version:  version.Version,
+					Resource: res.Name,
+				}
+
+				resOut.debugf("processing group=%v resource=%v\n", gvr.Group, gvr.Resource)
+
+				if dumpProgress.isDone(gvr) {
+					resOut.debugf("skipping group=%v resource=%v: already dumped\n", gvr.Group, gvr.Resource)
+					return
+				}
+
+				if accessResults != nil {
+					entries, err := checkListAccess(context.Background(), clientset, gvr, res.Namespaced, wantNamespaces)
+					if err != nil {
+						log.Printf("failed checking access for %v: %v\n", gvr.String(), err)
+						dumpProgress.markIncomplete()
+						return
+					}
+					accessResults.add(entries...)
+
+					if !anyAllowed(entries) {
+						resOut.debugf("skipping group=%v resource=%v: not allowed to list\n", gvr.Group, gvr.Resource)
+						return
+					}
+				}
+
+				var acquired int64
+				if budget != nil {
+					estimate, err := estimateListSize(context.Background(), dynamicClient, gvr)
+					if err != nil {
+						log.Printf("failed estimating size of %v: %v\n", gvr.String(), err)
+					}
+					if err != nil || estimate < 0 {
+						// unknown size, process it exclusively
+						estimate = budget.limit
+					}
+					acquired = budget.acquire(estimate)
+				}
+
+				resourceStart := time.Now()
+
+				resourceCtx := context.Background()
+				if *timeoutPerResourceFlag > 0 {
+					var cancel context.CancelFunc
+					resourceCtx, cancel = context.WithTimeout(resourceCtx, *timeoutPerResourceFlag)
+					defer cancel()
+				}
+
+				ctx := resourceCtx
+				if *listTimeoutFlag > 0 {
+					var cancel context.CancelFunc
+					ctx, cancel = context.WithTimeout(ctx, *listTimeoutFlag)
+					defer cancel()
+				}
+
+				var client dynamic.NamespaceableResourceInterface = dynamicClient.Resource(gvr)
+				if metadataClient != nil && matchesResource(projectResources, gvr.Resource, gvr.Group) {
+					client = newMetadataResource(metadataClient.Resource(gvr))
+				}
+				unstrList, err := listWithRetries(ctx, retries, func() (*unstructured.UnstructuredList, error) {
+					// every attempt is reported to the limiter, so retried throttling backs off too
+					if limiter != nil {
+						limiter.acquire()
+					}
+					listStart := time.Now()
+					var (
+						list *unstructured.UnstructuredList
+						err  error
+					)
+					if *parallelNamespacesFlag && res.Namespaced {
+						list, err = listNamespaces(ctx, client, wantNamespaces, listOpts, threadGuard, nil)
+					} else {
+						list, err = listWithFallback(ctx, client, gvr, res.Namespaced, clusterNamespaces, listOpts, threadGuard, resOut)
+					}
+					if limiter != nil {
+						limiter.release(time.Since(listStart), apierrors.IsTooManyRequests(err))
+					}
+					return list, err
+				})
+				if err != nil {
+					if budget != nil {
+						budget.release(acquired)
+					}
+					dumpProgress.markIncomplete()
+					switch {
+					case errors.Is(resourceCtx.Err(), context.DeadlineExceeded):
+						timedOut.add(gvr)
+						err = fmt.Errorf("abandoned after timeout per resource of %v", *timeoutPerResourceFlag)
+					case errors.Is(ctx.Err(), context.DeadlineExceeded):
+						err = fmt.Errorf("timed out after %v", *listTimeoutFlag)
+					}
+					if aggregated[version.GroupVersion] {
+						unavailable.add(gvr, err)
+					}
+					log.Printf("failed listing %v: %v\n", gvr.String(), err)
+					return
+				}
+
+				// finished by the writer of the last object, or right away when there is nothing to write
+				var pending *pendingWrites
+				handedOver = true
+				pending = newPendingWrites(func(complete bool) {
+					if budget != nil {
+						budget.release(acquired)
+					}
+					if pending.abandoned() {
+						timedOut.add(gvr)
+						log.Printf("abandoned %v after timeout per resource of %v\n", gvr.String(), *timeoutPerResourceFlag)
+					}
+
+					atomic.AddInt64(&completedResources, 1)
+					timing := resourceTiming{gvr: gvr, duration: time.Since(resourceStart), items: len(unstrList.Items)}
+					timings.add(timing)
+					if section != nil {
+						// without the duration, so the output of two runs only differs in the objects
+						resOut.debugf("finished group=%v resource=%v items=%d\n", gvr.Group, gvr.Resource, timing.items)
+						section.close()
+					} else {
+						resOut.debugf("finished group=%v resource=%v took=%v items=%d\n", gvr.Group, gvr.Resource, timing.duration.Round(time.Millisecond), timing.items)
+					}
+
+					if !complete {
+						dumpProgress.markIncomplete()
+						return
+					}
+					if err := dumpProgress.markDone(gvr); err != nil {
+						log.Printf("failed recording progress of %v: %v\n", gvr.String(), err)
+					}
+				})
+				if *timeoutPerResourceFlag > 0 {
+					pending.deadline = resourceStart.Add(*timeoutPerResourceFlag)
+				}
+
+				items := unstrList.Items
+				if nsLimiter != nil {
+					// let the writers alternate between the namespaces
+					items = interleaveByNamespace(items)
+				}
+
+				for _, item := range items {
+					if pending.timedOut() {
+						break
+					}
+					if skipItem(item, scopedFilter(filter, res.Namespaced, item)) {
+						continue
+					}
+
+					resOut.tracef("processing manifest group=%v version=%v resource=%v namespace=%v name=%q\n", gvr.Group, gvr.Version, gvr.Resource, item.GetNamespace(), item.GetName())
+
+					if owners != nil {
+						owners.track(item)
+					}
+					record(gvr, item)
+
+					pending.add()
+					writeJobs <- writeJob{gvr: gvr, item: item, pending: pending}
+				}
+				pending.done(!pending.abandoned())
+			}(res, gv.group, gv.version)
+		}
+	}
+
+	// all listers are done, let the writers drain the remaining objects
+	waitGroup.Wait()
+	close(writeJobs)
+	writers.Wait()
+
+	if owners != nil {
+		written := followOwners(context.Background(), dynamicClient, discoveredKinds(dumped), owners, func(gvr schema.GroupVersionResource, item unstructured.Unstructured) error {
+			out.tracef("processing owner group=%v version=%v resource=%v namespace=%v name=%q\n", gvr.Group, gvr.Version, gvr.Resource, item.GetNamespace(), item.GetName())
+			record(gvr, item)
+			return emit(gvr, item)
+		})
+		writtenFiles += written
+	}
+
+	if references != nil {
+		written := followReferences(context.Background(), dynamicClient, references, func(gvr schema.GroupVersionResource, item unstructured.Unstructured) error {
+			out.tracef("processing reference group=%v version=%v resource=%v namespace=%v name=%q\n", gvr.Group, gvr.Version, gvr.Resource, item.GetNamespace(), item.GetName())
+			record(gvr, item)
+			return emit(gvr, item)
+		})
+		writtenFiles += written
+	}
+
+	if events != nil {
+		written, err := includeEvents(context.Background(), dynamicClient, events, emit)
+		if err != nil {
+			log.Printf("failed including events: %v\n", err)
+			dumpProgress.markIncomplete()
+		}
+		writtenFiles += written
+	}
+
+	if writeOpts.archives != nil {
+		if err := writeOpts.archives.Close(); err != nil {
+			log.Fatalf("failed closing archives: %v\n", err)
+		}
+	}
+	if writeOpts.singleFile != nil && table == nil {
+		if err := writeOpts.singleFile.write(); err != nil {
+			log.Fatalf("failed writing single file: %v\n", err)
+		}
+	}
+	if writeOpts.sqlite != nil {
+		if err := writeOpts.sqlite.close(); err != nil {
+			log.Fatalf("failed writing SQLite database: %v\n", err)
+		}
+	}
+
+	// the table is only an inventory, the next dump still has to include the objects
+	if *sinceFileFlag != "" && table == nil && dumpProgress.isComplete() {
+		if err := writeSinceFile(*sinceFileFlag, start); err != nil {
+			log.Printf("failed updating since file: %v\n", err)
+		}
+	}
+
+	if err := dumpProgress.finish(); err != nil {
+		log.Printf("failed finishing progress: %v\n", err)
+	}
+
+	if table != nil {
+		if err := table.print(os.Stdout, time.Now()); err != nil {
+			log.Fatalf("failed printing table: %v\n", err)
+		}
+	} else {
+		if err := unavailable.write(*outdirFlag); err != nil {
+			log.Fatalf("failed writing unavailable resources: %v\n", err)
+		}
+		if err := deprecations.write(*outdirFlag); err != nil {
+			log.Fatalf("failed writing deprecations: %v\n", err)
+		}
+		if writeOpts.compressed != nil {
+			if err := writeOpts.compressed.write(*outdirFlag); err != nil {
+				log.Fatalf("failed writing compressed files: %v\n", err)
+			}
+		}
+		if writeOpts.restoreScript != nil {
+			if err := writeOpts.restoreScript.write(*outdirFlag); err != nil {
+				log.Fatalf("failed writing restore script: %v\n", err)
+			}
+		}
+		if accessResults != nil {
+			if err := accessResults.write(*outdirFlag); err != nil {
+				log.Fatalf("failed writing access report: %v\n", err)
+			}
+		}
+		if fieldManagers != nil {
+			if err := fieldManagers.write(*outdirFlag); err != nil {
+				log.Fatalf("failed writing field managers report: %v\n", err)
+			}
+		}
+		if images != nil {
+			if err := images.write(*outdirFlag); err != nil {
+				log.Fatalf("failed writing images report: %v\n", err)
+			}
+		}
+		if finalizers != nil {
+			if err := finalizers.write(*outdirFlag); err != nil {
+				log.Fatalf("failed writing finalizers report: %v\n", err)
+			}
+		}
+		if helmHooks != nil {
+			if err := helmHooks.write(*outdirFlag); err != nil {
+				log.Fatalf("failed writing helm hooks report: %v\n", err)
+			}
+		}
+	}
+
+	out.infof("loaded %d manifests in %v\n", writtenFiles, time.Since(start).Round(1*time.Millisecond))
+
+	if n := deprecations.len(); n > 0 && table == nil {
+		out.infof("found %d objects of deprecated API versions, see %q\n", n, deprecationsFilename)
+	}
+
+	if budgetExceeded {
+		out.infof("%s\n", budgetSummary(*maxRuntimeFlag, int(completedResources), totalResources))
+	}
+
+	if resources := timedOut.sorted(); len(resources) > 0 {
+		out.infof("abandoned %d resources after timeout per resource of %v: %v\n", len(resources), *timeoutPerResourceFlag, strings.Join(resources, "; "))
+	}
+
+	if limiter != nil {
+		out.debugf("adaptive concurrency limit: %d\n", limiter.currentLimit())
+	}
+
+	if out.enabled(levelDebug) {
+		if *serialLogsFlag {
+			out.debugf("resources:\n")
+			for _, timing := range timings.byResource() {
+				// without the duration, so the summaries of two runs only differ in the objects
+				out.debugf("  group=%v version=%v resource=%v items=%d\n", timing.gvr.Group, timing.gvr.Version, timing.gvr.Resource, timing.items)
+			}
+		} else {
+			out.debugf("slowest resources:\n")
+			for _, timing := range timings.slowest(10) {
+				out.debugf("  group=%v resource=%v took=%v items=%d\n", timing.gvr.Group, timing.gvr.Resource, timing.duration.Round(time.Millisecond), timing.items)
+			}
+		}
+	}
+
+	if report != nil {
+		if err := report.write(*reportFlag, time.Now()); err != nil {
+			log.Fatalf("failed writing report: %v\n", err)
+		}
+	}
+}
+
+// wantedResources counts the resources which pass the filter, without subresources.
+func wantedResources(dumped []groupVersionResources, resFilter resourceFilter) int {
+	var wanted int
+	for _, gv := range dumped {
+		for _, res := range gv.resources {
+			if !skipResource(res, gv.group.Name, resFilter) {
+				wanted++
+			}
+		}
+	}
+	return wanted
+}
+
+// budgetSummary describes a dump stopped by the time budget, by the completed resources of the wanted ones.
+func budgetSummary(budget time.Duration, completed, total int) string {
+	percent := 100.0
+	if total > 0 {
+		percent = 100 * float64(completed) / float64(total)
+	}
+	return fmt.Sprintf("partial dump due to time budget of %v: completed %d of %d wanted resources (%.1f%%)", budget, completed, total, percent)
+}
+
+// resourceAndGroupName combines the resource and group name as the resource name might not be unique otherwise.
+// Example content of the variables:
+//
+//	resource: "pod"		group: ""
+//	resource: "pod"		group: "metrics.k8s.io"
+func resourceAndGroupName(gvr schema.GroupVersionResource) string {
+	return strings.TrimSuffix(fmt.Sprintf("%s.%s", gvr.Resource, gvr.Group), ".")
+}
+
+type resourceFilter struct {
+	wantResources   []string
+	ignoreResources []string
+	// wantKinds are matched case-insensitively, a resource matching either wantResources or wantKinds is dumped
+	wantKinds []string
+	// skipKinds are matched case-insensitively, a resource matching either ignoreResources or skipKinds is skipped
+	skipKinds []string
+	// denyAll skips all resources when no resources are specified
+	denyAll bool
+	// onlyGroups skips the resources of all other groups, unless nil
+	onlyGroups map[string]bool
+}
+
+func skipResource(res metav1.APIResource, group string, filter resourceFilter) bool {
+	// check if we can even 'list' the resource
+	if !slices.Contains(res.Verbs, "list") {
+		return true
+	}
+
+	// skip subresources
+	// TODO: maybe there is a better way to not get them in the first place
+	if strings.Contains(res.Name, "/") {
+		return true
+	}
+
+	// not one of the allowed groups
+	if filter.onlyGroups != nil && !filter.onlyGroups[group] {
+		return true
+	}
+
+	wantResources := len(filter.wantResources) > 0 && filter.wantResources[0] != ""
+	wantKinds := len(filter.wantKinds) > 0
+
+	// nothing is dumped unless explicitly specified
+	if filter.denyAll && !wantResources && !wantKinds {
+		return true
+	}
+
+	// check if we got the specified resources or kinds (if any were specified)
+	if (wantResources || wantKinds) &&
+		!(wantResources && matchesResource(filter.wantResources, res.Name, group)) &&
+		!(wantKinds && slices.ContainsFunc(filter.wantKinds, func(kind string) bool { return strings.EqualFold(kind, res.Kind) })) {
+		return true
+	}
+
+	// check if we got a resource to ignore (if any resources were specified)
+	if len(filter.ignoreResources) > 0 && filter.ignoreResources[0] != "" && matchesResource(filter.ignoreResources, res.Name, group) {
+		return true
+	}
+
+	// check if we got a kind to ignore
+	if slices.ContainsFunc(filter.skipKinds, func(kind string) bool { return strings.EqualFold(kind, res.Kind) }) {
+		return true
+	}
+
+	return false
+}
+
+// matchesResource reports whether any of the patterns matches the resource.
+// Plain names have to match exactly, patterns containing '*' or '?' are matched
+// using filepath.Match against the resource and its group (e.g. '*.apps').
+func matchesResource(patterns []string, resource, group string) bool {
+	for _, pattern := range patterns {
+		if !strings.ContainsAny(pattern, "*?") {
+			if pattern == resource {
+				return true
+			}
+			continue
+		}
+
+		for _, name := range []string{resource, strings.TrimSuffix(resource+"."+group, ".")} {
+			if ok, _ := filepath.Match(pattern, name); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// annotation matches the annotation with the key and, when set, the value.
+type annotation struct {
+	key   string
+	value *string
+}
+
+// parseAnnotations parses 'key=value' pairs, only 'key' matches any value.
+func parseAnnotations(pairs []string) []annotation {
+	var annotations []annotation
+	for _, pair := range pairs {
+		key, value, found := strings.Cut(pair, "=")
+		a := annotation{key: key}
+		if found {
+			a.value = &value
+		}
+		annotations = append(annotations, a)
+	}
+	return annotations
+}
+
+func (a annotation) matches(annotations map[string]string) bool {
+	value, ok := annotations[a.key]
+	return ok && (a.value == nil || *a.value == value)
+}
+
+type itemFilter struct {
+	namespaced         bool
+	clusterscoped      bool
+	wantNamespaces     []string
+	ignoreNamespaces   []string
+	skipAnnotations    []annotation
+	requireAnnotations []annotation
+	// since skips objects which weren't modified after it, unless it's zero
+	since time.Time
+	// excludeOwned skips objects with a controller of any of the excludeOwnedKinds, or of any kind when empty
+	excludeOwned      bool
+	excludeOwnedKinds []string
+	// ignoreOwnerKinds skips objects with a controller of any of the kinds
+	ignoreOwnerKinds []string
+	// jsonPath skips the objects it doesn't match, unless nil
+	jsonPath *jsonPathFilter
+	// skipHelmHooks skips the objects Helm runs as hooks
+	skipHelmHooks bool
+}
+
+// scopedFilter returns the filter for the object of a resource. An object of a namespaced resource without a namespace
+// is written below the namespaced directory, so it's filtered like a namespaced object, not like a cluster-scoped one.
+func scopedFilter(filter itemFilter, namespaced bool, item unstructured.Unstructured) itemFilter {
+	if namespaced && item.GetNamespace() == "" {
+		filter.clusterscoped = filter.namespaced
+	}
+	return filter
+}
+
+func skipItem(item unstructured.Unstructured, filter itemFilter) bool {
+	// item with namespace but we skip namespaced items
+	if item.GetNamespace() != "" && !filter.namespaced {
+		return true
+	}
+	// item clusterscoped but we skip them
+	if item.GetNamespace() == "" && !filter.clusterscoped {
+		return true
+	}
+	// specific namespaces specied but doesn't match
+	if len(filter.wantNamespaces) > 0 && filter.wantNamespaces[0] != "" && !slices.Contains(filter.wantNamespaces, item.GetNamespace()) {
+		return true
+	}
+	// ignore specific namespaces and it matches
+	if len(filter.ignoreNamespaces) > 0 && filter.ignoreNamespaces[0] != "" && slices.Contains(filter.ignoreNamespaces, item.GetNamespace()) {
+		return true
+	}
+	// any of the skip annotations matches
+	for _, a := range filter.skipAnnotations {
+		if a.matches(item.GetAnnotations()) {
+			return true
+		}
+	}
+	// required annotations specified but none matches
+	if len(filter.requireAnnotations) > 0 && !slices.ContainsFunc(filter.requireAnnotations, func(a annotation) bool { return a.matches(item.GetAnnotations()) }) {
+		return true
+	}
+	// not modified since the last dump
+	if !filter.since.IsZero() && !lastModified(item).After(filter.since) {
+		return true
+	}
+	// controlled by another object
+	if filter.excludeOwned {
+		for _, ref := range item.GetOwnerReferences() {
+			if ref.Controller != nil && *ref.Controller && (len(filter.excludeOwnedKinds) == 0 || slices.Contains(filter.excludeOwnedKinds, ref.Kind)) {
+				return true
+			}
+		}
+	}
+	// controlled by an object of an ignored kind
+	for _, ref := range item.GetOwnerReferences() {
+		if ref.Controller != nil && *ref.Controller && slices.Contains(filter.ignoreOwnerKinds, ref.Kind) {
+			return true
+		}
+	}
+	// Helm hook, which isn't part of the release
+	if filter.skipHelmHooks && isHelmHook(item) {
+		return true
+	}
+	// the JSONPath expression isn't truthy
+	if filter.jsonPath != nil && !filter.jsonPath.matches(item) {
+		return true
+	}
+
+	return false
+}
+
+// handling of an output directory which isn't empty
+const (
+	existingDirWarn      = "warn"
+	existingDirFail      = "fail"
+	existingDirOverwrite = "overwrite"
+)
+
+// nonEmptyDir reports whether the directory exists and contains any entries.
+func nonEmptyDir(dir string) (bool, error) {
+	f, err := os.Open(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	_, err = f.Readdirnames(1)
+	if errors.Is(err, io.EOF) {
+		return false, nil
 	}
-	return defaultVal
+	return err == nil, err
 }
 
-func lookupEnvBool(key string, defaultVal bool) bool {
-	if val, ok := os.LookupEnv(key); ok {
-		parsed, err := strconv.ParseBool(val)
-		if err != nil {
-			log.Fatalf("failed parsing %q as bool (%q): %v", val, key, err)
+// flatSeparator joins the path components in the filename when writing without subdirectories.
+// Namespace and resource names can't contain underscores, and the object name is always the last
+// component, which keeps the encoded filenames unique.
+const flatSeparator = "__"
+
+// filenameHashLength is the number of hex digits of the hash appended to truncated filenames.
+const filenameHashLength = 16
+
+// minFileNameLength fits the hash of a truncated name with the longest suffix, e.g. '-<hash>.meta.json.gz'.
+const minFileNameLength = 1 + filenameHashLength + len(metadataSuffix) + len(".gz")
+
+// truncateFilename shortens the name to at most max bytes, replacing the end with a hash of the whole name,
+// so different long names with the same prefix stay unique. It reports whether the name was truncated.
+// Below the length of the hash, only the start of the hash is kept.
+func truncateFilename(name string, max int) (string, bool) {
+	if len(name) <= max {
+		return name, false
+	}
+
+	hash := fmt.Sprintf("-%x", sha256.Sum256([]byte(name)))[:1+filenameHashLength]
+	if max < len(hash) {
+		if max < 1 {
+			max = 1
 		}
-		return parsed
+		return hash[1 : 1+max], true
 	}
-	return defaultVal
+	keep := max - len(hash)
+	// don't cut a multi-byte character in half
+	for keep > 0 && !utf8.RuneStart(name[keep]) {
+		keep--
+	}
+	return name[:keep] + hash, true
 }
 
-func lookupEnvUint64(key string, defaultVal uint64) uint64 {
-	if val, ok := os.LookupEnv(key); ok {
-		parsed, err := strconv.ParseUint(val, 10, 64)
-		if err != nil {
-			log.Fatalf("failed parsing %q as uint64 (%q): %v", val, key, err)
+// gvkFilename appends the group, version and kind to the name, e.g. 'name.apps.v1.Deployment'.
+// The group of the core API is omitted.
+func gvkFilename(name string, gvk schema.GroupVersionKind) string {
+	parts := []string{name}
+	for _, part := range []string{gvk.Group, gvk.Version, gvk.Kind} {
+		if part != "" {
+			parts = append(parts, part)
 		}
-		return parsed
 	}
-	return defaultVal
+	return strings.Join(parts, ".")
 }
 
-func main() {
-	start := time.Now()
+type writeOptions struct {
+	outDir        string
+	secretsDir    string
+	stateless     bool
+	decodeSecrets bool
+	nameReplacer  *strings.Replacer
+	// sidecarMetadata writes the provenance of each object next to the manifest
+	sidecarMetadata bool
+	dumpTime        time.Time
+	noSubdir        bool
+	archives        *archiveWriters
+	// annotate prepends a provenance comment to each manifest
+	annotate    bool
+	contextName string
+	// top directories of the scopes, the defaults are used when empty
+	clusterscopedDir string
+	namespacedDir    string
+	// singleScope omits the directory of the scope, as only one of the scopes is dumped
+	singleScope      bool
+	stripFields      []fieldPath
+	globalStrip      []fieldPath
+	namespaceRenames map[string]string
+	deepRename       bool
+	addLabels        map[string]string
+	overwriteLabels  bool
+	redact           *regexp.Regexp
+	gvkFilename      bool
+	keepRaw          bool
+	// formatOverrides maps resources to their format, all other resources are written as YAML
+	formatOverrides map[string]string
+	// extractData writes the data keys of ConfigMaps and Secrets additionally to the manifests
+	extractData bool
+	// maxNameLength is the maximum length of the filenames in bytes, 0 for no limit
+	maxNameLength int
+	// keepOwnerRefs keeps the owner references when the state is cleaned
+	keepOwnerRefs bool
+	// layout is the directory structure, the default layout when empty
+	layout string
+	// dedupe links files with the same content to the first one, nil for copies
+	dedupe *contentIndex
+	// namespacedRes are the resources which are namespaced according to the discovery,
+	// their objects without a namespace are written below noNamespaceDir instead of the cluster-scoped directory
+	namespacedRes  map[schema.GroupVersionResource]bool
+	noNamespaceDir string
+	// restoreScript records the written manifests, nil for none
+	restoreScript *restoreScript
+	// singleFile combines all objects except separated secrets into one file, nil for one file per object
+	singleFile *singleFileWriter
+	// sqlite additionally receives the cleaned objects, nil for none
+	sqlite *sqliteWriter
+	// compressThreshold is the size in bytes above which files are gzipped, 0 for no compression
+	compressThreshold int
+	compressed        *compressedFiles
+}
 
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		log.Fatalf("failed getting user home dir: %v\n", err)
-	}
+// defaultSystemNamespaces are the namespaces created by Kubernetes itself.
+const defaultSystemNamespaces = "kube-system,kube-public,kube-node-lease"
 
-	var (
-		kubeConfigPath       = flag.String("config", lookupEnvString("CONFIG", filepath.Join(homeDir, ".kube", "config")), "path to the kubeconfig, empty for in-cluster config")
-		kubeContext          = flag.String("context", lookupEnvString("CONTEXT", ""), "context from the kubeconfig, empty for default")
-		outdirFlag           = flag.String("dir", lookupEnvString("DIR", "dump"), "output directory for the dumps")
-		resourcesFlag        = flag.String("resources", lookupEnvString("RESOURCES", ""), "resource to dump (e.g. 'configmaps,secrets'), empty for all")
-		ignoreResourcesFlag  = flag.String("ignore-resources", lookupEnvString("IGNORE_RESOURCES", ""), "resource to ignore (e.g. 'configmaps,secrets')")
-		namespacesFlag       = flag.String("namespaces", lookupEnvString("NAMESPACES", ""), "namespace to dump (e.g. 'ns1,ns2'), empty for all")
-		ignoreNamespacesFlag = flag.String("ignore-namespaces", lookupEnvString("IGNORE_NAMESPACES", ""), "namespace to ignore (e.g. 'ns1,ns2')")
-		clusterscopedFlag    = flag.Bool("clusterscoped", lookupEnvBool("CLUSTERSCOPED", true), "dump cluster-wide resources")
-		namespacedFlag       = flag.Bool("namespaced", lookupEnvBool("NAMESPACED", true), "dump namespaced resources")
-		statelessFlag        = flag.Bool("stateless", lookupEnvBool("STATELESS", true), "remove fields containing a state of the resource")
-		versionFlag          = flag.Bool("version", lookupEnvBool("VERSION", false), fmt.Sprintf("print version information of this release (%v)", version))
-		maxThreadsFlag       = flag.Uint64("threads", lookupEnvUint64("THREADS", 10), "maximum number of threads (minimum 1)")
-		verbosityFlag        = flag.Uint64("verbosity", lookupEnvUint64("VERBOSITY", 1), "verbosity of the output (0-3)")
-	)
-	flag.Parse()
+// withSystemNamespaces adds the comma-separated system namespaces to the namespaces to ignore.
+func withSystemNamespaces(ignoreNamespaces []string, systemNamespaces string) []string {
+	var combined []string
+	for _, ns := range append(ignoreNamespaces, strings.Split(strings.ToLower(systemNamespaces), ",")...) {
+		// an empty namespace would match all cluster-scoped objects
+		if ns = strings.TrimSpace(ns); ns != "" {
+			combined = append(combined, ns)
+		}
+	}
+	return combined
+}
 
-	if *versionFlag || *verbosityFlag > 1 {
-		fmt.Printf("version: %v\n", version)
-		fmt.Printf("commit: %v\n", commit)
-		fmt.Printf("date: %v\n", date)
+const (
+	defaultClusterscopedDir = "clusterscoped"
+	defaultNamespacedDir    = "namespaced"
+	// defaultNoNamespaceDir isn't a valid namespace name, so it can't collide with a namespace
+	defaultNoNamespaceDir = "_no-namespace"
+)
 
-		if *versionFlag {
-			os.Exit(0)
+// validateScopeDirs checks that the directory names of the scopes are single, distinct path components.
+func validateScopeDirs(clusterscopedDir, namespacedDir string) error {
+	for _, dir := range []string{clusterscopedDir, namespacedDir} {
+		if !safeDirName(dir) {
+			return fmt.Errorf("%q is not a safe directory name", dir)
 		}
 	}
+	if clusterscopedDir == namespacedDir {
+		return fmt.Errorf("the cluster-scoped and namespaced directories must differ, both are %q", clusterscopedDir)
+	}
+	return nil
+}
 
-	if *maxThreadsFlag <= 0 {
-		log.Fatalln("minimum number of threads is 1")
+// safeDirName reports whether the name is a single path component.
+func safeDirName(dir string) bool {
+	return dir != "" && dir != "." && dir != ".." && !strings.ContainsAny(dir, unsafeFilenameChars)
+}
+
+func isSecret(item unstructured.Unstructured) bool {
+	gvk := item.GroupVersionKind()
+	return gvk.Group == "" && gvk.Kind == "Secret"
+}
+
+const (
+	defaultNameReplaceChars = ":" // windows compatibility
+	defaultNameReplaceChar  = "_"
+	// unsafeFilenameChars can't be used in filenames on at least one common platform
+	unsafeFilenameChars = `/\<>:"|?*`
+)
+
+var defaultNameReplacer = strings.NewReplacer(defaultNameReplaceChars, defaultNameReplaceChar)
+
+// newNameReplacer returns a replacer which replaces each of the chars in object names with the replacement.
+func newNameReplacer(chars, replacement string) (*strings.Replacer, error) {
+	if utf8.RuneCountInString(replacement) != 1 {
+		return nil, fmt.Errorf("replacement %q must be a single character", replacement)
+	}
+	r, _ := utf8.DecodeRuneInString(replacement)
+	if strings.ContainsRune(unsafeFilenameChars, r) || unicode.IsControl(r) || unicode.IsSpace(r) || r == '.' {
+		return nil, fmt.Errorf("replacement %q is not safe to use in filenames", replacement)
+	}
+	if strings.ContainsRune(chars, r) {
+		return nil, fmt.Errorf("replacement %q is also a character to replace", replacement)
 	}
 
-	var (
-		wantResources    = strings.Split(strings.ToLower(*resourcesFlag), ",")
-		wantNamespaces   = strings.Split(strings.ToLower(*namespacesFlag), ",")
-		ignoreResources  = strings.Split(strings.ToLower(*ignoreResourcesFlag), ",")
-		ignoreNamespaces = strings.Split(strings.ToLower(*ignoreNamespacesFlag), ",")
-	)
+	var oldnew []string
+	for _, c := range chars {
+		oldnew = append(oldnew, string(c), replacement)
+	}
+	return strings.NewReplacer(oldnew...), nil
+}
 
-	kubeConfig, err := buildConfigFromFlags(*kubeContext, *kubeConfigPath)
-	if err != nil {
-		log.Fatalf("failed getting Kubernetes config: %v\n", err)
+// provenanceHeader returns a YAML comment describing where the object was dumped from.
+func provenanceHeader(contextName string, gvr schema.GroupVersionResource, dumpTime time.Time) []byte {
+	// a line break would end the comment
+	contextName = strings.NewReplacer("\r", " ", "\n", " ").Replace(contextName)
+	return []byte(fmt.Sprintf("# dumped from context=%s group=%s version=%s resource=%s at %s\n", contextName, gvr.Group, gvr.Version, gvr.Resource, dumpTime.UTC().Format(time.RFC3339)))
+}
+
+// outputFile is a file written for an object, named after the object with the suffix appended.
+type outputFile struct {
+	suffix  string
+	content []byte
+}
+
+// renderManifest transforms the item and returns it as YAML.
+func renderManifest(opts writeOptions, gvr schema.GroupVersionResource, item unstructured.Unstructured) ([]byte, error) {
+	if opts.namespaceRenames != nil {
+		renameNamespace(item, opts.namespaceRenames, opts.deepRename)
+	}
+	if opts.stateless {
+		ownerReferences := item.GetOwnerReferences()
+		cleanState(item)
+		if opts.keepOwnerRefs && len(ownerReferences) > 0 {
+			item.SetOwnerReferences(ownerReferences)
+		}
+	}
+	for _, path := range opts.stripFields {
+		removeFieldPath(item.Object, path)
+	}
+	for _, path := range opts.globalStrip {
+		removeFieldPath(item.Object, path)
+	}
+	if opts.decodeSecrets && isSecret(item) {
+		decodeSecretData(item)
+	}
+	// after decoding, so decoded secret values are redacted as well
+	if opts.redact != nil {
+		redactFields(item.Object, opts.redact)
+	}
+	if opts.addLabels != nil {
+		addLabels(item, opts.addLabels, opts.overwriteLabels)
 	}
 
-	clientset, err := kubernetes.NewForConfig(kubeConfig)
+	yamlBytes, err := yaml.Marshal(item.Object)
 	if err != nil {
-		log.Fatalf("failed getting Kubernetes clientset: %v\n", err)
+		return nil, fmt.Errorf("failed marshalling: %v", err)
+	}
+	yamlBytes = normalizeNewlines(yamlBytes)
+	if opts.annotate {
+		yamlBytes = append(provenanceHeader(opts.contextName, gvr, opts.dumpTime), yamlBytes...)
 	}
+	return yamlBytes, nil
+}
 
-	groups, err := clientset.DiscoveryClient.ServerGroups()
-	if err != nil {
-		log.Fatalf("failed getting server groups: %v\n", err)
+func writeYAML(opts writeOptions, gvr schema.GroupVersionResource, item unstructured.Unstructured) error {
+	var files []outputFile
+
+	if opts.sidecarMetadata {
+		// has to be collected before the state is cleaned
+		sidecar, err := sidecarMetadata(gvr, item, opts.dumpTime)
+		if err != nil {
+			return fmt.Errorf("failed creating metadata sidecar: %v", err)
+		}
+		files = append(files, outputFile{suffix: metadataSuffix, content: sidecar})
 	}
 
-	dynamicClient, err := dynamic.NewForConfig(kubeConfig)
+	if opts.keepRaw {
+		// has to be copied before the object is cleaned, only the fields which must never be written are removed
+		raw := item.DeepCopy().Object
+		for _, path := range opts.globalStrip {
+			removeFieldPath(raw, path)
+		}
+		if opts.redact != nil {
+			redactFields(raw, opts.redact)
+		}
+		rawBytes, err := yaml.Marshal(raw)
+		if err != nil {
+			return fmt.Errorf("failed marshalling raw object: %v", err)
+		}
+		files = append(files, outputFile{suffix: rawSuffix, content: normalizeNewlines(rawBytes)})
+	}
+
+	// has to be read before the state, including the uid, is cleaned
+	objName, uid := item.GetName(), item.GetUID()
+
+	yamlBytes, err := renderManifest(opts, gvr, item)
 	if err != nil {
-		log.Fatalf("failed creating dynamic client: %v\n", err)
+		return err
+	}
+	if opts.sqlite != nil {
+		if err := opts.sqlite.add(gvr, item, uid); err != nil {
+			return err
+		}
+	}
+	format := outputFormat(opts.formatOverrides, gvr)
+	if format == formatExtract {
+		// extracted from the transformed object, so redacted values stay redacted
+		files = append(extractData(item), files...)
+	} else {
+		files = append([]outputFile{{suffix: ".yaml", content: yamlBytes}}, files...)
+		if opts.extractData && isExtractable(gvr) {
+			files = append(files, extractData(item)...)
+		}
 	}
 
-	var (
-		writtenFiles uint64
-		waitGroup    sync.WaitGroup
-		threadGuard  = make(chan struct{}, *maxThreadsFlag)
-	)
+	if objName == "" {
+		// avoid collisions of objects without a name
+		objName = string(uid)
+		if objName == "" {
+			objName = fmt.Sprintf("unnamed-%x", sha256.Sum256(yamlBytes))[:len("unnamed-")+16]
+		}
+		log.Printf("warning: %v object in namespace %q has no name, using %q\n", item.GetKind(), item.GetNamespace(), objName)
+	}
+	nameReplacer := opts.nameReplacer
+	if nameReplacer == nil {
+		nameReplacer = defaultNameReplacer
+	}
+	if opts.gvkFilename {
+		objName = gvkFilename(objName, item.GroupVersionKind())
+	}
+	objName = nameReplacer.Replace(objName)
 
-	for _, group := range groups.Groups {
-		for _, version := range group.Versions {
-			resources, err := clientset.DiscoveryClient.ServerResourcesForGroupVersion(version.GroupVersion)
-			if err != nil {
-				log.Printf("failed getting resources for %q: %v\n", version.GroupVersion, err)
-				continue
+	// the scope of the discovery is authoritative, the namespace might be missing due to API quirks
+	namespace := item.GetNamespace()
+	if namespace == "" && opts.namespacedRes[gvr] {
+		namespace = defaultNoNamespaceDir
+		if opts.noNamespaceDir != "" {
+			namespace = opts.noNamespaceDir
+		}
+		log.Printf("warning: %v %q of the namespaced resource %v has no namespace, writing it to %q\n", item.GetKind(), item.GetName(), gvr.String(), namespace)
+	}
+
+	if opts.layout == layoutVelero {
+		if opts.maxNameLength > 0 {
+			if truncated, ok := truncateFilename(objName, opts.maxNameLength-len(".json")); ok {
+				log.Printf("warning: filename of %v %q in namespace %q is too long, using %q\n", item.GetKind(), item.GetName(), item.GetNamespace(), truncated)
+				objName = truncated
 			}
+		}
+		return writeVeleroObject(opts.outDir, gvr, item, namespace, objName)
+	}
 
-			waitGroup.Add(len(resources.APIResources))
-			for _, res := range resources.APIResources {
-				threadGuard <- struct{}{} // would block if guard channel is already filled
+	resourceAndGroup := resourceAndGroupName(gvr)
 
-				go func(res metav1.APIResource, group metav1.APIGroup, version metav1.GroupVersionForDiscovery) {
-					defer func() {
-						waitGroup.Done()
-						<-threadGuard
-					}()
+	var (
+		rootDir  = opts.outDir
+		dirPerm  = os.ModePerm
+		filePerm = os.ModePerm
+		secret   = opts.secretsDir != "" && isSecret(item)
+	)
 
-					if skipResource(res, wantResources, ignoreResources) {
-						return
-					}
+	// secrets are kept apart from the other resources, with restricted permissions
+	if secret {
+		rootDir = opts.secretsDir
+		dirPerm = 0o700
+		filePerm = 0o600
+	} else if opts.singleFile != nil {
+		opts.singleFile.add(item.GetKind(), item.GetNamespace(), objName, yamlBytes)
+		return nil
+	} else if opts.archives != nil {
+		for _, file := range files {
+			if err := opts.archives.write(namespace, filepath.Join(resourceAndGroup, objName)+file.suffix, file.content); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
 
-					gvr := schema.GroupVersionResource{
-						Group:    group.Name,
-						Version:  version.Version,
-						Resource: res.Name,
-					}
+	clusterscopedDir, namespacedDir := defaultClusterscopedDir, defaultNamespacedDir
+	if opts.clusterscopedDir != "" {
+		clusterscopedDir = opts.clusterscopedDir
+	}
+	if opts.namespacedDir != "" {
+		namespacedDir = opts.namespacedDir
+	}
 
-					if *verbosityFlag > 1 {
-						fmt.Printf("processing group=%v resource=%v\n", gvr.Group, gvr.Resource)
-					}
+	parts := []string{clusterscopedDir, resourceAndGroup}
+	if namespace != "" {
+		parts = []string{namespacedDir, namespace, resourceAndGroup}
+	}
+	if opts.singleScope {
+		parts = parts[1:]
+	}
 
-					unstrList, err := dynamicClient.Resource(gvr).List(context.Background(), metav1.ListOptions{})
-					if err != nil {
-						log.Printf("failed listing %v: %v\n", gvr.String(), err)
-						return
-					}
+	dir := filepath.Join(rootDir, filepath.Join(parts...))
+	if opts.noSubdir {
+		dir = rootDir
+		objName = strings.Join(append(parts, objName), flatSeparator)
+	}
 
-					for _, item := range unstrList.Items {
-						if skipItem(item, *namespacedFlag, *clusterscopedFlag, wantNamespaces, ignoreNamespaces) {
-							continue
-						}
+	if opts.maxNameLength > 0 {
+		suffixLength := 0
+		for _, file := range files {
+			if !strings.Contains(file.suffix, "/") && len(file.suffix) > suffixLength {
+				suffixLength = len(file.suffix)
+			}
+		}
+		if opts.compressThreshold > 0 {
+			suffixLength += len(".gz")
+		}
+		if truncated, ok := truncateFilename(objName, opts.maxNameLength-suffixLength); ok {
+			log.Printf("warning: filename of %v %q in namespace %q is too long, using %q\n", item.GetKind(), item.GetName(), item.GetNamespace(), truncated)
+			objName = truncated
+		}
+	}
 
-						// Use a combination of resource and group name as it might not be unique otherwise.
-						// Example content of the variables:
-						//		resource: "pod"		group: ""
-						//		resource: "pod"		group: "metrics.k8s.io"
-						resourceAndGroup := strings.TrimSuffix(fmt.Sprintf("%s.%s", res.Name, group.Name), ".")
+	if err = os.MkdirAll(dir, dirPerm); err != nil {
+		return fmt.Errorf("failed creating dir %q: %v", dir, err)
+	}
 
-						if *verbosityFlag > 2 {
-							fmt.Printf("processing manifest group=%v version=%v resource=%v namespace=%v name=%q\n", gvr.Group, gvr.Version, gvr.Resource, item.GetNamespace(), item.GetName())
-						}
+	if secret {
+		// the directory might have existed before with other permissions
+		if err = os.Chmod(rootDir, dirPerm); err != nil {
+			return fmt.Errorf("failed setting permissions of dir %q: %v", rootDir, err)
+		}
+	}
 
-						if err := writeYAML(*outdirFlag, resourceAndGroup, item, *statelessFlag); err != nil {
-							log.Printf("failed writing %v/%v: %v\n", item.GetNamespace(), item.GetName(), err)
-							continue
-						}
-						atomic.AddUint64(&writtenFiles, 1)
-					}
-				}(res, group, version)
+	for _, file := range files {
+		filename := filepath.Join(dir, objName) + file.suffix
+		if fileDir := filepath.Dir(filename); fileDir != dir {
+			if err = os.MkdirAll(fileDir, dirPerm); err != nil {
+				return fmt.Errorf("failed creating dir %q: %v", fileDir, err)
+			}
+		}
+		if opts.compressThreshold > 0 && len(file.content) > opts.compressThreshold {
+			file.content, err = gzipContent(file.content)
+			if err != nil {
+				return fmt.Errorf("failed compressing %q: %v", filename, err)
+			}
+			filename += ".gz"
+			if opts.compressed != nil {
+				relative, err := filepath.Rel(opts.outDir, filename)
+				if err != nil {
+					relative = filename
+				}
+				opts.compressed.add(relative)
+			}
+		}
+		if opts.dedupe != nil && !secret {
+			err = writeDeduplicated(opts.dedupe, filename, file.content, filePerm)
+		} else {
+			err = writeFileAtomic(filename, file.content, filePerm)
+		}
+		if err != nil {
+			return fmt.Errorf("failed writing file %q: %v", filename, err)
+		}
+		if secret {
+			if err = os.Chmod(filename, filePerm); err != nil {
+				return fmt.Errorf("failed setting permissions of file %q: %v", filename, err)
 			}
 		}
 	}
 
-	waitGroup.Wait()
-	if *verbosityFlag > 0 {
-		fmt.Printf("loaded %d manifests in %v\n", writtenFiles, time.Since(start).Round(1*time.Millisecond))
+	// extracted data can't be applied
+	if opts.restoreScript != nil && format != formatExtract {
+		manifest := filepath.Join(dir, objName) + files[0].suffix
+		relative, err := filepath.Rel(opts.outDir, manifest)
+		if err != nil {
+			return fmt.Errorf("failed getting the path of %q relative to %q: %v", manifest, opts.outDir, err)
+		}
+		opts.restoreScript.add(gvr, relative)
 	}
+
+	return nil
 }
 
-func skipResource(res metav1.APIResource, wantResources, ignoreResources []string) bool {
-	// check if we can even 'list' the resource
-	if !slices.Contains(res.Verbs, "list") {
-		return true
+// decodeSecretData moves the base64 decoded values of the secret's data to stringData.
+// Values which are not valid UTF-8 are kept in data.
+func decodeSecretData(item unstructured.Unstructured) {
+	data, ok := item.Object["data"].(map[string]interface{})
+	if !ok {
+		return
 	}
 
-	// skip subresources
-	// TODO: maybe there is a better way to not get them in the first place
-	if strings.Contains(res.Name, "/") {
-		return true
+	stringData, ok := item.Object["stringData"].(map[string]interface{})
+	if !ok {
+		stringData = make(map[string]interface{})
 	}
 
-	// check if we got the specified resources (if any resources were specified)
-	if len(wantResources) > 0 && wantResources[0] != "" && !slices.Contains(wantResources, res.Name) {
-		return true
+	for key, val := range data {
+		encoded, ok := val.(string)
+		if !ok {
+			continue
+		}
+		decoded, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || !utf8.Valid(decoded) {
+			continue
+		}
+		stringData[key] = string(decoded)
+		delete(data, key)
 	}
 
-	// check if we got a resource to ignore (if any resources were specified)
-	if len(ignoreResources) > 0 && ignoreResources[0] != "" && slices.Contains(ignoreResources, res.Name) {
-		return true
+	if len(stringData) > 0 {
+		item.Object["stringData"] = stringData
 	}
+	if len(data) == 0 {
+		delete(item.Object, "data")
+	}
+}
 
-	return false
+// sanitizePathComponent replaces all characters which are not safe in a single path component.
+func sanitizePathComponent(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '.' || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
 }
 
-func skipItem(item unstructured.Unstructured, namespaced, clusterscoped bool, wantNamespaces, ignoreNamespaces []string) bool {
-	// item with namespace but we skip namespaced items
-	if item.GetNamespace() != "" && !namespaced {
-		return true
-	}
-	// item clusterscoped but we skip them
-	if item.GetNamespace() == "" && !clusterscoped {
-		return true
-	}
-	// specific namespaces specied but doesn't match
-	if len(wantNamespaces) > 0 && wantNamespaces[0] != "" && !slices.Contains(wantNamespaces, item.GetNamespace()) {
-		return true
-	}
-	// ignore specific namespaces and it matches
-	if len(ignoreNamespaces) > 0 && ignoreNamespaces[0] != "" && slices.Contains(ignoreNamespaces, item.GetNamespace()) {
-		return true
-	}
+const metadataSuffix = ".meta.json"
 
-	return false
+// rawSuffix is the suffix of the untouched objects written next to the cleaned manifests.
+const rawSuffix = ".raw.yaml"
+
+type objectMetadata struct {
+	Group           string `json:"group"`
+	Version         string `json:"version"`
+	Resource        string `json:"resource"`
+	UID             string `json:"uid"`
+	ResourceVersion string `json:"resourceVersion"`
+	DumpTime        string `json:"dumpTime"`
 }
 
-func writeYAML(outDir, resourceAndGroup string, item unstructured.Unstructured, stateless bool) error {
-	if stateless {
-		cleanState(item)
+func sidecarMetadata(gvr schema.GroupVersionResource, item unstructured.Unstructured, dumpTime time.Time) ([]byte, error) {
+	metadata := objectMetadata{
+		Group:           gvr.Group,
+		Version:         gvr.Version,
+		Resource:        gvr.Resource,
+		UID:             string(item.GetUID()),
+		ResourceVersion: item.GetResourceVersion(),
+		DumpTime:        dumpTime.UTC().Format(time.RFC3339),
 	}
 
-	yamlBytes, err := yaml.Marshal(item.Object)
+	jsonBytes, err := json.MarshalIndent(metadata, "", "  ")
 	if err != nil {
-		return fmt.Errorf("failed marshalling: %v", err)
+		return nil, err
 	}
+	return append(jsonBytes, '\n'), nil
+}
 
-	namespace := "clusterscoped"
-	if item.GetNamespace() != "" {
-		namespace = filepath.Join("namespaced", item.GetNamespace())
+// writeReport writes the content as YAML to a file in the root of the output directory.
+func writeReport(outDir, filename string, content interface{}) error {
+	yamlBytes, err := yaml.Marshal(content)
+	if err != nil {
+		return fmt.Errorf("failed marshalling: %v", err)
 	}
 
-	dir := filepath.Join(outDir, namespace, resourceAndGroup)
-	if err = os.MkdirAll(dir, os.ModePerm); err != nil {
-		return fmt.Errorf("failed creating dir %q: %v", dir, err)
+	if err = os.MkdirAll(outDir, os.ModePerm); err != nil {
+		return fmt.Errorf("failed creating dir %q: %v", outDir, err)
 	}
 
-	objName := strings.ReplaceAll(item.GetName(), ":", "_") // windows compatibility
-	filename := filepath.Join(dir, objName) + ".yaml"
+	filename = filepath.Join(outDir, filename)
 	if err = os.WriteFile(filename, yamlBytes, os.ModePerm); err != nil {
 		return fmt.Errorf("failed writing file %q: %v", filename, err)
 	}
-
 	return nil
 }
 
+// normalizeNewlines converts all line endings to '\n' and makes sure the content ends with a newline.
+// Additional trailing newlines are kept as they are part of the value of a keep-chomped block scalar ('|+').
+func normalizeNewlines(content []byte) []byte {
+	content = bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
+	if len(content) > 0 && !bytes.HasSuffix(content, []byte("\n")) {
+		content = append(content, '\n')
+	}
+	return content
+}
+
 func cleanState(item unstructured.Unstructured) {
 	// partially based on https://github.com/WoozyMasta/kube-dump/blob/f1ae560a8b9da8dba1c28619f38089d40d0d2357/kube-dump#L334
 
@@ -314,21 +2160,159 @@ func cleanState(item unstructured.Unstructured) {
 	}
 }
 
-// https://github.com/kubernetes/client-go/issues/192#issuecomment-349564767
-func buildConfigFromFlags(context, kubeconfigPath string) (*rest.Config, error) {
-	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+func clientConfigFromFlags(context, kubeconfigPath string) clientcmd.ClientConfig {
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
 		&clientcmd.ClientConfigLoadingRules{ExplicitPath: kubeconfigPath},
 		&clientcmd.ConfigOverrides{
 			CurrentContext: context,
-		}).ClientConfig()
+		})
+}
+
+type configOptions struct {
+	context        string
+	kubeconfigPath string
+	userAgent      string
+	// proxyURL overrides the proxy of the kubeconfig and the proxy environment variables
+	proxyURL      string
+	tlsServerName string
+	// caDir contains PEM files which replace the CA of the kubeconfig
+	caDir string
+	// requestTimeout bounds each HTTP request, 0 keeps the timeout of the kubeconfig
+	requestTimeout time.Duration
+}
+
+// https://github.com/kubernetes/client-go/issues/192#issuecomment-349564767
+func buildConfigFromFlags(opts configOptions) (*rest.Config, error) {
+	config, err := clientConfigFromFlags(opts.context, opts.kubeconfigPath).ClientConfig()
 	if err != nil {
 		return config, err
 	}
+	if err := checkExecPlugin(config); err != nil {
+		return nil, err
+	}
+	if err := checkAuthProvider(config); err != nil {
+		return nil, err
+	}
 
 	// https://kubernetes.io/blog/2020/09/03/warnings/#customize-client-handling
 	config = rest.CopyConfig(config)
 	config.WarningHandler = rest.NoWarnings{}
 	config.QPS = 100
 	config.Burst = 300
+	config.UserAgent = opts.userAgent
+
+	// Without a proxy in the config, client-go uses HTTPS_PROXY, HTTP_PROXY and NO_PROXY from the environment.
+	if opts.proxyURL != "" {
+		proxyURL, err := url.Parse(opts.proxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed parsing proxy url: %v", err)
+		}
+		config.Proxy = http.ProxyURL(proxyURL)
+	}
+	if opts.tlsServerName != "" {
+		config.TLSClientConfig.ServerName = opts.tlsServerName
+	}
+	if opts.caDir != "" {
+		caData, err := readCADir(opts.caDir)
+		if err != nil {
+			return nil, err
+		}
+		config.TLSClientConfig.CAFile = ""
+		config.TLSClientConfig.CAData = caData
+	}
+	if opts.requestTimeout > 0 {
+		config.Timeout = opts.requestTimeout
+	}
+	reloadTokenFileOnUnauthorized(config)
 	return config, nil
 }
+
+// readCADir concatenates the PEM files of the directory into a single bundle.
+func readCADir(dir string) ([]byte, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed reading CA dir: %v", err)
+	}
+
+	var bundle []byte
+	for _, entry := range entries {
+		if entry.IsDir() || (filepath.Ext(entry.Name()) != ".pem" && filepath.Ext(entry.Name()) != ".crt") {
+			continue
+		}
+
+		filename := filepath.Join(dir, entry.Name())
+		content, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, fmt.Errorf("failed reading CA file: %v", err)
+		}
+		if !x509.NewCertPool().AppendCertsFromPEM(content) {
+			return nil, fmt.Errorf("no PEM certificates found in %q", filename)
+		}
+		bundle = append(bundle, normalizeNewlines(content)...)
+	}
+
+	if len(bundle) == 0 {
+		return nil, fmt.Errorf("no PEM files found in CA dir %q", dir)
+	}
+	return bundle, nil
+}
+
+// checkExecPlugin verifies that the credential plugin of the config is installed.
+// Otherwise the plugin only fails with the first request, with an error that doesn't explain the cause.
+func checkExecPlugin(config *rest.Config) error {
+	if config.ExecProvider == nil {
+		return nil
+	}
+
+	command := config.ExecProvider.Command
+	if _, err := exec.LookPath(command); err != nil {
+		msg := fmt.Sprintf("the kubeconfig uses the credential plugin %q, which can't be found (install it or fix the 'exec' section of the user in the kubeconfig)", command)
+		if hint := strings.TrimSpace(config.ExecProvider.InstallHint); hint != "" {
+			msg += ": " + hint
+		}
+		return errors.New(msg)
+	}
+	return nil
+}
+
+// printContexts prints the contexts of the kubeconfig, marking the one which would be used.
+func printContexts(w io.Writer, context, kubeconfigPath string) error {
+	rawConfig, err := clientConfigFromFlags(context, kubeconfigPath).RawConfig()
+	if err != nil {
+		return err
+	}
+	if context == "" {
+		context = rawConfig.CurrentContext
+	}
+
+	names := make([]string, 0, len(rawConfig.Contexts))
+	for name := range rawConfig.Contexts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "CURRENT\tNAME\tCLUSTER\tNAMESPACE")
+	for _, name := range names {
+		current := ""
+		if name == context {
+			current = "*"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", current, name, rawConfig.Contexts[name].Cluster, rawConfig.Contexts[name].Namespace)
+	}
+	return tw.Flush()
+}
+
+// currentContextName returns the given context or the current context of the kubeconfig.
+// An empty name is returned when running with the in-cluster config.
+func currentContextName(context, kubeconfigPath string) (string, error) {
+	if context != "" {
+		return context, nil
+	}
+
+	rawConfig, err := clientConfigFromFlags(context, kubeconfigPath).RawConfig()
+	if err != nil {
+		return "", err
+	}
+	return rawConfig.CurrentContext, nil
+}
@@ -1,17 +1,43 @@
 package main
 
 import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
 	"testing"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/exp/slices"
+	"sigs.k8s.io/yaml"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
 func TestSkipResource(t *testing.T) {
 	type args struct {
 		res             metav1.APIResource
+		group           string
 		wantResources   []string
 		ignoreResources []string
+		denyAll         bool
+		onlyGroups      map[string]bool
+		wantKinds       []string
+		skipKinds       []string
 	}
 	tests := []struct {
 		name string
@@ -100,28 +126,211 @@ func TestSkipResource(t *testing.T) {
 			},
 			skip: false,
 		},
+		{
+			name: "deny all without resources",
+			args: args{
+				res: metav1.APIResource{
+					Name:  "myresource",
+					Verbs: metav1.Verbs{"list"},
+				},
+				wantResources: []string{""},
+				denyAll:       true,
+			},
+			skip: true,
+		},
+		{
+			name: "deny all with resource match",
+			args: args{
+				res: metav1.APIResource{
+					Name:  "myresource",
+					Verbs: metav1.Verbs{"list"},
+				},
+				wantResources: []string{"myresource"},
+				denyAll:       true,
+			},
+			skip: false,
+		},
+		{
+			name: "only groups match",
+			args: args{
+				res:        metav1.APIResource{Name: "certificates", Verbs: metav1.Verbs{"list"}},
+				group:      "cert-manager.io",
+				onlyGroups: map[string]bool{"cert-manager.io": true},
+			},
+			skip: false,
+		},
+		{
+			name: "only groups core",
+			args: args{
+				res:        metav1.APIResource{Name: "pods", Verbs: metav1.Verbs{"list"}},
+				onlyGroups: map[string]bool{"cert-manager.io": true},
+			},
+			skip: true,
+		},
+		{
+			name: "want kind match",
+			args: args{
+				res:       metav1.APIResource{Name: "deployments", Kind: "Deployment", Verbs: metav1.Verbs{"list"}},
+				wantKinds: []string{"deployment"},
+			},
+			skip: false,
+		},
+		{
+			name: "want kind mismatch",
+			args: args{
+				res:       metav1.APIResource{Name: "deployments", Kind: "Deployment", Verbs: metav1.Verbs{"list"}},
+				wantKinds: []string{"Service"},
+			},
+			skip: true,
+		},
+		{
+			name: "want resource or kind",
+			args: args{
+				res:           metav1.APIResource{Name: "deployments", Kind: "Deployment", Verbs: metav1.Verbs{"list"}},
+				wantResources: []string{"deployments"},
+				wantKinds:     []string{"Service"},
+			},
+			skip: false,
+		},
+		{
+			name: "skip kind match",
+			args: args{
+				res:       metav1.APIResource{Name: "events", Kind: "Event", Verbs: metav1.Verbs{"list"}},
+				skipKinds: []string{"Endpoints", "event"},
+			},
+			skip: true,
+		},
+		{
+			name: "skip kind or ignore resource",
+			args: args{
+				res:             metav1.APIResource{Name: "configmaps", Kind: "ConfigMap", Verbs: metav1.Verbs{"list"}},
+				ignoreResources: []string{"configmaps"},
+				skipKinds:       []string{"Event"},
+			},
+			skip: true,
+		},
+		{
+			name: "skip kind mismatch",
+			args: args{
+				res:       metav1.APIResource{Name: "configmaps", Kind: "ConfigMap", Verbs: metav1.Verbs{"list"}},
+				skipKinds: []string{"Event"},
+			},
+			skip: false,
+		},
+		{
+			name: "deny all with kind match",
+			args: args{
+				res:       metav1.APIResource{Name: "deployments", Kind: "Deployment", Verbs: metav1.Verbs{"list"}},
+				wantKinds: []string{"Deployment"},
+				denyAll:   true,
+			},
+			skip: false,
+		},
+		{
+			name: "want glob match",
+			args: args{
+				res:           metav1.APIResource{Name: "configmaps", Verbs: metav1.Verbs{"list"}},
+				wantResources: []string{"config*"},
+			},
+			skip: false,
+		},
+		{
+			name: "want glob group match",
+			args: args{
+				res:           metav1.APIResource{Name: "deployments", Verbs: metav1.Verbs{"list"}},
+				group:         "apps",
+				wantResources: []string{"*.apps"},
+			},
+			skip: false,
+		},
+		{
+			name: "want glob group mismatch",
+			args: args{
+				res:           metav1.APIResource{Name: "deployments", Verbs: metav1.Verbs{"list"}},
+				group:         "extensions",
+				wantResources: []string{"*.apps"},
+			},
+			skip: true,
+		},
+		{
+			name: "want plain name matches exactly",
+			args: args{
+				res:           metav1.APIResource{Name: "deployments", Verbs: metav1.Verbs{"list"}},
+				group:         "apps",
+				wantResources: []string{"deployments.apps"},
+			},
+			skip: true,
+		},
+		{
+			name: "ignore glob match",
+			args: args{
+				res:             metav1.APIResource{Name: "secrets", Verbs: metav1.Verbs{"list"}},
+				ignoreResources: []string{"secret?"},
+			},
+			skip: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := skipResource(tt.args.res, tt.args.wantResources, tt.args.ignoreResources); got != tt.skip {
+			if got := skipResource(tt.args.res, tt.args.group, resourceFilter{wantResources: tt.args.wantResources, ignoreResources: tt.args.ignoreResources, denyAll: tt.args.denyAll, onlyGroups: tt.args.onlyGroups, wantKinds: tt.args.wantKinds, skipKinds: tt.args.skipKinds}); got != tt.skip {
 				t.Errorf("ignoreResource() = %v, want %v", got, tt.skip)
 			}
 		})
 	}
 }
 
+func TestScopedFilter(t *testing.T) {
+	namespacedOnly := itemFilter{namespaced: true}
+	clusterscopedOnly := itemFilter{clusterscoped: true}
+
+	noNamespace := unstructured.Unstructured{}
+	noNamespace.SetName("quirk")
+
+	if skipItem(noNamespace, scopedFilter(namespacedOnly, true, noNamespace)) {
+		t.Error("expected a namespaced object without namespace to match the namespaced filter")
+	}
+	if !skipItem(noNamespace, scopedFilter(clusterscopedOnly, true, noNamespace)) {
+		t.Error("expected a namespaced object without namespace to be skipped by the cluster-scoped filter")
+	}
+	if !skipItem(noNamespace, scopedFilter(namespacedOnly, false, noNamespace)) {
+		t.Error("expected a cluster-scoped object to be skipped by the namespaced filter")
+	}
+	if got := scopedFilter(clusterscopedOnly, false, noNamespace); !got.clusterscoped {
+		t.Error("expected the filter of cluster-scoped resources to be unchanged")
+	}
+}
+
 func TestSkipItem(t *testing.T) {
 	type args struct {
-		item             unstructured.Unstructured
-		namespaced       bool
-		clusterscoped    bool
-		wantNamespaces   []string
-		ignoreNamespaces []string
+		item               unstructured.Unstructured
+		namespaced         bool
+		clusterscoped      bool
+		wantNamespaces     []string
+		ignoreNamespaces   []string
+		skipAnnotations    []annotation
+		requireAnnotations []annotation
+		excludeOwned       bool
+		excludeOwnedKinds  []string
+		ignoreOwnerKinds   []string
+		skipHelmHooks      bool
 	}
 
 	namespacedTestItem := unstructured.Unstructured{}
 	namespacedTestItem.SetNamespace("mynamespace")
 
+	annotatedTestItem := unstructured.Unstructured{}
+	annotatedTestItem.SetAnnotations(map[string]string{"backup": "false"})
+
+	hookTestItem := unstructured.Unstructured{}
+	hookTestItem.SetAnnotations(map[string]string{helmHookAnnotation: "pre-install"})
+
+	controller := true
+	ownedTestItem := unstructured.Unstructured{}
+	ownedTestItem.SetOwnerReferences([]metav1.OwnerReference{
+		{Kind: "Deployment"},
+		{Kind: "ReplicaSet", Controller: &controller},
+	})
+
 	tests := []struct {
 		name string
 		args args
@@ -197,12 +406,959 @@ func TestSkipItem(t *testing.T) {
 			},
 			skip: true,
 		},
+		{
+			name: "skip annotation match",
+			args: args{
+				item:            annotatedTestItem,
+				clusterscoped:   true,
+				skipAnnotations: parseAnnotations([]string{"backup=false"}),
+			},
+			skip: true,
+		},
+		{
+			name: "skip annotation key match",
+			args: args{
+				item:            annotatedTestItem,
+				clusterscoped:   true,
+				skipAnnotations: parseAnnotations([]string{"backup"}),
+			},
+			skip: true,
+		},
+		{
+			name: "skip annotation don't match",
+			args: args{
+				item:            annotatedTestItem,
+				clusterscoped:   true,
+				skipAnnotations: parseAnnotations([]string{"backup=true"}),
+			},
+			skip: false,
+		},
+		{
+			name: "require annotation match",
+			args: args{
+				item:               annotatedTestItem,
+				clusterscoped:      true,
+				requireAnnotations: parseAnnotations([]string{"other=true", "backup=false"}),
+			},
+			skip: false,
+		},
+		{
+			name: "require annotation don't match",
+			args: args{
+				item:               annotatedTestItem,
+				clusterscoped:      true,
+				requireAnnotations: parseAnnotations([]string{"backup=true"}),
+			},
+			skip: true,
+		},
+		{
+			name: "exclude owned",
+			args: args{
+				item:          ownedTestItem,
+				clusterscoped: true,
+				excludeOwned:  true,
+			},
+			skip: true,
+		},
+		{
+			name: "exclude owned kind match",
+			args: args{
+				item:              ownedTestItem,
+				clusterscoped:     true,
+				excludeOwned:      true,
+				excludeOwnedKinds: []string{"ReplicaSet"},
+			},
+			skip: true,
+		},
+		{
+			name: "exclude owned kind of non-controller",
+			args: args{
+				item:              ownedTestItem,
+				clusterscoped:     true,
+				excludeOwned:      true,
+				excludeOwnedKinds: []string{"Deployment"},
+			},
+			skip: false,
+		},
+		{
+			name: "ignore owner kind of controller",
+			args: args{
+				item:             ownedTestItem,
+				clusterscoped:    true,
+				ignoreOwnerKinds: []string{"Job", "ReplicaSet"},
+			},
+			skip: true,
+		},
+		{
+			name: "ignore owner kind of non-controller",
+			args: args{
+				item:             ownedTestItem,
+				clusterscoped:    true,
+				ignoreOwnerKinds: []string{"Deployment"},
+			},
+			skip: false,
+		},
+		{
+			name: "skip helm hook",
+			args: args{
+				item:          hookTestItem,
+				clusterscoped: true,
+				skipHelmHooks: true,
+			},
+			skip: true,
+		},
+		{
+			name: "keep helm hook",
+			args: args{
+				item:          hookTestItem,
+				clusterscoped: true,
+			},
+			skip: false,
+		},
+		{
+			name: "skip helm hooks without hook",
+			args: args{
+				item:          annotatedTestItem,
+				clusterscoped: true,
+				skipHelmHooks: true,
+			},
+			skip: false,
+		},
+		{
+			name: "exclude owned without owner",
+			args: args{
+				item:          annotatedTestItem,
+				clusterscoped: true,
+				excludeOwned:  true,
+			},
+			skip: false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := skipItem(tt.args.item, tt.args.namespaced, tt.args.clusterscoped, tt.args.wantNamespaces, tt.args.ignoreNamespaces); got != tt.skip {
+			filter := itemFilter{
+				namespaced:         tt.args.namespaced,
+				clusterscoped:      tt.args.clusterscoped,
+				wantNamespaces:     tt.args.wantNamespaces,
+				ignoreNamespaces:   tt.args.ignoreNamespaces,
+				skipAnnotations:    tt.args.skipAnnotations,
+				requireAnnotations: tt.args.requireAnnotations,
+				excludeOwned:       tt.args.excludeOwned,
+				excludeOwnedKinds:  tt.args.excludeOwnedKinds,
+				ignoreOwnerKinds:   tt.args.ignoreOwnerKinds,
+				skipHelmHooks:      tt.args.skipHelmHooks,
+			}
+			if got := skipItem(tt.args.item, filter); got != tt.skip {
 				t.Errorf("ignoreItem() = %v, want %v", got, tt.skip)
 			}
 		})
 	}
 }
+
+func TestWithSystemNamespaces(t *testing.T) {
+	tests := []struct {
+		ignore []string
+		system string
+		want   []string
+	}{
+		{ignore: []string{""}, system: defaultSystemNamespaces, want: []string{"kube-system", "kube-public", "kube-node-lease"}},
+		{ignore: []string{"ns1"}, system: "kube-system, Openshift-Monitoring", want: []string{"ns1", "kube-system", "openshift-monitoring"}},
+		{ignore: []string{""}, system: "", want: nil},
+	}
+
+	for _, tt := range tests {
+		if got := withSystemNamespaces(tt.ignore, tt.system); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("withSystemNamespaces(%q, %q) = %q, want %q", tt.ignore, tt.system, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeNewlines(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "empty",
+			content: "",
+			want:    "",
+		},
+		{
+			name:    "trailing newline",
+			content: "a: b\n",
+			want:    "a: b\n",
+		},
+		{
+			name:    "missing trailing newline",
+			content: "a: b",
+			want:    "a: b\n",
+		},
+		{
+			name:    "windows line endings",
+			content: "a: b\r\nc: d\r\n",
+			want:    "a: b\nc: d\n",
+		},
+		{
+			name:    "keep-chomped block scalar",
+			content: "a: |+\n  b\n\n",
+			want:    "a: |+\n  b\n\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(normalizeNewlines([]byte(tt.content))); got != tt.want {
+				t.Errorf("normalizeNewlines() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeNewlinesMarshalled(t *testing.T) {
+	item := unstructured.Unstructured{}
+	item.SetName("myname")
+	item.SetAnnotations(map[string]string{"multiline": "a\r\nb\n"})
+
+	yamlBytes, err := yaml.Marshal(item.Object)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "metadata:\n  annotations:\n    multiline: \"a\\r\\nb\\n\"\n  name: myname\n"
+	if got := string(normalizeNewlines(yamlBytes)); got != want {
+		t.Errorf("normalizeNewlines() = %q, want %q", got, want)
+	}
+}
+
+func TestWriteYAMLPath(t *testing.T) {
+	namespacedItem := unstructured.Unstructured{}
+	namespacedItem.SetNamespace("mynamespace")
+	namespacedItem.SetName("my:name")
+
+	clusterscopedItem := unstructured.Unstructured{}
+	clusterscopedItem.SetName("myname")
+
+	unnamedItem := unstructured.Unstructured{}
+	unnamedItem.SetUID("myuid")
+
+	secretItem := unstructured.Unstructured{}
+	secretItem.SetAPIVersion("v1")
+	secretItem.SetKind("Secret")
+	secretItem.SetNamespace("mynamespace")
+	secretItem.SetName("mysecret")
+
+	tests := []struct {
+		name     string
+		opts     writeOptions
+		item     unstructured.Unstructured
+		wantPath string
+	}{
+		{
+			name:     "namespaced",
+			item:     namespacedItem,
+			wantPath: filepath.Join("namespaced", "mynamespace", "configmaps", "my_name.yaml"),
+		},
+		{
+			name:     "clusterscoped",
+			item:     clusterscopedItem,
+			wantPath: filepath.Join("clusterscoped", "configmaps", "myname.yaml"),
+		},
+		{
+			name:     "no name",
+			item:     unnamedItem,
+			wantPath: filepath.Join("clusterscoped", "configmaps", "myuid.yaml"),
+		},
+		{
+			name:     "no name stateless",
+			opts:     writeOptions{stateless: true},
+			item:     unnamedItem,
+			wantPath: filepath.Join("clusterscoped", "configmaps", "myuid.yaml"),
+		},
+		{
+			name:     "secret without secrets dir",
+			item:     secretItem,
+			wantPath: filepath.Join("namespaced", "mynamespace", "configmaps", "mysecret.yaml"),
+		},
+		{
+			name:     "secret with secrets dir",
+			opts:     writeOptions{secretsDir: "secrets"},
+			item:     secretItem,
+			wantPath: filepath.Join("secrets", "namespaced", "mynamespace", "configmaps", "mysecret.yaml"),
+		},
+		{
+			name:     "sidecar metadata",
+			opts:     writeOptions{sidecarMetadata: true},
+			item:     clusterscopedItem,
+			wantPath: filepath.Join("clusterscoped", "configmaps", "myname.meta.json"),
+		},
+		{
+			name:     "keep raw",
+			opts:     writeOptions{keepRaw: true},
+			item:     clusterscopedItem,
+			wantPath: filepath.Join("clusterscoped", "configmaps", "myname.raw.yaml"),
+		},
+		{
+			name:     "custom scope dirs namespaced",
+			opts:     writeOptions{clusterscopedDir: "cluster", namespacedDir: "ns"},
+			item:     namespacedItem,
+			wantPath: filepath.Join("ns", "mynamespace", "configmaps", "my_name.yaml"),
+		},
+		{
+			name:     "custom scope dirs clusterscoped",
+			opts:     writeOptions{clusterscopedDir: "cluster", namespacedDir: "ns"},
+			item:     clusterscopedItem,
+			wantPath: filepath.Join("cluster", "configmaps", "myname.yaml"),
+		},
+		{
+			name:     "single scope namespaced",
+			opts:     writeOptions{singleScope: true},
+			item:     namespacedItem,
+			wantPath: filepath.Join("mynamespace", "configmaps", "my_name.yaml"),
+		},
+		{
+			name:     "single scope clusterscoped",
+			opts:     writeOptions{singleScope: true},
+			item:     clusterscopedItem,
+			wantPath: filepath.Join("configmaps", "myname.yaml"),
+		},
+		{
+			name:     "renamed namespace",
+			opts:     writeOptions{namespaceRenames: map[string]string{"mynamespace": "othernamespace"}},
+			item:     namespacedItem,
+			wantPath: filepath.Join("namespaced", "othernamespace", "configmaps", "my_name.yaml"),
+		},
+		{
+			name:     "gvk in filename",
+			opts:     writeOptions{gvkFilename: true},
+			item:     secretItem,
+			wantPath: filepath.Join("namespaced", "mynamespace", "configmaps", "mysecret.v1.Secret.yaml"),
+		},
+		{
+			name:     "gvk in filename no subdir",
+			opts:     writeOptions{gvkFilename: true, noSubdir: true},
+			item:     secretItem,
+			wantPath: "namespaced__mynamespace__configmaps__mysecret.v1.Secret.yaml",
+		},
+		{
+			name:     "namespaced no subdir",
+			opts:     writeOptions{noSubdir: true},
+			item:     namespacedItem,
+			wantPath: "namespaced__mynamespace__configmaps__my_name.yaml",
+		},
+		{
+			name:     "namespaced resource without namespace",
+			opts:     writeOptions{namespacedRes: map[schema.GroupVersionResource]bool{{Version: "v1", Resource: "configmaps"}: true}},
+			item:     clusterscopedItem,
+			wantPath: filepath.Join("namespaced", defaultNoNamespaceDir, "configmaps", "myname.yaml"),
+		},
+		{
+			name:     "namespaced resource without namespace custom dir",
+			opts:     writeOptions{namespacedRes: map[schema.GroupVersionResource]bool{{Version: "v1", Resource: "configmaps"}: true}, noNamespaceDir: "_unknown"},
+			item:     clusterscopedItem,
+			wantPath: filepath.Join("namespaced", "_unknown", "configmaps", "myname.yaml"),
+		},
+		{
+			name:     "clusterscoped no subdir",
+			opts:     writeOptions{noSubdir: true},
+			item:     clusterscopedItem,
+			wantPath: "clusterscoped__configmaps__myname.yaml",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.outDir = t.TempDir()
+			if tt.opts.secretsDir != "" {
+				tt.opts.secretsDir = filepath.Join(tt.opts.outDir, tt.opts.secretsDir)
+			}
+			if err := writeYAML(tt.opts, schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}, *tt.item.DeepCopy()); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := os.Stat(filepath.Join(tt.opts.outDir, tt.wantPath)); err != nil {
+				t.Errorf("expected file %q: %v", tt.wantPath, err)
+			}
+		})
+	}
+}
+
+func TestStringSliceFlag(t *testing.T) {
+	tests := []struct {
+		name       string
+		defaultVal string
+		set        []string
+		want       []string
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name:       "default",
+			defaultVal: "apps/v1, v1",
+			want:       []string{"apps/v1", "v1"},
+		},
+		{
+			name:       "set replaces default",
+			defaultVal: "apps/v1",
+			set:        []string{"v1", "batch/v1,networking.k8s.io/v1"},
+			want:       []string{"v1", "batch/v1", "networking.k8s.io/v1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newStringSliceFlag(tt.defaultVal)
+			for _, val := range tt.set {
+				if err := f.Set(val); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if !slices.Equal(f.values, tt.want) {
+				t.Errorf("values = %v, want %v", f.values, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeSecretData(t *testing.T) {
+	item := unstructured.Unstructured{Object: map[string]interface{}{
+		"data": map[string]interface{}{
+			"text":   "aGVsbG8=", // hello
+			"binary": "/w==",     // 0xff
+		},
+	}}
+
+	decodeSecretData(item)
+
+	stringData, _, _ := unstructured.NestedStringMap(item.Object, "stringData")
+	if stringData["text"] != "hello" {
+		t.Errorf("stringData.text = %q, want %q", stringData["text"], "hello")
+	}
+	data, _, _ := unstructured.NestedStringMap(item.Object, "data")
+	if _, ok := data["text"]; ok {
+		t.Errorf("data.text should be moved to stringData")
+	}
+	if data["binary"] != "/w==" {
+		t.Errorf("data.binary = %q, want %q", data["binary"], "/w==")
+	}
+}
+
+func TestSanitizePathComponent(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "kind-kind", want: "kind-kind"},
+		{name: "arn:aws:eks:eu-central-1:123:cluster/prod", want: "arn_aws_eks_eu-central-1_123_cluster_prod"},
+		{name: `user@cluster\ns`, want: "user_cluster_ns"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sanitizePathComponent(tt.name); got != tt.want {
+				t.Errorf("sanitizePathComponent() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewNameReplacer(t *testing.T) {
+	tests := []struct {
+		name        string
+		chars       string
+		replacement string
+		objName     string
+		want        string
+		wantErr     bool
+	}{
+		{
+			name:        "default",
+			chars:       defaultNameReplaceChars,
+			replacement: defaultNameReplaceChar,
+			objName:     "system:controller:job",
+			want:        "system_controller_job",
+		},
+		{
+			name:        "multiple chars",
+			chars:       ":@",
+			replacement: "-",
+			objName:     "a:b@c",
+			want:        "a-b-c",
+		},
+		{
+			name:        "unsafe replacement",
+			chars:       ":",
+			replacement: "/",
+			wantErr:     true,
+		},
+		{
+			name:        "multiple replacement chars",
+			chars:       ":",
+			replacement: "__",
+			wantErr:     true,
+		},
+		{
+			name:        "replacement is replaced",
+			chars:       ":_",
+			replacement: "_",
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			replacer, err := newNameReplacer(tt.chars, tt.replacement)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newNameReplacer() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got := replacer.Replace(tt.objName); got != tt.want {
+				t.Errorf("Replace() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+const testKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: test
+  cluster:
+    server: https://127.0.0.1:6443
+contexts:
+- name: test
+  context:
+    cluster: test
+    user: test
+current-context: test
+users:
+- name: test
+  user:
+    token: test
+`
+
+func TestBuildConfigFromFlagsProxy(t *testing.T) {
+	kubeconfigPath := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(kubeconfigPath, []byte(testKubeconfig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := buildConfigFromFlags(configOptions{
+		kubeconfigPath: kubeconfigPath,
+		proxyURL:       "http://proxy.example.com:3128",
+		tlsServerName:  "api.example.com",
+		requestTimeout: 30 * time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if config.Timeout != 30*time.Second {
+		t.Errorf("Timeout = %v, want %v", config.Timeout, 30*time.Second)
+	}
+	if config.TLSClientConfig.ServerName != "api.example.com" {
+		t.Errorf("ServerName = %q, want %q", config.TLSClientConfig.ServerName, "api.example.com")
+	}
+
+	proxyURL, err := config.Proxy(&http.Request{URL: &url.URL{Scheme: "https", Host: "127.0.0.1:6443"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proxyURL.String() != "http://proxy.example.com:3128" {
+		t.Errorf("proxy = %q, want %q", proxyURL, "http://proxy.example.com:3128")
+	}
+}
+
+func TestPrintContexts(t *testing.T) {
+	kubeconfig := strings.Replace(testKubeconfig, "contexts:\n", `contexts:
+- name: other
+  context:
+    cluster: test
+    user: test
+    namespace: ns1
+`, 1)
+	kubeconfigPath := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(kubeconfigPath, []byte(kubeconfig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		context string
+		want    string
+	}{
+		{
+			want: "CURRENT  NAME   CLUSTER  NAMESPACE\n" +
+				"         other  test     ns1\n" +
+				"*        test   test     \n",
+		},
+		{
+			context: "other",
+			want: "CURRENT  NAME   CLUSTER  NAMESPACE\n" +
+				"*        other  test     ns1\n" +
+				"         test   test     \n",
+		},
+	}
+
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := printContexts(&buf, tt.context, kubeconfigPath); err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != tt.want {
+			t.Errorf("context %q: got\n%q\nwant\n%q", tt.context, got, tt.want)
+		}
+	}
+}
+
+func TestBuildConfigFromFlagsExecPlugin(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		wantErr string
+	}{
+		{name: "missing plugin", command: "kubedump-missing-credential-plugin", wantErr: `credential plugin "kubedump-missing-credential-plugin"`},
+		{name: "installed plugin", command: os.Args[0]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kubeconfig := strings.Replace(testKubeconfig, "    token: test\n", `    exec:
+      apiVersion: client.authentication.k8s.io/v1
+      command: `+tt.command+`
+      installHint: install the plugin
+      interactiveMode: Never
+`, 1)
+
+			kubeconfigPath := filepath.Join(t.TempDir(), "config")
+			if err := os.WriteFile(kubeconfigPath, []byte(kubeconfig), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := buildConfigFromFlags(configOptions{kubeconfigPath: kubeconfigPath})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) || !strings.Contains(err.Error(), "install the plugin") {
+				t.Fatalf("got error %v, want it to contain %q and the install hint", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func testCertificatePEM(t *testing.T, commonName string) []byte {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+		IsCA:         true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestBuildConfigFromFlagsCADir(t *testing.T) {
+	kubeconfigPath := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(kubeconfigPath, []byte(testKubeconfig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	caDir := t.TempDir()
+	first := testCertificatePEM(t, "first")
+	second := testCertificatePEM(t, "second")
+	files := map[string][]byte{
+		"a.pem":      first,
+		"b.crt":      second,
+		"README.txt": []byte("not a certificate"),
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(caDir, name), content, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	config, err := buildConfigFromFlags(configOptions{kubeconfigPath: kubeconfigPath, caDir: caDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := string(first) + string(second); string(config.TLSClientConfig.CAData) != want {
+		t.Errorf("got CA data %q, want %q", config.TLSClientConfig.CAData, want)
+	}
+
+	if err := os.WriteFile(filepath.Join(caDir, "broken.pem"), []byte("broken"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := buildConfigFromFlags(configOptions{kubeconfigPath: kubeconfigPath, caDir: caDir}); err == nil {
+		t.Error("expected an error for a file without certificates")
+	}
+
+	if _, err := buildConfigFromFlags(configOptions{kubeconfigPath: kubeconfigPath, caDir: t.TempDir()}); err == nil {
+		t.Error("expected an error for a directory without PEM files")
+	}
+}
+
+func TestProvenanceHeader(t *testing.T) {
+	gvr := schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+	dumpTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	got := string(provenanceHeader("prod\nfoo", gvr, dumpTime))
+	want := "# dumped from context=prod foo group=apps version=v1 resource=deployments at 2024-01-02T03:04:05Z\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestValidateScopeDirs(t *testing.T) {
+	tests := []struct {
+		clusterscoped string
+		namespaced    string
+		wantErr       bool
+	}{
+		{clusterscoped: defaultClusterscopedDir, namespaced: defaultNamespacedDir},
+		{clusterscoped: "_cluster", namespaced: "ns"},
+		{clusterscoped: "", namespaced: "ns", wantErr: true},
+		{clusterscoped: "..", namespaced: "ns", wantErr: true},
+		{clusterscoped: "a/b", namespaced: "ns", wantErr: true},
+		{clusterscoped: "same", namespaced: "same", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.clusterscoped+" "+tt.namespaced, func(t *testing.T) {
+			if err := validateScopeDirs(tt.clusterscoped, tt.namespaced); (err != nil) != tt.wantErr {
+				t.Errorf("validateScopeDirs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGVKFilename(t *testing.T) {
+	tests := []struct {
+		gvk  schema.GroupVersionKind
+		want string
+	}{
+		{gvk: schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, want: "web.apps.v1.Deployment"},
+		{gvk: schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, want: "web.v1.ConfigMap"},
+		{gvk: schema.GroupVersionKind{}, want: "web"},
+	}
+
+	for _, tt := range tests {
+		if got := gvkFilename("web", tt.gvk); got != tt.want {
+			t.Errorf("gvkFilename(%v) = %q, want %q", tt.gvk, got, tt.want)
+		}
+	}
+}
+
+func TestWriteYAMLKeepRaw(t *testing.T) {
+	item := unstructured.Unstructured{}
+	item.SetAPIVersion("v1")
+	item.SetKind("ConfigMap")
+	item.SetName("myname")
+	item.SetResourceVersion("42")
+
+	opts := writeOptions{outDir: t.TempDir(), stateless: true, keepRaw: true}
+	if err := writeYAML(opts, schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}, item); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := filepath.Join(opts.outDir, "clusterscoped", "configmaps")
+	cleaned, err := os.ReadFile(filepath.Join(dir, "myname.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(cleaned), "resourceVersion") {
+		t.Errorf("expected the resource version to be cleaned from the manifest, got %q", cleaned)
+	}
+
+	raw, err := os.ReadFile(filepath.Join(dir, "myname"+rawSuffix))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(raw), "resourceVersion: \"42\"") {
+		t.Errorf("expected the raw object to keep the resource version, got %q", raw)
+	}
+}
+
+func TestWriteYAMLKeepRawRedacted(t *testing.T) {
+	item := unstructured.Unstructured{}
+	item.SetAPIVersion("v1")
+	item.SetKind("ConfigMap")
+	item.SetName("myname")
+	item.SetAnnotations(map[string]string{"example.com/owner": "team"})
+	item.Object["data"] = map[string]interface{}{"password": "hunter2"}
+
+	globalStrip, err := parseFieldPath(`metadata.annotations.example\.com/owner`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := writeOptions{outDir: t.TempDir(), keepRaw: true, redact: regexp.MustCompile("password"), globalStrip: []fieldPath{globalStrip}}
+	if err := writeYAML(opts, schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}, item); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := os.ReadFile(filepath.Join(opts.outDir, "clusterscoped", "configmaps", "myname"+rawSuffix))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(raw), "hunter2") || strings.Contains(string(raw), "example.com/owner") {
+		t.Errorf("expected the raw object to be redacted and stripped, got %q", raw)
+	}
+}
+
+func TestTruncateFilename(t *testing.T) {
+	long := strings.Repeat("a", 300)
+
+	got, truncated := truncateFilename(long, 250)
+	if !truncated || len(got) != 250 {
+		t.Fatalf("got %q (truncated %v), want 250 bytes", got, truncated)
+	}
+	if !strings.HasPrefix(got, strings.Repeat("a", 250-1-filenameHashLength)+"-") {
+		t.Errorf("got %q, want the prefix of the name followed by the hash", got)
+	}
+	if other, _ := truncateFilename(long+"b", 250); other == got {
+		t.Errorf("got the same truncated name %q for different names", got)
+	}
+
+	if got, truncated := truncateFilename("short", 250); truncated || got != "short" {
+		t.Errorf("got %q (truncated %v), want the name unchanged", got, truncated)
+	}
+
+	// below the length of the hash only its start is kept
+	for _, max := range []int{filenameHashLength, 5, 0, -3} {
+		want := max
+		if want < 1 {
+			want = 1
+		}
+		if got, truncated := truncateFilename(long, max); !truncated || len(got) != want || strings.HasPrefix(got, "-") {
+			t.Errorf("got %q (truncated %v) for max %d, want %d bytes of the hash", got, truncated, max, want)
+		}
+	}
+
+	// the multi-byte character at the cut is dropped completely
+	got, _ = truncateFilename("aaaäbbbbbbbbbbbbbbbbbbbbb", 21)
+	if !utf8.ValidString(got) || !strings.HasPrefix(got, "aaa-") {
+		t.Errorf("got %q, want the character at the cut dropped", got)
+	}
+}
+
+func TestWriteYAMLMaxNameLength(t *testing.T) {
+	item := unstructured.Unstructured{}
+	item.SetName(strings.Repeat("a", 300))
+
+	opts := writeOptions{outDir: t.TempDir(), maxNameLength: 255, keepRaw: true}
+	if err := writeYAML(opts, schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}, item); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(opts.outDir, "clusterscoped", "configmaps"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d files, want the manifest and the raw object", len(entries))
+	}
+	for _, entry := range entries {
+		if len(entry.Name()) > 255 {
+			t.Errorf("got filename with %d bytes, want at most 255", len(entry.Name()))
+		}
+	}
+}
+
+func TestNonEmptyDir(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, tt := range []struct {
+		name string
+		dir  string
+		want bool
+	}{
+		{name: "missing", dir: filepath.Join(dir, "missing"), want: false},
+		{name: "empty", dir: dir, want: false},
+	} {
+		got, err := nonEmptyDir(tt.dir)
+		if err != nil {
+			t.Fatalf("%v: %v", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("%v: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "file"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	got, err := nonEmptyDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got {
+		t.Error("got empty, want the dir with a file to be non-empty")
+	}
+}
+
+func TestRenderManifestKeepOwnerRefs(t *testing.T) {
+	newItem := func() unstructured.Unstructured {
+		item := unstructured.Unstructured{}
+		item.SetAPIVersion("apps/v1")
+		item.SetKind("ReplicaSet")
+		item.SetNamespace("ns")
+		item.SetName("web-5d9c")
+		item.SetUID("uid-rs")
+		item.SetOwnerReferences([]metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "Deployment", Name: "web", UID: "uid-deploy"}})
+		return item
+	}
+
+	for _, keep := range []bool{false, true} {
+		item := newItem()
+		if _, err := renderManifest(writeOptions{stateless: true, keepOwnerRefs: keep}, schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "replicasets"}, item); err != nil {
+			t.Fatal(err)
+		}
+		if got := len(item.GetOwnerReferences()); (got > 0) != keep {
+			t.Errorf("keep %v: got %d owner references", keep, got)
+		}
+		if item.GetUID() != "" {
+			t.Errorf("keep %v: got uid %q, want it cleaned", keep, item.GetUID())
+		}
+	}
+}
+
+func TestWantedResources(t *testing.T) {
+	dumped := []groupVersionResources{{
+		group: metav1.APIGroup{Name: ""},
+		resources: []metav1.APIResource{
+			{Name: "pods", Verbs: metav1.Verbs{"list"}},
+			{Name: "pods/status", Verbs: metav1.Verbs{"get"}},
+			{Name: "configmaps", Verbs: metav1.Verbs{"list"}},
+		},
+	}}
+	if got := wantedResources(dumped, resourceFilter{wantResources: []string{"pods"}}); got != 1 {
+		t.Errorf("got %d wanted resources, want 1", got)
+	}
+	if got := wantedResources(dumped, resourceFilter{wantResources: []string{""}}); got != 2 {
+		t.Errorf("got %d wanted resources, want 2", got)
+	}
+}
+
+func TestBudgetSummary(t *testing.T) {
+	tests := []struct {
+		completed, total int
+		want             string
+	}{
+		{completed: 1, total: 1, want: "partial dump due to time budget of 1m0s: completed 1 of 1 wanted resources (100.0%)"},
+		{completed: 1, total: 3, want: "partial dump due to time budget of 1m0s: completed 1 of 3 wanted resources (33.3%)"},
+		{completed: 0, total: 0, want: "partial dump due to time budget of 1m0s: completed 0 of 0 wanted resources (100.0%)"},
+	}
+	for _, tt := range tests {
+		if got := budgetSummary(time.Minute, tt.completed, tt.total); got != tt.want {
+			t.Errorf("budgetSummary(%d, %d) = %q, want %q", tt.completed, tt.total, got, tt.want)
+		}
+	}
+}
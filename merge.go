@@ -0,0 +1,205 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+const mergeIndexFilename = "index.yaml"
+
+type mergeSource struct {
+	name string
+	dir  string
+}
+
+type mergeIndexEntry struct {
+	Source string `json:"source"`
+	Path   string `json:"path"`
+}
+
+// parseMergeSources parses the sources like 'prod=./dumps/prod', a plain directory is named after its base.
+func parseMergeSources(args []string) ([]mergeSource, error) {
+	var (
+		sources []mergeSource
+		names   = make(map[string]bool)
+	)
+	for _, arg := range args {
+		source := mergeSource{dir: arg}
+		if name, dir, ok := strings.Cut(arg, "="); ok {
+			source = mergeSource{name: name, dir: dir}
+		} else {
+			source.name = filepath.Base(filepath.Clean(arg))
+		}
+
+		if source.name == "" || source.name == "." || source.name == ".." || strings.ContainsAny(source.name, `/\`) || source.name == mergeIndexFilename {
+			return nil, fmt.Errorf("invalid source name %q of %q", source.name, arg)
+		}
+		if source.dir == "" {
+			return nil, fmt.Errorf("missing directory of source %q", source.name)
+		}
+		if names[source.name] {
+			return nil, fmt.Errorf("duplicate source name %q", source.name)
+		}
+		names[source.name] = true
+		sources = append(sources, source)
+	}
+	return sources, nil
+}
+
+// mergeDumps copies the dump directories below '<name>/' of the directory and updates its index.
+// A source merged before is replaced, the files and entries of other sources from previous merges are kept. It returns the number of copied files.
+func mergeDumps(into string, sources []mergeSource) (int, error) {
+	index, err := readMergeIndex(into)
+	if err != nil {
+		return 0, err
+	}
+
+	merged := make(map[string]bool)
+	for _, source := range sources {
+		merged[source.name] = true
+	}
+	entries := index[:0]
+	for _, entry := range index {
+		if !merged[entry.Source] {
+			entries = append(entries, entry)
+		}
+	}
+
+	var copied int
+	for _, source := range sources {
+		if err := checkMergeSource(into, source); err != nil {
+			return copied, err
+		}
+
+		// the files of a previous merge of the source are replaced, so the tree matches the index
+		target := filepath.Join(into, source.name)
+		if err := os.RemoveAll(target); err != nil {
+			return copied, fmt.Errorf("failed removing previous merge of %q: %v", source.name, err)
+		}
+		paths, err := copyTree(source.dir, target)
+		if err != nil {
+			return copied, fmt.Errorf("failed merging %q: %v", source.name, err)
+		}
+		for _, path := range paths {
+			entries = append(entries, mergeIndexEntry{Source: source.name, Path: filepath.ToSlash(filepath.Join(source.name, path))})
+		}
+		copied += len(paths)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Path < entries[j].Path
+	})
+	return copied, writeReport(into, mergeIndexFilename, entries)
+}
+
+func readMergeIndex(dir string) ([]mergeIndexEntry, error) {
+	content, err := os.ReadFile(filepath.Join(dir, mergeIndexFilename))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed reading index: %v", err)
+	}
+
+	var entries []mergeIndexEntry
+	if err := yaml.Unmarshal(content, &entries); err != nil {
+		return nil, fmt.Errorf("failed parsing index: %v", err)
+	}
+	return entries, nil
+}
+
+// checkMergeSource fails when the source isn't a directory, contains the target, which would copy itself,
+// or is inside its target, which is removed before copying.
+func checkMergeSource(into string, source mergeSource) error {
+	info, err := os.Stat(source.dir)
+	if err != nil {
+		return fmt.Errorf("failed reading source %q: %v", source.name, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("source %q is not a directory", source.name)
+	}
+
+	absSource, err := filepath.Abs(source.dir)
+	if err != nil {
+		return err
+	}
+	absInto, err := filepath.Abs(into)
+	if err != nil {
+		return err
+	}
+	if isWithin(absSource, absInto) {
+		return fmt.Errorf("source %q contains the merge directory", source.name)
+	}
+	// the target of the source is replaced
+	if isWithin(filepath.Join(absInto, source.name), absSource) {
+		return fmt.Errorf("source %q is inside its merge target", source.name)
+	}
+	return nil
+}
+
+// isWithin reports whether the path is the dir or below it.
+func isWithin(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// copyTree copies the files and symlinks, e.g. of -dedupe-content, and returns their paths relative to the source.
+// Leftover temporary files of interrupted writes are skipped.
+func copyTree(src, dst string) ([]string, error) {
+	var paths []string
+	err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		switch {
+		case d.IsDir():
+			if err := os.MkdirAll(target, os.ModePerm); err != nil {
+				return fmt.Errorf("failed creating dir %q: %v", target, err)
+			}
+			return nil
+		case strings.HasPrefix(d.Name(), ".tmp-"):
+			return nil
+		case d.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			if err := os.Remove(target); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			if err := os.Symlink(link, target); err != nil {
+				return fmt.Errorf("failed creating symlink %q: %v", target, err)
+			}
+		case d.Type().IsRegular():
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			if err := writeFileAtomic(target, content, info.Mode().Perm()); err != nil {
+				return fmt.Errorf("failed writing file %q: %v", target, err)
+			}
+		default:
+			return nil
+		}
+		paths = append(paths, rel)
+		return nil
+	})
+	return paths, err
+}
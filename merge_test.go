@@ -0,0 +1,112 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseMergeSources(t *testing.T) {
+	got, err := parseMergeSources([]string{"prod=./dumps/a", "./dumps/staging/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []mergeSource{{name: "prod", dir: "./dumps/a"}, {name: "staging", dir: "./dumps/staging/"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	for _, args := range [][]string{
+		{"a=x", "a=y"},
+		{"=x"},
+		{"a="},
+		{"a/b=x"},
+		{".."},
+		{mergeIndexFilename + "=x"},
+	} {
+		if _, err := parseMergeSources(args); err == nil {
+			t.Errorf("expected an error for %v", args)
+		}
+	}
+}
+
+func TestMergeDumps(t *testing.T) {
+	write := func(filename, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(filename), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filename, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	base := t.TempDir()
+	prod := filepath.Join(base, "prod")
+	staging := filepath.Join(base, "staging")
+	into := filepath.Join(base, "merged")
+
+	write(filepath.Join(prod, "default", "configmap", "a.yaml"), "prod")
+	write(filepath.Join(prod, "default", "configmap", ".tmp-0123"), "partial")
+	if err := os.Symlink("a.yaml", filepath.Join(prod, "default", "configmap", "b.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	write(filepath.Join(staging, "default", "configmap", "a.yaml"), "staging")
+
+	copied, err := mergeDumps(into, []mergeSource{{name: "prod", dir: prod}, {name: "staging", dir: staging}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if copied != 3 {
+		t.Errorf("got %d copied files, want 3", copied)
+	}
+
+	content, err := os.ReadFile(filepath.Join(into, "staging", "default", "configmap", "a.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "staging" {
+		t.Errorf("got content %q, want %q", content, "staging")
+	}
+	if link, err := os.Readlink(filepath.Join(into, "prod", "default", "configmap", "b.yaml")); err != nil || link != "a.yaml" {
+		t.Errorf("got symlink %q (%v), want %q", link, err, "a.yaml")
+	}
+	if _, err := os.Stat(filepath.Join(into, "prod", "default", "configmap", ".tmp-0123")); !os.IsNotExist(err) {
+		t.Errorf("expected the temporary file to be skipped, got %v", err)
+	}
+
+	// merging a source again replaces its entries and keeps the others
+	if err := os.Remove(filepath.Join(prod, "default", "configmap", "b.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mergeDumps(into, []mergeSource{{name: "prod", dir: prod}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Lstat(filepath.Join(into, "prod", "default", "configmap", "b.yaml")); !os.IsNotExist(err) {
+		t.Errorf("expected the removed file to be gone from the merge, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(into, "staging", "default", "configmap", "a.yaml")); err != nil {
+		t.Errorf("expected the other source to be kept, got %v", err)
+	}
+
+	index, err := readMergeIndex(into)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []mergeIndexEntry{
+		{Source: "prod", Path: "prod/default/configmap/a.yaml"},
+		{Source: "staging", Path: "staging/default/configmap/a.yaml"},
+	}
+	if !reflect.DeepEqual(index, want) {
+		t.Errorf("got index %v, want %v", index, want)
+	}
+
+	if _, err := mergeDumps(filepath.Join(prod, "merged"), []mergeSource{{name: "prod", dir: prod}}); err == nil {
+		t.Error("expected an error when the source contains the merge directory")
+	}
+	if _, err := mergeDumps(into, []mergeSource{{name: "prod", dir: filepath.Join(into, "prod", "default")}}); err == nil {
+		t.Error("expected an error when the source is inside its merge target")
+	}
+}
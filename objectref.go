@@ -0,0 +1,37 @@
+package main
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// objectRef identifies an object in the reports, it's embedded to inline its fields.
+type objectRef struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+}
+
+func newObjectRef(gvr schema.GroupVersionResource, item unstructured.Unstructured) objectRef {
+	return objectRef{
+		APIVersion: gvr.GroupVersion().String(),
+		Kind:       item.GetKind(),
+		Namespace:  item.GetNamespace(),
+		Name:       item.GetName(),
+	}
+}
+
+// less orders the references by API version, kind, namespace and name.
+func (a objectRef) less(b objectRef) bool {
+	if a.APIVersion != b.APIVersion {
+		return a.APIVersion < b.APIVersion
+	}
+	if a.Kind != b.Kind {
+		return a.Kind < b.Kind
+	}
+	if a.Namespace != b.Namespace {
+		return a.Namespace < b.Namespace
+	}
+	return a.Name < b.Name
+}
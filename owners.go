@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+)
+
+type pendingOwner struct {
+	namespace string
+	ref       metav1.OwnerReference
+}
+
+// ownerTracker collects the owner references of dumped objects which still have to be fetched.
+// Objects are identified by their UID, so every object is only processed once, which also guards
+// against cycles in the owner graph. It is safe for concurrent use.
+type ownerTracker struct {
+	mu      sync.Mutex
+	seen    map[types.UID]bool
+	pending []pendingOwner
+}
+
+func newOwnerTracker() *ownerTracker {
+	return &ownerTracker{seen: make(map[types.UID]bool)}
+}
+
+// track marks the item as dumped and queues its owners.
+func (t *ownerTracker) track(item unstructured.Unstructured) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.seen[item.GetUID()] = true
+	for _, ref := range item.GetOwnerReferences() {
+		t.pending = append(t.pending, pendingOwner{namespace: item.GetNamespace(), ref: ref})
+	}
+}
+
+// next returns the next owner which wasn't dumped yet.
+func (t *ownerTracker) next() (pendingOwner, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for len(t.pending) > 0 {
+		owner := t.pending[0]
+		t.pending = t.pending[1:]
+
+		if t.seen[owner.ref.UID] {
+			continue
+		}
+		t.seen[owner.ref.UID] = true
+		return owner, true
+	}
+	return pendingOwner{}, false
+}
+
+// followOwners fetches all owners queued in the tracker, including the owners of the fetched owners,
+// and passes them to write. It returns the number of written owners.
+func followOwners(ctx context.Context, dynamicClient dynamic.Interface, kinds map[schema.GroupVersionKind]discoveredResource, tracker *ownerTracker, write func(schema.GroupVersionResource, unstructured.Unstructured) error) uint64 {
+	var written uint64
+
+	for {
+		owner, ok := tracker.next()
+		if !ok {
+			return written
+		}
+
+		if err := followOwner(ctx, dynamicClient, kinds, tracker, owner, write); err != nil {
+			log.Printf("failed following owner %v %q of namespace %q: %v\n", owner.ref.Kind, owner.ref.Name, owner.namespace, err)
+			continue
+		}
+		written++
+	}
+}
+
+func followOwner(ctx context.Context, dynamicClient dynamic.Interface, kinds map[schema.GroupVersionKind]discoveredResource, tracker *ownerTracker, owner pendingOwner, write func(schema.GroupVersionResource, unstructured.Unstructured) error) error {
+	gv, err := schema.ParseGroupVersion(owner.ref.APIVersion)
+	if err != nil {
+		return fmt.Errorf("failed parsing api version: %v", err)
+	}
+
+	res, ok := kinds[gv.WithKind(owner.ref.Kind)]
+	if !ok {
+		return fmt.Errorf("resource not found in discovery")
+	}
+
+	// owners are either in the same namespace or cluster-scoped
+	namespace := ""
+	if res.namespaced {
+		namespace = owner.namespace
+	}
+
+	item, err := dynamicClient.Resource(res.gvr).Namespace(namespace).Get(ctx, owner.ref.Name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed getting: %v", err)
+	}
+
+	if item.GetUID() != owner.ref.UID {
+		return fmt.Errorf("owner was replaced (uid %q, want %q)", item.GetUID(), owner.ref.UID)
+	}
+
+	tracker.track(*item)
+	return write(res.gvr, *item)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestOwnerTracker(t *testing.T) {
+	tracker := newOwnerTracker()
+
+	owner := unstructured.Unstructured{}
+	owner.SetUID("owner-uid")
+
+	child := unstructured.Unstructured{}
+	child.SetUID("child-uid")
+	child.SetNamespace("mynamespace")
+	child.SetOwnerReferences([]metav1.OwnerReference{
+		{UID: "owner-uid", Name: "owner"},
+		{UID: "child-uid", Name: "child"}, // cycle
+		{UID: "missing-uid", Name: "missing"},
+		{UID: "missing-uid", Name: "missing"}, // duplicate
+	})
+
+	tracker.track(child)
+	tracker.track(owner)
+
+	pending, ok := tracker.next()
+	if !ok {
+		t.Fatal("expected a pending owner")
+	}
+	if pending.ref.Name != "missing" || pending.namespace != "mynamespace" {
+		t.Errorf("next() = %v in %q, want missing in %q", pending.ref.Name, pending.namespace, "mynamespace")
+	}
+
+	if pending, ok := tracker.next(); ok {
+		t.Errorf("expected no more pending owners, got %v", pending.ref.Name)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
+)
+
+// countObjects returns the number of objects of the resource by listing a single object
+// and using the number of remaining objects reported by the API server.
+// When the number of objects is unknown, -1 is returned.
+func countObjects(ctx context.Context, client dynamic.ResourceInterface) (int64, error) {
+	sample, err := client.List(ctx, metav1.ListOptions{Limit: 1})
+	if err != nil {
+		return 0, err
+	}
+
+	count := int64(len(sample.Items))
+	if remaining := sample.GetRemainingItemCount(); remaining != nil {
+		count += *remaining
+	} else if sample.GetContinue() != "" {
+		return -1, nil
+	}
+	return count, nil
+}
+
+// estimateObjects sums up the number of objects of all resources which pass the filter in the wanted namespaces.
+// It also returns the number of resources whose number of objects is unknown.
+func estimateObjects(ctx context.Context, dynamicClient dynamic.Interface, discovered []groupVersionResources, resFilter resourceFilter, wantNamespaces []string, threads uint64) (int64, int) {
+	var (
+		total   int64
+		unknown int
+	)
+	for _, c := range countResources(ctx, dynamicClient, discovered, resFilter, wantNamespaces, threads, false) {
+		if c.count < 0 {
+			unknown++
+			continue
+		}
+		total += c.count
+	}
+	return total, unknown
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+)
+
+// sampledResource answers a list with a single object and the given list metadata.
+type sampledResource struct {
+	dynamic.ResourceInterface
+	items     int
+	remaining *int64
+	continued bool
+}
+
+func (r *sampledResource) List(_ context.Context, opts metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	if opts.Limit != 1 {
+		return nil, nil
+	}
+
+	list := &unstructured.UnstructuredList{}
+	for i := 0; i < r.items; i++ {
+		list.Items = append(list.Items, unstructured.Unstructured{})
+	}
+	list.SetRemainingItemCount(r.remaining)
+	if r.continued {
+		list.SetContinue("next")
+	}
+	return list, nil
+}
+
+func TestCountObjects(t *testing.T) {
+	remaining := int64(41)
+
+	tests := []struct {
+		name     string
+		resource *sampledResource
+		want     int64
+	}{
+		{name: "empty", resource: &sampledResource{}, want: 0},
+		{name: "single", resource: &sampledResource{items: 1}, want: 1},
+		{name: "remaining", resource: &sampledResource{items: 1, remaining: &remaining, continued: true}, want: 42},
+		{name: "unknown", resource: &sampledResource{items: 1, continued: true}, want: -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := countObjects(context.Background(), tt.resource)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("countObjects() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEstimateObjects(t *testing.T) {
+	discovered := []groupVersionResources{{
+		version:   metav1.GroupVersionForDiscovery{GroupVersion: "v1", Version: "v1"},
+		resources: []metav1.APIResource{{Name: "configmaps", Kind: "ConfigMap", Namespaced: true, Verbs: metav1.Verbs{"list"}}},
+	}}
+	client := &fakeDynamicClient{resource: &namespacedResource{namespaces: 10, objects: 3}}
+
+	total, unknown := estimateObjects(context.Background(), client, discovered, resourceFilter{}, nil, 2)
+	if total != 30 || unknown != 0 {
+		t.Errorf("got %d objects (%d unknown), want 30 cluster-wide", total, unknown)
+	}
+
+	total, unknown = estimateObjects(context.Background(), client, discovered, resourceFilter{}, []string{"ns1", "ns2"}, 2)
+	if total != 6 || unknown != 0 {
+		t.Errorf("got %d objects (%d unknown), want 6 in the wanted namespaces", total, unknown)
+	}
+
+	continued := &fakeDynamicClient{resource: &clusterResource{resource: &sampledResource{items: 1, continued: true}}}
+	total, unknown = estimateObjects(context.Background(), continued, discovered, resourceFilter{}, nil, 2)
+	if total != 0 || unknown != 1 {
+		t.Errorf("got %d objects (%d unknown), want the unknown count not to be listed", total, unknown)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const progressFilename = ".kubedump-progress"
+
+// progress records the group version resources which were completely dumped,
+// one per line, allowing an interrupted dump to be resumed. It is safe for concurrent use.
+// A nil progress doesn't record anything.
+type progress struct {
+	mu         sync.Mutex
+	file       *os.File
+	done       map[string]bool
+	incomplete bool
+}
+
+// openProgress opens the progress file in outDir. When resume is set,
+// the already completed resources are loaded, otherwise the file is truncated.
+func openProgress(outDir string, resume bool) (*progress, error) {
+	if err := os.MkdirAll(outDir, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("failed creating dir %q: %v", outDir, err)
+	}
+
+	filename := filepath.Join(outDir, progressFilename)
+	p := &progress{done: make(map[string]bool)}
+
+	if resume {
+		if err := p.load(filename); err != nil {
+			return nil, err
+		}
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if !resume {
+		flags |= os.O_TRUNC
+	}
+
+	file, err := os.OpenFile(filename, flags, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed opening progress file %q: %v", filename, err)
+	}
+	p.file = file
+	return p, nil
+}
+
+func (p *progress) load(filename string) error {
+	file, err := os.Open(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed opening progress file %q: %v", filename, err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			p.done[line] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed reading progress file %q: %v", filename, err)
+	}
+	return nil
+}
+
+func progressKey(gvr schema.GroupVersionResource) string {
+	return strings.Join([]string{gvr.Group, gvr.Version, gvr.Resource}, "/")
+}
+
+func (p *progress) isDone(gvr schema.GroupVersionResource) bool {
+	if p == nil {
+		return false
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.done[progressKey(gvr)]
+}
+
+func (p *progress) markDone(gvr schema.GroupVersionResource) error {
+	if p == nil {
+		return nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := progressKey(gvr)
+	p.done[key] = true
+	if _, err := fmt.Fprintln(p.file, key); err != nil {
+		return fmt.Errorf("failed writing progress file: %v", err)
+	}
+	return nil
+}
+
+// markIncomplete records that a resource couldn't be dumped completely.
+func (p *progress) markIncomplete() {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.incomplete = true
+}
+
+// isComplete reports whether all resources were dumped completely so far.
+func (p *progress) isComplete() bool {
+	if p == nil {
+		return true
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return !p.incomplete
+}
+
+// finish closes the progress file. When all resources were dumped completely,
+// the file is removed as the dump doesn't need to be resumed anymore.
+func (p *progress) finish() error {
+	if p == nil {
+		return nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.file.Close(); err != nil {
+		return fmt.Errorf("failed closing progress file: %v", err)
+	}
+	if p.incomplete {
+		return nil
+	}
+	if err := os.Remove(p.file.Name()); err != nil {
+		return fmt.Errorf("failed removing progress file: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestProgressResume(t *testing.T) {
+	dir := t.TempDir()
+
+	var (
+		done    = schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+		pending = schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	)
+
+	p, err := openProgress(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.markDone(done); err != nil {
+		t.Fatal(err)
+	}
+	p.markIncomplete()
+	if err := p.finish(); err != nil {
+		t.Fatal(err)
+	}
+
+	resumed, err := openProgress(dir, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resumed.isDone(done) {
+		t.Errorf("expected %v to be done", done)
+	}
+	if resumed.isDone(pending) {
+		t.Errorf("expected %v not to be done", pending)
+	}
+	if err := resumed.finish(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, progressFilename)); !os.IsNotExist(err) {
+		t.Errorf("expected progress file to be removed after a complete run: %v", err)
+	}
+}
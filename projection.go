@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/metadata"
+)
+
+// metadataResource lists only the metadata of the objects with the metadata client, so listResource and
+// listNamespaces can be used for projected resources. The objects are of kind PartialObjectMetadata.
+// All other methods of the dynamic client are not supported.
+type metadataResource struct {
+	dynamic.NamespaceableResourceInterface
+	client metadata.ResourceInterface
+	getter metadata.Getter
+}
+
+func newMetadataResource(getter metadata.Getter) *metadataResource {
+	return &metadataResource{client: getter, getter: getter}
+}
+
+func (r *metadataResource) Namespace(namespace string) dynamic.ResourceInterface {
+	return &metadataResource{client: r.getter.Namespace(namespace), getter: r.getter}
+}
+
+func (r *metadataResource) List(ctx context.Context, opts metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	list, err := r.client.List(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return partialMetadataToUnstructured(list)
+}
+
+func partialMetadataToUnstructured(list *metav1.PartialObjectMetadataList) (*unstructured.UnstructuredList, error) {
+	result := &unstructured.UnstructuredList{}
+	result.SetAPIVersion(metav1.SchemeGroupVersion.String())
+	result.SetKind("PartialObjectMetadataList")
+	result.SetResourceVersion(list.GetResourceVersion())
+	result.SetContinue(list.GetContinue())
+	if list.RemainingItemCount != nil {
+		result.SetRemainingItemCount(list.RemainingItemCount)
+	}
+
+	for i := range list.Items {
+		obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&list.Items[i])
+		if err != nil {
+			return nil, err
+		}
+		item := unstructured.Unstructured{Object: obj}
+		if item.GetKind() == "" {
+			item.SetAPIVersion(metav1.SchemeGroupVersion.String())
+			item.SetKind("PartialObjectMetadata")
+		}
+		result.Items = append(result.Items, item)
+	}
+	return result, nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPartialMetadataToUnstructured(t *testing.T) {
+	list := &metav1.PartialObjectMetadataList{
+		ListMeta: metav1.ListMeta{ResourceVersion: "42", Continue: "next"},
+		Items: []metav1.PartialObjectMetadata{
+			{ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "cm1", Labels: map[string]string{"app": "web"}}},
+		},
+	}
+
+	got, err := partialMetadataToUnstructured(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.GetResourceVersion() != "42" || got.GetContinue() != "next" {
+		t.Errorf("got resource version %q and continue %q, want 42 and next", got.GetResourceVersion(), got.GetContinue())
+	}
+	if len(got.Items) != 1 {
+		t.Fatalf("got %d items, want 1", len(got.Items))
+	}
+
+	item := got.Items[0]
+	if item.GetAPIVersion() != "meta.k8s.io/v1" || item.GetKind() != "PartialObjectMetadata" {
+		t.Errorf("got %v %v, want meta.k8s.io/v1 PartialObjectMetadata", item.GetAPIVersion(), item.GetKind())
+	}
+	if item.GetNamespace() != "ns1" || item.GetName() != "cm1" || !reflect.DeepEqual(item.GetLabels(), map[string]string{"app": "web"}) {
+		t.Errorf("got metadata %v, want the metadata of the list item", item.Object["metadata"])
+	}
+}
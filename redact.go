@@ -0,0 +1,29 @@
+package main
+
+import "regexp"
+
+const redactedPlaceholder = "REDACTED"
+
+// redactFields replaces the string values of all keys matching the pattern with a placeholder,
+// as well as the values of name/value pairs like environment variables whose name matches.
+func redactFields(obj interface{}, pattern *regexp.Regexp) {
+	switch typed := obj.(type) {
+	case map[string]interface{}:
+		if name, ok := typed["name"].(string); ok && pattern.MatchString(name) {
+			if _, ok := typed["value"].(string); ok {
+				typed["value"] = redactedPlaceholder
+			}
+		}
+		for key, value := range typed {
+			if _, ok := value.(string); ok && pattern.MatchString(key) {
+				typed[key] = redactedPlaceholder
+				continue
+			}
+			redactFields(value, pattern)
+		}
+	case []interface{}:
+		for _, element := range typed {
+			redactFields(element, pattern)
+		}
+	}
+}
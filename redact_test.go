@@ -0,0 +1,60 @@
+package main
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestRedactFields(t *testing.T) {
+	obj := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"name": "web",
+			"annotations": map[string]interface{}{
+				"example.com/api-token": "abc",
+				"example.com/owner":     "team",
+			},
+		},
+		"spec": map[string]interface{}{
+			"containers": []interface{}{
+				map[string]interface{}{
+					"name": "web",
+					"env": []interface{}{
+						map[string]interface{}{"name": "DB_PASSWORD", "value": "hunter2"},
+						map[string]interface{}{"name": "DB_HOST", "value": "db"},
+						map[string]interface{}{"name": "API_TOKEN", "valueFrom": map[string]interface{}{"secretKeyRef": map[string]interface{}{"name": "api", "key": "token"}}},
+					},
+				},
+			},
+		},
+		"stringData": map[string]interface{}{"password": "hunter2", "username": "admin"},
+	}
+
+	want := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"name": "web",
+			"annotations": map[string]interface{}{
+				"example.com/api-token": redactedPlaceholder,
+				"example.com/owner":     "team",
+			},
+		},
+		"spec": map[string]interface{}{
+			"containers": []interface{}{
+				map[string]interface{}{
+					"name": "web",
+					"env": []interface{}{
+						map[string]interface{}{"name": "DB_PASSWORD", "value": redactedPlaceholder},
+						map[string]interface{}{"name": "DB_HOST", "value": "db"},
+						map[string]interface{}{"name": "API_TOKEN", "valueFrom": map[string]interface{}{"secretKeyRef": map[string]interface{}{"name": "api", "key": "token"}}},
+					},
+				},
+			},
+		},
+		"stringData": map[string]interface{}{"password": redactedPlaceholder, "username": "admin"},
+	}
+
+	redactFields(obj, regexp.MustCompile(`(?i)password|token`))
+	if !reflect.DeepEqual(obj, want) {
+		t.Errorf("got %v, want %v", obj, want)
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+)
+
+var (
+	configMapsGVR = schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	secretsGVR    = schema.GroupVersionResource{Version: "v1", Resource: "secrets"}
+)
+
+// configReference is a config map or secret referenced by the pod spec of a workload.
+type configReference struct {
+	gvr       schema.GroupVersionResource
+	namespace string
+	name      string
+}
+
+// referenceTracker collects the config maps and secrets referenced by dumped workloads which still have to be fetched.
+// Dumped objects are identified by their UID, so every object is only written once. It is safe for concurrent use.
+type referenceTracker struct {
+	mu      sync.Mutex
+	seen    map[types.UID]bool
+	dumped  map[configReference]bool
+	pending []configReference
+}
+
+func newReferenceTracker() *referenceTracker {
+	return &referenceTracker{seen: make(map[types.UID]bool), dumped: make(map[configReference]bool)}
+}
+
+// track marks the item as dumped and queues the config maps and secrets referenced by its pod spec.
+func (t *referenceTracker) track(gvr schema.GroupVersionResource, item unstructured.Unstructured) {
+	refs := podSpecReferences(item)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.seen[item.GetUID()] = true
+	if gvr.GroupResource() == configMapsGVR.GroupResource() || gvr.GroupResource() == secretsGVR.GroupResource() {
+		t.dumped[configReference{gvr: gvr, namespace: item.GetNamespace(), name: item.GetName()}] = true
+	}
+	t.pending = append(t.pending, refs...)
+}
+
+// next returns the next reference which wasn't dumped yet.
+func (t *referenceTracker) next() (configReference, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for len(t.pending) > 0 {
+		ref := t.pending[0]
+		t.pending = t.pending[1:]
+
+		if t.dumped[ref] {
+			continue
+		}
+		t.dumped[ref] = true
+		return ref, true
+	}
+	return configReference{}, false
+}
+
+// markSeen reports whether the item wasn't seen before and marks it as seen.
+func (t *referenceTracker) markSeen(item unstructured.Unstructured) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.seen[item.GetUID()] {
+		return false
+	}
+	t.seen[item.GetUID()] = true
+	return true
+}
+
+// podSpecReferences returns the config maps and secrets referenced by the volumes, environment
+// and image pull secrets of the pod spec of the item, when it's a workload.
+func podSpecReferences(item unstructured.Unstructured) []configReference {
+	path, ok := podSpecPaths[item.GetKind()]
+	if !ok {
+		return nil
+	}
+	podSpec, ok, _ := unstructured.NestedMap(item.Object, path...)
+	if !ok {
+		return nil
+	}
+
+	var refs []configReference
+	add := func(gvr schema.GroupVersionResource, obj map[string]interface{}, fields ...string) {
+		if name, _, _ := unstructured.NestedString(obj, fields...); name != "" {
+			refs = append(refs, configReference{gvr: gvr, namespace: item.GetNamespace(), name: name})
+		}
+	}
+
+	for _, volume := range nestedMaps(podSpec, "volumes") {
+		add(configMapsGVR, volume, "configMap", "name")
+		add(secretsGVR, volume, "secret", "secretName")
+		for _, source := range nestedMaps(volume, "projected", "sources") {
+			add(configMapsGVR, source, "configMap", "name")
+			add(secretsGVR, source, "secret", "name")
+		}
+	}
+	for _, field := range containerFields {
+		for _, container := range nestedMaps(podSpec, field) {
+			for _, envFrom := range nestedMaps(container, "envFrom") {
+				add(configMapsGVR, envFrom, "configMapRef", "name")
+				add(secretsGVR, envFrom, "secretRef", "name")
+			}
+			for _, env := range nestedMaps(container, "env") {
+				add(configMapsGVR, env, "valueFrom", "configMapKeyRef", "name")
+				add(secretsGVR, env, "valueFrom", "secretKeyRef", "name")
+			}
+		}
+	}
+	for _, pullSecret := range nestedMaps(podSpec, "imagePullSecrets") {
+		add(secretsGVR, pullSecret, "name")
+	}
+	return refs
+}
+
+// nestedMaps returns the objects of the array at the fields, other elements are ignored.
+func nestedMaps(obj map[string]interface{}, fields ...string) []map[string]interface{} {
+	elements, _, _ := unstructured.NestedSlice(obj, fields...)
+
+	var maps []map[string]interface{}
+	for _, element := range elements {
+		if m, ok := element.(map[string]interface{}); ok {
+			maps = append(maps, m)
+		}
+	}
+	return maps
+}
+
+// followReferences fetches all config maps and secrets queued in the tracker and passes them to write.
+// References to missing objects are ignored, as they might be optional. It returns the number of written objects.
+func followReferences(ctx context.Context, dynamicClient dynamic.Interface, tracker *referenceTracker, write func(schema.GroupVersionResource, unstructured.Unstructured) error) uint64 {
+	var written uint64
+
+	for {
+		ref, ok := tracker.next()
+		if !ok {
+			return written
+		}
+
+		item, err := dynamicClient.Resource(ref.gvr).Namespace(ref.namespace).Get(ctx, ref.name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			log.Printf("failed getting referenced %v %q of namespace %q: %v\n", ref.gvr.Resource, ref.name, ref.namespace, err)
+			continue
+		}
+		if !tracker.markSeen(*item) {
+			continue
+		}
+
+		if err := write(ref.gvr, *item); err != nil {
+			log.Printf("failed writing referenced %v %q of namespace %q: %v\n", ref.gvr.Resource, ref.name, ref.namespace, err)
+			continue
+		}
+		written++
+	}
+}
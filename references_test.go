@@ -0,0 +1,76 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestReferenceTracker(t *testing.T) {
+	deployment := unstructured.Unstructured{Object: map[string]interface{}{
+		"kind":     "Deployment",
+		"metadata": map[string]interface{}{"namespace": "ns1", "name": "web", "uid": "d1"},
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"volumes": []interface{}{
+						map[string]interface{}{"name": "config", "configMap": map[string]interface{}{"name": "web-config"}},
+						map[string]interface{}{"name": "tls", "secret": map[string]interface{}{"secretName": "web-tls"}},
+						map[string]interface{}{"name": "projected", "projected": map[string]interface{}{
+							"sources": []interface{}{map[string]interface{}{"configMap": map[string]interface{}{"name": "ca-bundle"}}},
+						}},
+					},
+					"containers": []interface{}{
+						map[string]interface{}{
+							"name":    "web",
+							"envFrom": []interface{}{map[string]interface{}{"secretRef": map[string]interface{}{"name": "web-env"}}},
+							"env": []interface{}{
+								map[string]interface{}{"name": "A", "valueFrom": map[string]interface{}{"configMapKeyRef": map[string]interface{}{"name": "web-config", "key": "a"}}},
+								map[string]interface{}{"name": "B", "value": "b"},
+							},
+						},
+					},
+					"imagePullSecrets": []interface{}{map[string]interface{}{"name": "registry"}},
+				},
+			},
+		},
+	}}
+
+	dumpedSecret := unstructured.Unstructured{}
+	dumpedSecret.SetKind("Secret")
+	dumpedSecret.SetNamespace("ns1")
+	dumpedSecret.SetName("web-tls")
+	dumpedSecret.SetUID("s1")
+
+	tracker := newReferenceTracker()
+	tracker.track(secretsGVR, dumpedSecret)
+	tracker.track(schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}, deployment)
+
+	var got []configReference
+	for {
+		ref, ok := tracker.next()
+		if !ok {
+			break
+		}
+		got = append(got, ref)
+	}
+
+	want := []configReference{
+		{gvr: configMapsGVR, namespace: "ns1", name: "web-config"},
+		{gvr: configMapsGVR, namespace: "ns1", name: "ca-bundle"},
+		{gvr: secretsGVR, namespace: "ns1", name: "web-env"},
+		{gvr: secretsGVR, namespace: "ns1", name: "registry"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	fetched := unstructured.Unstructured{}
+	fetched.SetUID(types.UID("s1"))
+	if tracker.markSeen(fetched) {
+		t.Error("already dumped object is not seen")
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// namespaceReferences are the fields of built-in kinds which refer to a namespace.
+var namespaceReferences = map[string][]fieldPath{
+	"ClusterRoleBinding":             {mustParseFieldPath("subjects[*].namespace")},
+	"RoleBinding":                    {mustParseFieldPath("subjects[*].namespace")},
+	"MutatingWebhookConfiguration":   {mustParseFieldPath("webhooks[*].clientConfig.service.namespace")},
+	"ValidatingWebhookConfiguration": {mustParseFieldPath("webhooks[*].clientConfig.service.namespace")},
+	"APIService":                     {mustParseFieldPath("spec.service.namespace")},
+	"CustomResourceDefinition":       {mustParseFieldPath("spec.conversion.webhook.clientConfig.service.namespace")},
+	"PersistentVolume":               {mustParseFieldPath("spec.claimRef.namespace")},
+	// the label key contains dots
+	"Namespace": {{{key: "metadata"}, {key: "labels"}, {key: "kubernetes.io/metadata.name"}}},
+}
+
+func mustParseFieldPath(path string) fieldPath {
+	parsed, err := parseFieldPath(path)
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
+
+// parseNamespaceRenames parses renames like 'old=new'.
+func parseNamespaceRenames(values []string) (map[string]string, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	renames := make(map[string]string)
+	for _, value := range values {
+		oldName, newName, ok := strings.Cut(value, "=")
+		if !ok || oldName == "" || newName == "" {
+			return nil, fmt.Errorf("invalid rename %q, expected 'old=new'", value)
+		}
+		if _, ok := renames[oldName]; ok {
+			return nil, fmt.Errorf("namespace %q is renamed more than once", oldName)
+		}
+		renames[oldName] = newName
+	}
+	return renames, nil
+}
+
+// renameNamespace moves the item into its renamed namespace and renames namespace objects.
+// With deep, the namespace references of the namespaceReferences are renamed as well.
+func renameNamespace(item unstructured.Unstructured, renames map[string]string, deep bool) {
+	if newName, ok := renames[item.GetNamespace()]; ok && item.GetNamespace() != "" {
+		item.SetNamespace(newName)
+	}
+
+	if item.GetKind() == "Namespace" && item.GetNamespace() == "" {
+		if newName, ok := renames[item.GetName()]; ok {
+			item.SetName(newName)
+		}
+	}
+
+	if !deep {
+		return
+	}
+
+	for _, reference := range namespaceReferences[item.GetKind()] {
+		visitFieldPath(item.Object, reference, func(parent map[string]interface{}, key string) {
+			if oldName, ok := parent[key].(string); ok {
+				if newName, ok := renames[oldName]; ok {
+					parent[key] = newName
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestParseNamespaceRenames(t *testing.T) {
+	got, err := parseNamespaceRenames([]string{"prod=staging", "a=b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"prod": "staging", "a": "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	for _, invalid := range [][]string{{"prod"}, {"=staging"}, {"prod="}, {"prod=a", "prod=b"}} {
+		if _, err := parseNamespaceRenames(invalid); err == nil {
+			t.Errorf("expected an error for %q", invalid)
+		}
+	}
+}
+
+func TestRenameNamespace(t *testing.T) {
+	renames := map[string]string{"prod": "staging"}
+
+	tests := []struct {
+		name string
+		deep bool
+		item map[string]interface{}
+		want map[string]interface{}
+	}{
+		{
+			name: "namespaced",
+			item: map[string]interface{}{"kind": "ConfigMap", "metadata": map[string]interface{}{"name": "cm", "namespace": "prod"}},
+			want: map[string]interface{}{"kind": "ConfigMap", "metadata": map[string]interface{}{"name": "cm", "namespace": "staging"}},
+		},
+		{
+			name: "other namespace",
+			item: map[string]interface{}{"kind": "ConfigMap", "metadata": map[string]interface{}{"name": "cm", "namespace": "dev"}},
+			want: map[string]interface{}{"kind": "ConfigMap", "metadata": map[string]interface{}{"name": "cm", "namespace": "dev"}},
+		},
+		{
+			name: "namespace object",
+			item: map[string]interface{}{"kind": "Namespace", "metadata": map[string]interface{}{"name": "prod", "labels": map[string]interface{}{"kubernetes.io/metadata.name": "prod"}}},
+			want: map[string]interface{}{"kind": "Namespace", "metadata": map[string]interface{}{"name": "staging", "labels": map[string]interface{}{"kubernetes.io/metadata.name": "prod"}}},
+		},
+		{
+			name: "namespace object deep",
+			deep: true,
+			item: map[string]interface{}{"kind": "Namespace", "metadata": map[string]interface{}{"name": "prod", "labels": map[string]interface{}{"kubernetes.io/metadata.name": "prod"}}},
+			want: map[string]interface{}{"kind": "Namespace", "metadata": map[string]interface{}{"name": "staging", "labels": map[string]interface{}{"kubernetes.io/metadata.name": "staging"}}},
+		},
+		{
+			name: "references without deep",
+			item: map[string]interface{}{"kind": "ClusterRoleBinding", "metadata": map[string]interface{}{"name": "crb"}, "subjects": []interface{}{
+				map[string]interface{}{"kind": "ServiceAccount", "name": "sa", "namespace": "prod"},
+			}},
+			want: map[string]interface{}{"kind": "ClusterRoleBinding", "metadata": map[string]interface{}{"name": "crb"}, "subjects": []interface{}{
+				map[string]interface{}{"kind": "ServiceAccount", "name": "sa", "namespace": "prod"},
+			}},
+		},
+		{
+			name: "references deep",
+			deep: true,
+			item: map[string]interface{}{"kind": "ClusterRoleBinding", "metadata": map[string]interface{}{"name": "crb"}, "subjects": []interface{}{
+				map[string]interface{}{"kind": "ServiceAccount", "name": "sa", "namespace": "prod"},
+				map[string]interface{}{"kind": "ServiceAccount", "name": "sa", "namespace": "dev"},
+				map[string]interface{}{"kind": "Group", "name": "admins"},
+			}},
+			want: map[string]interface{}{"kind": "ClusterRoleBinding", "metadata": map[string]interface{}{"name": "crb"}, "subjects": []interface{}{
+				map[string]interface{}{"kind": "ServiceAccount", "name": "sa", "namespace": "staging"},
+				map[string]interface{}{"kind": "ServiceAccount", "name": "sa", "namespace": "dev"},
+				map[string]interface{}{"kind": "Group", "name": "admins"},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			item := unstructured.Unstructured{Object: tt.item}
+			renameNamespace(item, renames, tt.deep)
+			if !reflect.DeepEqual(item.Object, tt.want) {
+				t.Errorf("got %v, want %v", item.Object, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// runReport is a machine-readable summary of a run. It is safe for concurrent use.
+// Errors are collected by using the report as an additional output of the logger.
+type runReport struct {
+	mu sync.Mutex
+
+	Start          time.Time         `json:"start"`
+	End            time.Time         `json:"end"`
+	ClusterVersion string            `json:"clusterVersion,omitempty"`
+	Flags          map[string]string `json:"flags"`
+	Written        uint64            `json:"written"`
+	Resources      map[string]uint64 `json:"resources"`
+	Namespaces     map[string]uint64 `json:"namespaces"`
+	Errors         []string          `json:"errors"`
+}
+
+func newRunReport(start time.Time) *runReport {
+	r := &runReport{
+		Start:      start,
+		Flags:      make(map[string]string),
+		Resources:  make(map[string]uint64),
+		Namespaces: make(map[string]uint64),
+		Errors:     []string{},
+	}
+	flag.VisitAll(func(f *flag.Flag) {
+		r.Flags[f.Name] = redactUserinfo(f.Value.String())
+	})
+	return r
+}
+
+// redactUserinfo masks the password of a URL like '-proxy-url', other values are kept.
+func redactUserinfo(value string) string {
+	u, err := url.Parse(value)
+	if err != nil || u.User == nil {
+		return value
+	}
+	return u.Redacted()
+}
+
+// count records a written object.
+func (r *runReport) count(gvr schema.GroupVersionResource, namespace string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Written++
+	r.Resources[gvr.GroupVersion().String()+" "+gvr.Resource]++
+	if namespace != "" {
+		r.Namespaces[namespace]++
+	}
+}
+
+// Write records each logged line as an error, except for the warnings.
+func (r *runReport) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		if strings.HasPrefix(logMessage(line), "warning:") {
+			continue
+		}
+		r.Errors = append(r.Errors, line)
+	}
+	return len(p), nil
+}
+
+// logMessage returns the line without the date and time prepended by the logger.
+func logMessage(line string) string {
+	for {
+		field, rest, ok := strings.Cut(line, " ")
+		if !ok || field == "" || strings.Trim(field, "0123456789/:.") != "" {
+			return line
+		}
+		line = rest
+	}
+}
+
+func (r *runReport) write(filename string, end time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.End = end
+	content, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed marshalling: %v", err)
+	}
+	if err := os.WriteFile(filename, append(content, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed writing: %v", err)
+	}
+	return nil
+}
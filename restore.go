@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const restoreScriptFilename = "apply.sh"
+
+// restoreScript collects the written manifests for a script applying them with kubectl. It is safe for concurrent use.
+type restoreScript struct {
+	mu sync.Mutex
+	// versions are the group versions applied for each resource, nil for all
+	versions map[schema.GroupResource]string
+	seen     map[string]bool
+	// files are relative to the output directory
+	namespaces []string
+	crds       []string
+	others     []string
+}
+
+// newRestoreScript applies each dumped resource only in one group version, the preferred one of the group when it serves it.
+// Resources of aliased groups are left out when their current group is dumped too, e.g. the ingresses of extensions/v1beta1.
+func newRestoreScript(dumped []groupVersionResources) *restoreScript {
+	versions := make(map[schema.GroupResource]string)
+	for _, preferred := range []bool{true, false} {
+		for _, gv := range dumped {
+			if (gv.version.GroupVersion == gv.group.PreferredVersion.GroupVersion) != preferred {
+				continue
+			}
+			for _, res := range gv.resources {
+				gr := schema.GroupResource{Group: gv.group.Name, Resource: res.Name}
+				if _, ok := versions[gr]; !ok && !strings.Contains(res.Name, "/") {
+					versions[gr] = gv.version.Version
+				}
+			}
+		}
+	}
+
+	for gr := range versions {
+		alias, ok := groupAliases[gr]
+		if !ok {
+			continue
+		}
+		if _, ok := versions[schema.GroupResource{Group: alias, Resource: gr.Resource}]; ok {
+			versions[gr] = ""
+		}
+	}
+	return &restoreScript{versions: versions}
+}
+
+// add records the manifest of the group version resource, each file once.
+// Manifests of the resource in other group versions are left out, they would overwrite each other.
+func (s *restoreScript) add(gvr schema.GroupVersionResource, filename string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.versions != nil && s.versions[gvr.GroupResource()] != gvr.Version {
+		return
+	}
+	if s.seen[filename] {
+		return
+	}
+	if s.seen == nil {
+		s.seen = make(map[string]bool)
+	}
+	s.seen[filename] = true
+
+	switch {
+	case gvr.Group == "" && gvr.Resource == "namespaces":
+		s.namespaces = append(s.namespaces, filename)
+	case gvr.Group == "apiextensions.k8s.io" && gvr.Resource == "customresourcedefinitions":
+		s.crds = append(s.crds, filename)
+	default:
+		s.others = append(s.others, filename)
+	}
+}
+
+// script returns a shell script applying the namespaces first, then the custom resource definitions and then all other manifests.
+func (s *restoreScript) script() []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var b strings.Builder
+	b.WriteString("#!/bin/sh\n")
+	b.WriteString("# generated by kubedump, applies the dumped manifests in dependency order\n")
+	b.WriteString("set -e\n")
+	b.WriteString("cd \"$(dirname \"$0\")\"\n")
+
+	for _, files := range [][]string{s.namespaces, s.crds, s.others} {
+		sort.Strings(files)
+		for _, file := range files {
+			fmt.Fprintf(&b, "\"${KUBECTL:-kubectl}\" apply -f %s\n", shellQuote(filepath.ToSlash(file)))
+		}
+	}
+	return []byte(b.String())
+}
+
+// write writes the executable script to the root of the output directory.
+func (s *restoreScript) write(outDir string) error {
+	if err := os.MkdirAll(outDir, os.ModePerm); err != nil {
+		return fmt.Errorf("failed creating dir %q: %v", outDir, err)
+	}
+
+	filename := filepath.Join(outDir, restoreScriptFilename)
+	if err := os.WriteFile(filename, s.script(), 0o755); err != nil {
+		return fmt.Errorf("failed writing file %q: %v", filename, err)
+	}
+	return nil
+}
+
+// shellQuote quotes the value for a POSIX shell.
+func shellQuote(val string) string {
+	return "'" + strings.ReplaceAll(val, "'", `'\''`) + "'"
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestRestoreScript(t *testing.T) {
+	s := &restoreScript{}
+	s.add(schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}, "namespaced/ns1/deployments.apps/web.yaml")
+	s.add(schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}, "namespaced/ns1/configmaps/it's.yaml")
+	s.add(schema.GroupVersionResource{Group: "apiextensions.k8s.io", Version: "v1", Resource: "customresourcedefinitions"}, "clusterscoped/customresourcedefinitions.apiextensions.k8s.io/foos.example.com.yaml")
+	s.add(schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}, "clusterscoped/namespaces/ns1.yaml")
+
+	want := `#!/bin/sh
+# generated by kubedump, applies the dumped manifests in dependency order
+set -e
+cd "$(dirname "$0")"
+"${KUBECTL:-kubectl}" apply -f 'clusterscoped/namespaces/ns1.yaml'
+"${KUBECTL:-kubectl}" apply -f 'clusterscoped/customresourcedefinitions.apiextensions.k8s.io/foos.example.com.yaml'
+"${KUBECTL:-kubectl}" apply -f 'namespaced/ns1/configmaps/it'\''s.yaml'
+"${KUBECTL:-kubectl}" apply -f 'namespaced/ns1/deployments.apps/web.yaml'
+`
+	if got := string(s.script()); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRestoreScriptDuplicates(t *testing.T) {
+	group := func(name, preferred string) metav1.APIGroup {
+		return metav1.APIGroup{Name: name, PreferredVersion: metav1.GroupVersionForDiscovery{GroupVersion: strings.TrimPrefix(name+"/"+preferred, "/"), Version: preferred}}
+	}
+	version := func(group, version string) metav1.GroupVersionForDiscovery {
+		return metav1.GroupVersionForDiscovery{GroupVersion: strings.TrimPrefix(group+"/"+version, "/"), Version: version}
+	}
+	resources := func(names ...string) []metav1.APIResource {
+		var res []metav1.APIResource
+		for _, name := range names {
+			res = append(res, metav1.APIResource{Name: name})
+		}
+		return res
+	}
+
+	s := newRestoreScript([]groupVersionResources{
+		{group: group("autoscaling", "v2"), version: version("autoscaling", "v1"), resources: resources("horizontalpodautoscalers")},
+		{group: group("autoscaling", "v2"), version: version("autoscaling", "v2"), resources: resources("horizontalpodautoscalers", "horizontalpodautoscalers/status")},
+		{group: group("extensions", "v1beta1"), version: version("extensions", "v1beta1"), resources: resources("ingresses", "podsecuritypolicies")},
+		{group: group("networking.k8s.io", "v1"), version: version("networking.k8s.io", "v1"), resources: resources("ingresses")},
+	})
+
+	s.add(schema.GroupVersionResource{Group: "autoscaling", Version: "v1", Resource: "horizontalpodautoscalers"}, "namespaced/ns1/horizontalpodautoscalers.autoscaling/web.yaml")
+	s.add(schema.GroupVersionResource{Group: "autoscaling", Version: "v2", Resource: "horizontalpodautoscalers"}, "namespaced/ns1/horizontalpodautoscalers.autoscaling/web.yaml")
+	s.add(schema.GroupVersionResource{Group: "autoscaling", Version: "v2", Resource: "horizontalpodautoscalers"}, "namespaced/ns1/horizontalpodautoscalers.autoscaling/web.yaml")
+	s.add(schema.GroupVersionResource{Group: "extensions", Version: "v1beta1", Resource: "ingresses"}, "namespaced/ns1/ingresses.extensions/web.yaml")
+	s.add(schema.GroupVersionResource{Group: "networking.k8s.io", Version: "v1", Resource: "ingresses"}, "namespaced/ns1/ingresses.networking.k8s.io/web.yaml")
+	s.add(schema.GroupVersionResource{Group: "extensions", Version: "v1beta1", Resource: "podsecuritypolicies"}, "clusterscoped/podsecuritypolicies.extensions/restricted.yaml")
+
+	want := `#!/bin/sh
+# generated by kubedump, applies the dumped manifests in dependency order
+set -e
+cd "$(dirname "$0")"
+"${KUBECTL:-kubectl}" apply -f 'clusterscoped/podsecuritypolicies.extensions/restricted.yaml'
+"${KUBECTL:-kubectl}" apply -f 'namespaced/ns1/horizontalpodautoscalers.autoscaling/web.yaml'
+"${KUBECTL:-kubectl}" apply -f 'namespaced/ns1/ingresses.networking.k8s.io/web.yaml'
+`
+	if got := string(s.script()); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
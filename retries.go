@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+)
+
+// listBackoff is used for retrying failed list calls.
+var listBackoff = wait.Backoff{
+	Steps:    4,
+	Duration: 500 * time.Millisecond,
+	Factor:   2,
+	Jitter:   0.1,
+}
+
+// retryBudget limits the number of retries of the whole run, so a broad outage doesn't
+// cause a retry storm. It is safe for concurrent use.
+type retryBudget struct {
+	max       uint64
+	remaining int64
+	exhausted sync.Once
+}
+
+func newRetryBudget(max uint64) *retryBudget {
+	return &retryBudget{max: max, remaining: int64(max)}
+}
+
+// take reports whether another retry is allowed. The exhaustion is logged once, unless there were no retries at all.
+func (b *retryBudget) take() bool {
+	if atomic.AddInt64(&b.remaining, -1) >= 0 {
+		return true
+	}
+	if b.max > 0 {
+		b.exhausted.Do(func() {
+			log.Println("retry budget exhausted, further failures are not retried")
+		})
+	}
+	return false
+}
+
+// isTransient reports whether the error is likely to go away by retrying.
+func isTransient(err error) bool {
+	return apierrors.IsServerTimeout(err) ||
+		apierrors.IsTimeout(err) ||
+		apierrors.IsTooManyRequests(err) ||
+		apierrors.IsInternalError(err) ||
+		apierrors.IsServiceUnavailable(err) ||
+		utilnet.IsConnectionReset(err) ||
+		utilnet.IsProbableEOF(err)
+}
+
+// listWithRetries retries transient failures of the list while the budget allows it.
+//
+// An unauthorized list is retried once without taking from the budget: when the credentials
+// of a long-running dump expired, the credential plugin of the kubeconfig refreshes them
+// on the 401 response and a token file is read again, so the retry authenticates with the new credentials.
+// Tokens and certificates written into the kubeconfig itself can't be refreshed.
+func listWithRetries(ctx context.Context, budget *retryBudget, list func() (*unstructured.UnstructuredList, error)) (*unstructured.UnstructuredList, error) {
+	var (
+		result    *unstructured.UnstructuredList
+		refreshed bool
+	)
+	err := retry.OnError(listBackoff, func(err error) bool {
+		if ctx.Err() != nil {
+			return false
+		}
+		if apierrors.IsUnauthorized(err) && !refreshed {
+			refreshed = true
+			return true
+		}
+		return isTransient(err) && budget.take()
+	}, func() (err error) {
+		result, err = list()
+		return err
+	})
+	return result, err
+}
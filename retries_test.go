@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+)
+
+func TestListWithRetries(t *testing.T) {
+	backoff := listBackoff
+	listBackoff.Duration = 0
+	defer func() { listBackoff = backoff }()
+
+	transient := apierrors.NewServiceUnavailable("overloaded")
+	permanent := apierrors.NewForbidden(schema.GroupResource{Resource: "secrets"}, "", errors.New("denied"))
+	unauthorized := apierrors.NewUnauthorized("token expired")
+
+	tests := []struct {
+		name         string
+		budget       uint64
+		errs         []error
+		wantCalls    int
+		wantErr      bool
+		wantRemained int64
+	}{
+		{name: "success", budget: 5, wantCalls: 1, wantRemained: 5},
+		{name: "transient error", budget: 5, errs: []error{transient, transient}, wantCalls: 3, wantRemained: 3},
+		{name: "permanent error", budget: 5, errs: []error{permanent}, wantCalls: 1, wantErr: true, wantRemained: 5},
+		{name: "budget exhausted", budget: 1, errs: []error{transient, transient}, wantCalls: 2, wantErr: true, wantRemained: -1},
+		{name: "no budget", budget: 0, errs: []error{transient}, wantCalls: 1, wantErr: true, wantRemained: -1},
+		{name: "unauthorized once", budget: 5, errs: []error{unauthorized}, wantCalls: 2, wantRemained: 5},
+		{name: "unauthorized twice", budget: 5, errs: []error{unauthorized, unauthorized}, wantCalls: 2, wantErr: true, wantRemained: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			budget := newRetryBudget(tt.budget)
+
+			calls := 0
+			_, err := listWithRetries(context.Background(), budget, func() (*unstructured.UnstructuredList, error) {
+				calls++
+				if calls <= len(tt.errs) {
+					return nil, tt.errs[calls-1]
+				}
+				return &unstructured.UnstructuredList{}, nil
+			})
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, wantErr %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("got %d calls, want %d", calls, tt.wantCalls)
+			}
+			if budget.remaining != tt.wantRemained {
+				t.Errorf("got %d remaining retries, want %d", budget.remaining, tt.wantRemained)
+			}
+		})
+	}
+}
+
+func TestListWithRetriesExpiredToken(t *testing.T) {
+	backoff := listBackoff
+	listBackoff.Duration = 0
+	defer func() { listBackoff = backoff }()
+
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		// the token expires after the first request and is refreshed by the next one
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"Unauthorized","code":401}`)
+			return
+		}
+		fmt.Fprint(w, `{"kind":"NamespaceList","apiVersion":"v1","metadata":{},"items":[{"metadata":{"name":"default"}}]}`)
+	}))
+	defer server.Close()
+
+	dynamicClient, err := dynamic.NewForConfig(&rest.Config{Host: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := listWithRetries(context.Background(), newRetryBudget(0), func() (*unstructured.UnstructuredList, error) {
+		return listResource(context.Background(), dynamicClient.Resource(namespacesGVR), listOptions{})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Items) != 1 {
+		t.Errorf("got %d items, want 1", len(list.Items))
+	}
+	if requests != 2 {
+		t.Errorf("got %d requests, want 2", requests)
+	}
+}
+
+// flakyResource fails the first lists in the namespace 'ns1' with a 503.
+type flakyResource struct {
+	dynamic.NamespaceableResourceInterface
+	namespace string
+	failures  *int32
+}
+
+func (r *flakyResource) Namespace(ns string) dynamic.ResourceInterface {
+	return &flakyResource{namespace: ns, failures: r.failures}
+}
+
+func (r *flakyResource) List(context.Context, metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	if r.namespace == "ns1" && atomic.AddInt32(r.failures, -1) >= 0 {
+		return nil, apierrors.NewServiceUnavailable("overloaded")
+	}
+	list := &unstructured.UnstructuredList{}
+	item := unstructured.Unstructured{}
+	item.SetNamespace(r.namespace)
+	list.Items = append(list.Items, item)
+	return list, nil
+}
+
+func TestListWithRetriesPerNamespace(t *testing.T) {
+	backoff := listBackoff
+	listBackoff.Duration = 0
+	defer func() { listBackoff = backoff }()
+
+	failures := int32(1)
+	client := &flakyResource{failures: &failures}
+	var calls int
+	list, err := listWithRetries(context.Background(), newRetryBudget(5), func() (*unstructured.UnstructuredList, error) {
+		calls++
+		return listNamespaces(context.Background(), client, []string{"ns0", "ns1"}, listOptions{}, make(chan struct{}, 1), nil)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Errorf("got %d calls, want the wrapped 503 to be retried once", calls)
+	}
+	if len(list.Items) != 2 {
+		t.Errorf("got %d items, want 2", len(list.Items))
+	}
+
+	failures = 1
+	_, err = listNamespaces(context.Background(), client, []string{"ns1"}, listOptions{}, make(chan struct{}, 1), nil)
+	if !apierrors.IsServiceUnavailable(err) || !isTransient(err) {
+		t.Errorf("got %v, want a transient service unavailable error", err)
+	}
+}
+
+func TestListWithRetriesExpiredTokenFile(t *testing.T) {
+	backoff := listBackoff
+	listBackoff.Duration = 0
+	defer func() { listBackoff = backoff }()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("expired\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("Content-Type", "application/json")
+		if r.Header.Get("Authorization") != "Bearer refreshed" {
+			// the token is rotated while the expired one is rejected
+			if err := os.WriteFile(tokenFile, []byte("refreshed\n"), 0o600); err != nil {
+				t.Error(err)
+			}
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"Unauthorized","code":401}`)
+			return
+		}
+		fmt.Fprint(w, `{"kind":"NamespaceList","apiVersion":"v1","metadata":{},"items":[{"metadata":{"name":"default"}}]}`)
+	}))
+	defer server.Close()
+
+	config := &rest.Config{Host: server.URL, BearerTokenFile: tokenFile}
+	reloadTokenFileOnUnauthorized(config)
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := listWithRetries(context.Background(), newRetryBudget(0), func() (*unstructured.UnstructuredList, error) {
+		return listResource(context.Background(), dynamicClient.Resource(namespacesGVR), listOptions{})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Items) != 1 {
+		t.Errorf("got %d items, want 1", len(list.Items))
+	}
+	if requests != 2 {
+		t.Errorf("got %d requests, want 2", requests)
+	}
+}
+
+func TestRetryBudgetExhaustedLog(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	if newRetryBudget(0).take() {
+		t.Fatal("expected no retries without a budget")
+	}
+	if buf.Len() != 0 {
+		t.Errorf("got %q, want no exhaustion logged without a budget", buf.String())
+	}
+
+	budget := newRetryBudget(1)
+	for i := 0; i < 3; i++ {
+		budget.take()
+	}
+	if got := strings.Count(buf.String(), "retry budget exhausted"); got != 1 {
+		t.Errorf("got the exhaustion logged %d times, want once: %q", got, buf.String())
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"context"
+
+	"golang.org/x/exp/slices"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
+)
+
+// selectNamespaces lists the names of the namespaces matching the label selector.
+// When namespaces are wanted explicitly, only those of them matching the selector are returned.
+func selectNamespaces(ctx context.Context, dynamicClient dynamic.Interface, selector string, want []string) ([]string, error) {
+	list, err := dynamicClient.Resource(namespacesGVR).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, item := range list.Items {
+		if len(want) > 0 && want[0] != "" && !slices.Contains(want, item.GetName()) {
+			continue
+		}
+		names = append(names, item.GetName())
+	}
+	return names, nil
+}
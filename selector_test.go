@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/dynamic"
+)
+
+// labeledResource serves the objects matching the label selector of the list options.
+type labeledResource struct {
+	dynamic.NamespaceableResourceInterface
+	objects map[string]map[string]string
+}
+
+func (r *labeledResource) List(_ context.Context, opts metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	selector, err := labels.Parse(opts.LabelSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	list := &unstructured.UnstructuredList{}
+	for _, name := range []string{"a", "b", "c"} {
+		if !selector.Matches(labels.Set(r.objects[name])) {
+			continue
+		}
+		item := unstructured.Unstructured{}
+		item.SetName(name)
+		item.SetLabels(r.objects[name])
+		list.Items = append(list.Items, item)
+	}
+	return list, nil
+}
+
+func TestSelectNamespaces(t *testing.T) {
+	client := &fakeDynamicClient{resource: &labeledResource{objects: map[string]map[string]string{
+		"a": {"backup": "true"},
+		"b": {"backup": "false"},
+		"c": {"backup": "true", "team": "x"},
+	}}}
+
+	tests := []struct {
+		name     string
+		selector string
+		want     []string
+		expected []string
+	}{
+		{name: "all matching", selector: "backup=true", want: []string{""}, expected: []string{"a", "c"}},
+		{name: "matching and wanted", selector: "backup=true", want: []string{"c", "b"}, expected: []string{"c"}},
+		{name: "none matching", selector: "team=y", want: []string{""}, expected: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := selectNamespaces(context.Background(), client, tt.selector, tt.want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("got %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// readSinceFile reads the timestamp of the last successful dump.
+// A missing file results in the zero time, so everything is dumped on the first run.
+func readSinceFile(filename string) (time.Time, error) {
+	content, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed reading since file %q: %v", filename, err)
+	}
+
+	since, err := time.Parse(time.RFC3339, strings.TrimSpace(string(content)))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed parsing since file %q: %v", filename, err)
+	}
+	return since, nil
+}
+
+func writeSinceFile(filename string, since time.Time) error {
+	if err := os.WriteFile(filename, []byte(since.UTC().Format(time.RFC3339)+"\n"), 0o644); err != nil {
+		return fmt.Errorf("failed writing since file %q: %v", filename, err)
+	}
+	return nil
+}
+
+// lastModified returns the latest of the creation time and the times of the managed fields.
+func lastModified(item unstructured.Unstructured) time.Time {
+	modified := item.GetCreationTimestamp().Time
+	for _, entry := range item.GetManagedFields() {
+		if entry.Time != nil && entry.Time.After(modified) {
+			modified = entry.Time.Time
+		}
+	}
+	return modified
+}
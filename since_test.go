@@ -0,0 +1,55 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestSinceFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "since")
+
+	since, err := readSinceFile(filename)
+	if err != nil {
+		t.Fatalf("failed reading missing file: %v", err)
+	}
+	if !since.IsZero() {
+		t.Errorf("got %v for a missing file, want zero time", since)
+	}
+
+	want := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := writeSinceFile(filename, want.Add(500*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+
+	since, err = readSinceFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !since.Equal(want) {
+		t.Errorf("got %v, want %v", since, want)
+	}
+}
+
+func TestLastModified(t *testing.T) {
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	updated := created.Add(time.Hour)
+
+	item := unstructured.Unstructured{}
+	item.SetCreationTimestamp(metav1.NewTime(created))
+	if got := lastModified(item); !got.Equal(created) {
+		t.Errorf("got %v, want creation time %v", got, created)
+	}
+
+	updatedTime := metav1.NewTime(updated)
+	item.SetManagedFields([]metav1.ManagedFieldsEntry{
+		{Manager: "a", Time: &updatedTime},
+		{Manager: "b"},
+	})
+	if got := lastModified(item); !got.Equal(updated) {
+		t.Errorf("got %v, want managed fields time %v", got, updated)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+type singleFileObject struct {
+	kind      string
+	namespace string
+	name      string
+	manifest  []byte
+}
+
+// singleFileWriter combines all objects into a single YAML file. The objects are kept
+// until the file is written, sorted by kind and name. It is safe for concurrent use.
+type singleFileWriter struct {
+	filename string
+	mu       sync.Mutex
+	objects  []singleFileObject
+}
+
+func newSingleFileWriter(filename string) *singleFileWriter {
+	return &singleFileWriter{filename: filename}
+}
+
+func (w *singleFileWriter) add(kind, namespace, name string, manifest []byte) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.objects = append(w.objects, singleFileObject{kind: kind, namespace: namespace, name: name, manifest: manifest})
+}
+
+// content returns the sorted objects, each separated by '---' and preceded by a header comment.
+func (w *singleFileWriter) content() []byte {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	sort.Slice(w.objects, func(i, j int) bool {
+		a, b := w.objects[i], w.objects[j]
+		if a.kind != b.kind {
+			return a.kind < b.kind
+		}
+		if a.name != b.name {
+			return a.name < b.name
+		}
+		return a.namespace < b.namespace
+	})
+
+	var buf bytes.Buffer
+	for _, obj := range w.objects {
+		fmt.Fprintf(&buf, "---\n# === %s/%s/%s ===\n", obj.kind, obj.namespace, obj.name)
+		buf.Write(obj.manifest)
+	}
+	return buf.Bytes()
+}
+
+// write writes the combined file.
+func (w *singleFileWriter) write() error {
+	if err := os.MkdirAll(filepath.Dir(w.filename), os.ModePerm); err != nil {
+		return fmt.Errorf("failed creating dir %q: %v", filepath.Dir(w.filename), err)
+	}
+	if err := os.WriteFile(w.filename, w.content(), os.ModePerm); err != nil {
+		return fmt.Errorf("failed writing file %q: %v", w.filename, err)
+	}
+	return nil
+}
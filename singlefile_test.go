@@ -0,0 +1,42 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSingleFileWriter(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "dump", "all.yaml")
+
+	w := newSingleFileWriter(filename)
+	w.add("Service", "ns1", "web", []byte("kind: Service\n"))
+	w.add("ConfigMap", "ns2", "b", []byte("kind: ConfigMap\n"))
+	w.add("Namespace", "", "ns1", []byte("kind: Namespace\n"))
+	w.add("ConfigMap", "ns1", "a", []byte("kind: ConfigMap\n"))
+	if err := w.write(); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `---
+# === ConfigMap/ns1/a ===
+kind: ConfigMap
+---
+# === ConfigMap/ns2/b ===
+kind: ConfigMap
+---
+# === Namespace//ns1 ===
+kind: Namespace
+---
+# === Service/ns1/web ===
+kind: Service
+`
+	if string(got) != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+
+	// pure Go driver, so kubedump still builds without cgo
+	_ "modernc.org/sqlite"
+)
+
+const sqliteSchema = `CREATE TABLE IF NOT EXISTS objects (
+	"group" TEXT NOT NULL,
+	version TEXT NOT NULL,
+	kind TEXT NOT NULL,
+	namespace TEXT NOT NULL,
+	name TEXT NOT NULL,
+	uid TEXT NOT NULL,
+	body TEXT NOT NULL,
+	PRIMARY KEY ("group", version, kind, namespace, name)
+)`
+
+// sqliteWriter inserts the objects into the 'objects' table of a SQLite database, replacing the rows of previous runs.
+// All objects are inserted in a single transaction, which is committed on close. It is safe for concurrent use.
+type sqliteWriter struct {
+	mu     sync.Mutex
+	db     *sql.DB
+	tx     *sql.Tx
+	insert *sql.Stmt
+}
+
+// openSQLite opens the database and creates the schema when it doesn't exist yet.
+func openSQLite(filename string) (*sqliteWriter, error) {
+	db, err := sql.Open("sqlite", filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed opening database %q: %v", filename, err)
+	}
+	// the writers take turns on a single connection
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed creating schema: %v", err)
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed starting transaction: %v", err)
+	}
+	insert, err := tx.Prepare(`INSERT OR REPLACE INTO objects ("group", version, kind, namespace, name, uid, body) VALUES (?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		tx.Rollback()
+		db.Close()
+		return nil, fmt.Errorf("failed preparing insert: %v", err)
+	}
+	return &sqliteWriter{db: db, tx: tx, insert: insert}, nil
+}
+
+// add inserts the object, the uid has to be passed as it might have been cleaned.
+func (w *sqliteWriter) add(gvr schema.GroupVersionResource, item unstructured.Unstructured, uid types.UID) error {
+	body, err := json.Marshal(item.Object)
+	if err != nil {
+		return fmt.Errorf("failed marshalling: %v", err)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, err := w.insert.Exec(gvr.Group, gvr.Version, item.GetKind(), item.GetNamespace(), item.GetName(), string(uid), string(body)); err != nil {
+		return fmt.Errorf("failed inserting into database: %v", err)
+	}
+	return nil
+}
+
+// close commits the inserted objects.
+func (w *sqliteWriter) close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.tx.Commit(); err != nil {
+		w.db.Close()
+		return fmt.Errorf("failed committing: %v", err)
+	}
+	return w.db.Close()
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestSQLiteWriter(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "dump.db")
+	deployments := schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+
+	newItem := func(name, image string) unstructured.Unstructured {
+		item := unstructured.Unstructured{Object: map[string]interface{}{"spec": map[string]interface{}{"image": image}}}
+		item.SetKind("Deployment")
+		item.SetNamespace("ns")
+		item.SetName(name)
+		return item
+	}
+
+	// the second run replaces the rows of the first one
+	for _, image := range []string{"nginx:1", "nginx:2"} {
+		writer, err := openSQLite(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range []string{"a", "b"} {
+			if err := writer.add(deployments, newItem(name, image), types.UID("uid-"+name)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := writer.close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	db, err := sql.Open("sqlite", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM objects`).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("got %d rows, want 2", count)
+	}
+
+	var group, version, kind, uid, image string
+	row := db.QueryRow(`SELECT "group", version, kind, uid, json_extract(body, '$.spec.image') FROM objects WHERE namespace = 'ns' AND name = 'b'`)
+	if err := row.Scan(&group, &version, &kind, &uid, &image); err != nil {
+		t.Fatal(err)
+	}
+	if group != "apps" || version != "v1" || kind != "Deployment" || uid != "uid-b" || image != "nginx:2" {
+		t.Errorf("got %q %q %q %q %q", group, version, kind, uid, image)
+	}
+}
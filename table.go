@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/duration"
+)
+
+const (
+	outputFormatYAML  = "yaml"
+	outputFormatTable = "table"
+)
+
+type tableRow struct {
+	kind      string
+	namespace string
+	name      string
+	created   time.Time
+}
+
+// objectTable collects the objects for printing them as a table instead of writing them.
+// It is safe for concurrent use.
+type objectTable struct {
+	mu   sync.Mutex
+	rows []tableRow
+}
+
+func (t *objectTable) add(item unstructured.Unstructured) {
+	row := tableRow{
+		kind:      item.GetKind(),
+		namespace: item.GetNamespace(),
+		name:      item.GetName(),
+		created:   item.GetCreationTimestamp().Time,
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rows = append(t.rows, row)
+}
+
+// print writes the collected objects ordered by kind, namespace and name.
+func (t *objectTable) print(w io.Writer, now time.Time) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	sort.Slice(t.rows, func(i, j int) bool {
+		a, b := t.rows[i], t.rows[j]
+		if a.kind != b.kind {
+			return a.kind < b.kind
+		}
+		if a.namespace != b.namespace {
+			return a.namespace < b.namespace
+		}
+		return a.name < b.name
+	})
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "KIND\tNAMESPACE\tNAME\tAGE")
+	for _, row := range t.rows {
+		age := "<unknown>"
+		if !row.created.IsZero() {
+			age = duration.HumanDuration(now.Sub(row.created))
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", row.kind, row.namespace, row.name, age)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestObjectTablePrint(t *testing.T) {
+	now := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	deployment := unstructured.Unstructured{}
+	deployment.SetKind("Deployment")
+	deployment.SetNamespace("default")
+	deployment.SetName("web")
+	deployment.SetCreationTimestamp(metav1.NewTime(now.Add(-2 * time.Hour)))
+
+	configMap := unstructured.Unstructured{}
+	configMap.SetKind("ConfigMap")
+	configMap.SetNamespace("default")
+	configMap.SetName("config")
+
+	table := &objectTable{}
+	table.add(deployment)
+	table.add(configMap)
+
+	var buf bytes.Buffer
+	if err := table.print(&buf, now); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "" +
+		"KIND        NAMESPACE  NAME    AGE\n" +
+		"ConfigMap   default    config  <unknown>\n" +
+		"Deployment  default    web     120m\n"
+	if got := buf.String(); got != want {
+		t.Errorf("print() =\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"runtime"
+	"strconv"
+	"time"
+)
+
+const (
+	threadsAuto = "auto"
+	// autoThreadsLatencyStep adds another thread per CPU for every step of API latency
+	autoThreadsLatencyStep = 50 * time.Millisecond
+	autoThreadsMin         = 4
+	autoThreadsMax         = 64
+)
+
+// threadsFlag is a number of threads or "auto".
+type threadsFlag struct {
+	n    uint64
+	auto bool
+}
+
+func newThreadsFlag(defaultVal string) *threadsFlag {
+	f := &threadsFlag{}
+	if err := f.Set(defaultVal); err != nil {
+		panic(err)
+	}
+	return f
+}
+
+func (f *threadsFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	if f.auto {
+		return threadsAuto
+	}
+	return strconv.FormatUint(f.n, 10)
+}
+
+func (f *threadsFlag) Set(val string) error {
+	if val == threadsAuto {
+		f.n, f.auto = 0, true
+		return nil
+	}
+
+	n, err := strconv.ParseUint(val, 10, 64)
+	if err != nil {
+		return err
+	}
+	f.n, f.auto = n, false
+	return nil
+}
+
+// resolve returns the number of threads, which for "auto" depends on the latency of the API server.
+func (f *threadsFlag) resolve(latency time.Duration) uint64 {
+	if !f.auto {
+		return f.n
+	}
+	return autoThreads(runtime.NumCPU(), latency)
+}
+
+// autoThreads uses a thread per CPU plus another thread per CPU for every autoThreadsLatencyStep
+// of latency, as listing mostly waits for the API server. The result is between autoThreadsMin and autoThreadsMax.
+func autoThreads(cpus int, latency time.Duration) uint64 {
+	threads := uint64(cpus) * uint64(1+latency/autoThreadsLatencyStep)
+	if threads < autoThreadsMin {
+		return autoThreadsMin
+	}
+	if threads > autoThreadsMax {
+		return autoThreadsMax
+	}
+	return threads
+}
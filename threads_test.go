@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestThreadsFlag(t *testing.T) {
+	f := newThreadsFlag("10")
+	if f.auto || f.resolve(time.Second) != 10 || f.String() != "10" {
+		t.Errorf("got %+v, want 10 threads", f)
+	}
+
+	if err := f.Set(threadsAuto); err != nil {
+		t.Fatal(err)
+	}
+	if !f.auto || f.String() != threadsAuto {
+		t.Errorf("got %+v, want auto", f)
+	}
+
+	if err := f.Set("many"); err == nil {
+		t.Error("expected an error for an invalid number")
+	}
+}
+
+func TestAutoThreads(t *testing.T) {
+	tests := []struct {
+		cpus    int
+		latency time.Duration
+		want    uint64
+	}{
+		{cpus: 1, latency: 0, want: autoThreadsMin},
+		{cpus: 8, latency: 10 * time.Millisecond, want: 8},
+		{cpus: 8, latency: 120 * time.Millisecond, want: 24},
+		{cpus: 32, latency: time.Second, want: autoThreadsMax},
+	}
+
+	for _, tt := range tests {
+		if got := autoThreads(tt.cpus, tt.latency); got != tt.want {
+			t.Errorf("autoThreads(%d, %v) = %d, want %d", tt.cpus, tt.latency, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+type resourceTiming struct {
+	gvr      schema.GroupVersionResource
+	duration time.Duration
+	items    int
+}
+
+// resourceTimings collects how long processing each resource took. It is safe for concurrent use.
+type resourceTimings struct {
+	mu      sync.Mutex
+	timings []resourceTiming
+}
+
+func (t *resourceTimings) add(timing resourceTiming) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.timings = append(t.timings, timing)
+}
+
+// slowest returns up to n timings, ordered from the slowest to the fastest.
+func (t *resourceTimings) slowest(n int) []resourceTiming {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	sorted := make([]resourceTiming, len(t.timings))
+	copy(sorted, t.timings)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].duration > sorted[j].duration
+	})
+
+	if len(sorted) > n {
+		sorted = sorted[:n]
+	}
+	return sorted
+}
+
+// byResource returns all timings, ordered by group, resource and version.
+func (t *resourceTimings) byResource() []resourceTiming {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	sorted := make([]resourceTiming, len(t.timings))
+	copy(sorted, t.timings)
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i].gvr, sorted[j].gvr
+		if a.Group != b.Group {
+			return a.Group < b.Group
+		}
+		if a.Resource != b.Resource {
+			return a.Resource < b.Resource
+		}
+		return a.Version < b.Version
+	})
+	return sorted
+}
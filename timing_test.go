@@ -0,0 +1,51 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestResourceTimingsSlowest(t *testing.T) {
+	timings := &resourceTimings{}
+	for i, d := range []time.Duration{2, 5, 1, 4} {
+		timings.add(resourceTiming{
+			gvr:      schema.GroupVersionResource{Resource: string(rune('a' + i))},
+			duration: d,
+		})
+	}
+
+	got := timings.slowest(3)
+	want := []string{"b", "d", "a"}
+	if len(got) != len(want) {
+		t.Fatalf("slowest() returned %d timings, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].gvr.Resource != want[i] {
+			t.Errorf("slowest()[%d] = %q, want %q", i, got[i].gvr.Resource, want[i])
+		}
+	}
+}
+
+func TestResourceTimingsByResource(t *testing.T) {
+	timings := &resourceTimings{}
+	for _, gvr := range []schema.GroupVersionResource{
+		{Group: "apps", Version: "v1", Resource: "deployments"},
+		{Version: "v1", Resource: "pods"},
+		{Group: "apps", Version: "v1", Resource: "daemonsets"},
+		{Version: "v1", Resource: "configmaps"},
+	} {
+		timings.add(resourceTiming{gvr: gvr})
+	}
+
+	var got []string
+	for _, timing := range timings.byResource() {
+		got = append(got, resourceAndGroupName(timing.gvr))
+	}
+	want := []string{"configmaps", "pods", "daemonsets.apps", "deployments.apps"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+const unavailableFilename = "unavailable.yaml"
+
+var apiServicesGVR = schema.GroupVersionResource{Group: "apiregistration.k8s.io", Version: "v1", Resource: "apiservices"}
+
+// aggregatedGroupVersions returns the group versions which are served by an extension API server
+// registered with an APIService instead of the Kubernetes API server itself.
+func aggregatedGroupVersions(ctx context.Context, dynamicClient dynamic.Interface) (map[string]bool, error) {
+	list, err := dynamicClient.Resource(apiServicesGVR).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	aggregated := make(map[string]bool)
+	for _, item := range list.Items {
+		service, found, _ := unstructured.NestedMap(item.Object, "spec", "service")
+		if !found || service == nil {
+			// served locally
+			continue
+		}
+
+		group, _, _ := unstructured.NestedString(item.Object, "spec", "group")
+		version, _, _ := unstructured.NestedString(item.Object, "spec", "version")
+		aggregated[schema.GroupVersion{Group: group, Version: version}.String()] = true
+	}
+	return aggregated, nil
+}
+
+type unavailableEntry struct {
+	Group   string `json:"group"`
+	Version string `json:"version"`
+	// Resource is empty when the whole group version couldn't be discovered
+	Resource string `json:"resource,omitempty"`
+	Error    string `json:"error"`
+}
+
+// unavailableReport collects the resources which are served by an APIService but couldn't be listed,
+// and the group versions served by an APIService which couldn't be discovered.
+// It is safe for concurrent use.
+type unavailableReport struct {
+	mu      sync.Mutex
+	entries []unavailableEntry
+}
+
+func (r *unavailableReport) add(gvr schema.GroupVersionResource, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.entries = append(r.entries, unavailableEntry{
+		Group:    gvr.Group,
+		Version:  gvr.Version,
+		Resource: gvr.Resource,
+		Error:    err.Error(),
+	})
+}
+
+// addDiscoveryFailures records the failures of the aggregated group versions.
+func (r *unavailableReport) addDiscoveryFailures(failed map[string]error, aggregated map[string]bool) {
+	for groupVersion, err := range failed {
+		if !aggregated[groupVersion] {
+			continue
+		}
+		gv, parseErr := schema.ParseGroupVersion(groupVersion)
+		if parseErr != nil {
+			continue
+		}
+		r.add(gv.WithResource(""), err)
+	}
+}
+
+// write writes the report, but only when there were unavailable resources.
+func (r *unavailableReport) write(outDir string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.entries) == 0 {
+		return nil
+	}
+
+	sort.Slice(r.entries, func(i, j int) bool {
+		a, b := r.entries[i], r.entries[j]
+		if a.Group != b.Group {
+			return a.Group < b.Group
+		}
+		if a.Version != b.Version {
+			return a.Version < b.Version
+		}
+		return a.Resource < b.Resource
+	})
+
+	return writeReport(outDir, unavailableFilename, r.entries)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"sigs.k8s.io/yaml"
+)
+
+// listedResource serves a fixed list.
+type listedResource struct {
+	dynamic.NamespaceableResourceInterface
+	items []unstructured.Unstructured
+}
+
+func (r *listedResource) List(context.Context, metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	return &unstructured.UnstructuredList{Items: r.items}, nil
+}
+
+func TestAggregatedGroupVersions(t *testing.T) {
+	apiService := func(group, version string, service map[string]interface{}) unstructured.Unstructured {
+		spec := map[string]interface{}{"group": group, "version": version}
+		if service != nil {
+			spec["service"] = service
+		}
+		return unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+	}
+	client := &fakeDynamicClient{resource: &listedResource{items: []unstructured.Unstructured{
+		apiService("apps", "v1", nil),
+		apiService("metrics.k8s.io", "v1beta1", map[string]interface{}{"name": "metrics-server", "namespace": "kube-system"}),
+	}}}
+
+	got, err := aggregatedGroupVersions(context.Background(), client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]bool{"metrics.k8s.io/v1beta1": true}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestUnavailableReport(t *testing.T) {
+	report := &unavailableReport{}
+	outDir := t.TempDir()
+
+	if err := report.write(outDir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(outDir, unavailableFilename)); !os.IsNotExist(err) {
+		t.Fatalf("expected no report without unavailable resources, got %v", err)
+	}
+
+	report.add(schema.GroupVersionResource{Group: "custom.metrics.k8s.io", Version: "v1beta2", Resource: "pods"}, errors.New("service unavailable"))
+	report.addDiscoveryFailures(map[string]error{
+		"metrics.k8s.io/v1beta1": errors.New("the server is currently unable to handle the request"),
+		"example.com/v1":         errors.New("not aggregated"),
+	}, map[string]bool{"metrics.k8s.io/v1beta1": true, "custom.metrics.k8s.io/v1beta2": true})
+
+	if err := report.write(outDir); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(filepath.Join(outDir, unavailableFilename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []unavailableEntry
+	if err := yaml.Unmarshal(content, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []unavailableEntry{
+		{Group: "custom.metrics.k8s.io", Version: "v1beta2", Resource: "pods", Error: "service unavailable"},
+		{Group: "metrics.k8s.io", Version: "v1beta1", Error: "the server is currently unable to handle the request"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	layoutDefault = "default"
+	// layoutVelero writes the objects like the contents of a Velero backup tarball
+	layoutVelero = "velero"
+)
+
+// veleroFormatVersion is the version of the backup format without the directories per API version.
+const veleroFormatVersion = "1"
+
+// veleroPath returns the path of the object relative to the root of the backup,
+// e.g. 'resources/deployments.apps/namespaces/<namespace>/<name>.json'.
+func veleroPath(gvr schema.GroupVersionResource, namespace, name string) string {
+	if namespace == "" {
+		return filepath.Join("resources", resourceAndGroupName(gvr), "cluster", name+".json")
+	}
+	return filepath.Join("resources", resourceAndGroupName(gvr), "namespaces", namespace, name+".json")
+}
+
+// writeVeleroObject writes the object as JSON to its path in the backup below the directory.
+// The namespace may differ from the one of the object, e.g. for namespaced objects without a namespace.
+func writeVeleroObject(dir string, gvr schema.GroupVersionResource, item unstructured.Unstructured, namespace, name string) error {
+	content, err := json.Marshal(item.Object)
+	if err != nil {
+		return fmt.Errorf("failed marshalling: %v", err)
+	}
+
+	filename := filepath.Join(dir, veleroPath(gvr, namespace, name))
+	if err := os.MkdirAll(filepath.Dir(filename), os.ModePerm); err != nil {
+		return fmt.Errorf("failed creating dir %q: %v", filepath.Dir(filename), err)
+	}
+	if err := writeFileAtomic(filename, content, os.ModePerm); err != nil {
+		return fmt.Errorf("failed writing file %q: %v", filename, err)
+	}
+	return nil
+}
+
+// writeVeleroMetadata writes the version of the backup format, which Velero reads before the resources.
+func writeVeleroMetadata(dir string) error {
+	metadataDir := filepath.Join(dir, "metadata")
+	if err := os.MkdirAll(metadataDir, os.ModePerm); err != nil {
+		return fmt.Errorf("failed creating dir %q: %v", metadataDir, err)
+	}
+	return writeFileAtomic(filepath.Join(metadataDir, "version"), []byte(veleroFormatVersion), os.ModePerm)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestVeleroPath(t *testing.T) {
+	tests := []struct {
+		gvr       schema.GroupVersionResource
+		namespace string
+		want      string
+	}{
+		{gvr: schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}, namespace: "ns", want: filepath.Join("resources", "deployments.apps", "namespaces", "ns", "web.json")},
+		{gvr: schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}, want: filepath.Join("resources", "namespaces", "cluster", "web.json")},
+	}
+
+	for _, tt := range tests {
+		if got := veleroPath(tt.gvr, tt.namespace, "web"); got != tt.want {
+			t.Errorf("veleroPath(%v, %q) = %q, want %q", tt.gvr, tt.namespace, got, tt.want)
+		}
+	}
+}
+
+func TestWriteYAMLVelero(t *testing.T) {
+	item := unstructured.Unstructured{}
+	item.SetAPIVersion("v1")
+	item.SetKind("ConfigMap")
+	item.SetNamespace("ns")
+	item.SetName("web")
+	item.SetResourceVersion("42")
+
+	opts := writeOptions{outDir: t.TempDir(), stateless: true, layout: layoutVelero}
+	if err := writeYAML(opts, schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}, item); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeVeleroMetadata(opts.outDir); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(opts.outDir, "resources", "configmaps", "namespaces", "ns", "web.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(content, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got["kind"] != "ConfigMap" {
+		t.Errorf("got kind %v, want ConfigMap", got["kind"])
+	}
+	if _, ok := got["metadata"].(map[string]interface{})["resourceVersion"]; ok {
+		t.Error("expected the object to be cleaned")
+	}
+
+	version, err := os.ReadFile(filepath.Join(opts.outDir, "metadata", "version"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(version) != veleroFormatVersion {
+		t.Errorf("got version %q, want %q", version, veleroFormatVersion)
+	}
+}
+
+func TestWriteYAMLVeleroMaxNameLength(t *testing.T) {
+	item := unstructured.Unstructured{}
+	item.SetAPIVersion("v1")
+	item.SetKind("ConfigMap")
+	item.SetNamespace("ns")
+	item.SetName(strings.Repeat("a", 100))
+
+	opts := writeOptions{outDir: t.TempDir(), layout: layoutVelero, maxNameLength: 40}
+	if err := writeYAML(opts, schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}, item); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(opts.outDir, "resources", "configmaps", "namespaces", "ns"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || len(entries[0].Name()) > 40 || !strings.HasSuffix(entries[0].Name(), ".json") {
+		t.Errorf("got %v, want a single JSON file with a name of at most 40 bytes", entries)
+	}
+}
+
+func TestWriteYAMLVeleroNoNamespace(t *testing.T) {
+	item := unstructured.Unstructured{}
+	item.SetAPIVersion("v1")
+	item.SetKind("ConfigMap")
+	item.SetName("web")
+
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	opts := writeOptions{outDir: t.TempDir(), layout: layoutVelero, namespacedRes: map[schema.GroupVersionResource]bool{gvr: true}}
+	if err := writeYAML(opts, gvr, item); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(opts.outDir, veleroPath(gvr, defaultNoNamespaceDir, "web"))); err != nil {
+		t.Errorf("expected the object below the no namespace dir: %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// writeJob is an object passed from the listers to the writers.
+type writeJob struct {
+	gvr     schema.GroupVersionResource
+	item    unstructured.Unstructured
+	pending *pendingWrites
+}
+
+// pendingWrites counts the objects of a resource which are not written yet.
+// The lister holds one reference itself until all objects are queued,
+// finish is called once with the result after the last reference is done.
+type pendingWrites struct {
+	count  int64
+	failed int32
+	finish func(complete bool)
+	// deadline abandons the remaining objects once it passed, unless zero
+	deadline time.Time
+	expired  int32
+}
+
+func newPendingWrites(finish func(complete bool)) *pendingWrites {
+	return &pendingWrites{count: 1, finish: finish}
+}
+
+// timedOut reports whether the deadline passed, the remaining objects are not written anymore then.
+func (p *pendingWrites) timedOut() bool {
+	if atomic.LoadInt32(&p.expired) == 1 {
+		return true
+	}
+	if p.deadline.IsZero() || time.Now().Before(p.deadline) {
+		return false
+	}
+	atomic.StoreInt32(&p.expired, 1)
+	return true
+}
+
+// abandoned reports whether objects were skipped due to the deadline.
+func (p *pendingWrites) abandoned() bool {
+	return atomic.LoadInt32(&p.expired) == 1
+}
+
+func (p *pendingWrites) add() {
+	atomic.AddInt64(&p.count, 1)
+}
+
+func (p *pendingWrites) done(ok bool) {
+	if !ok {
+		atomic.StoreInt32(&p.failed, 1)
+	}
+	if atomic.AddInt64(&p.count, -1) == 0 {
+		p.finish(atomic.LoadInt32(&p.failed) == 0)
+	}
+}
+
+// timedOutResources collects the resources abandoned after -timeout-per-resource. It is safe for concurrent use.
+type timedOutResources struct {
+	mu        sync.Mutex
+	resources []string
+}
+
+func (t *timedOutResources) add(gvr schema.GroupVersionResource) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.resources = append(t.resources, gvr.String())
+}
+
+func (t *timedOutResources) sorted() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	resources := append([]string(nil), t.resources...)
+	sort.Strings(resources)
+	return resources
+}
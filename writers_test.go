@@ -0,0 +1,90 @@
+package main
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestPendingWrites(t *testing.T) {
+	testCases := []struct {
+		description string
+		results     []bool
+		want        bool
+	}{
+		{description: "no objects", results: nil, want: true},
+		{description: "all written", results: []bool{true, true, true}, want: true},
+		{description: "one failed", results: []bool{true, false, true}, want: false},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.description, func(t *testing.T) {
+			var (
+				calls    int
+				complete bool
+			)
+			pending := newPendingWrites(func(c bool) {
+				calls++
+				complete = c
+			})
+
+			var wg sync.WaitGroup
+			for _, ok := range tt.results {
+				pending.add()
+				wg.Add(1)
+				go func(ok bool) {
+					defer wg.Done()
+					pending.done(ok)
+				}(ok)
+			}
+			wg.Wait()
+
+			if calls != 0 {
+				t.Fatalf("finished before the lister was done")
+			}
+			pending.done(true)
+
+			if calls != 1 {
+				t.Fatalf("got %d finish calls, want 1", calls)
+			}
+			if complete != tt.want {
+				t.Errorf("got complete %v, want %v", complete, tt.want)
+			}
+		})
+	}
+}
+
+func TestPendingWritesTimedOut(t *testing.T) {
+	pending := newPendingWrites(func(bool) {})
+	if pending.timedOut() {
+		t.Error("expected no timeout without a deadline")
+	}
+
+	pending.deadline = time.Now().Add(time.Hour)
+	if pending.timedOut() {
+		t.Error("expected no timeout before the deadline")
+	}
+
+	pending.deadline = time.Now().Add(-time.Second)
+	if !pending.timedOut() {
+		t.Error("expected a timeout after the deadline")
+	}
+	pending.deadline = time.Now().Add(time.Hour)
+	if !pending.timedOut() {
+		t.Error("expected the timeout to stick")
+	}
+}
+
+func TestTimedOutResources(t *testing.T) {
+	timedOut := &timedOutResources{}
+	timedOut.add(schema.GroupVersionResource{Version: "v1", Resource: "secrets"})
+	timedOut.add(schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"})
+
+	want := []string{"/v1, Resource=secrets", "apps/v1, Resource=deployments"}
+	if got := timedOut.sorted(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}